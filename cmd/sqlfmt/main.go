@@ -0,0 +1,81 @@
+// Sqlfmt formats queries in canonical form.
+//
+// Usage:
+//
+//	sqlfmt [-width n] [-w] [file ...]
+//
+// Without files it filters standard input to standard output. Each file
+// holds one query; with -w the formatted query replaces the file content,
+// otherwise it is written to standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"lexer/sql"
+)
+
+var (
+	width = flag.Int("width", 80, "break queries longer than `n` over several lines; 0 keeps one line")
+	write = flag.Bool("w", false, "write the result to the file instead of standard output")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: sqlfmt [-width n] [-w] [file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	opts := sql.FormatOptions{Width: *width}
+
+	if flag.NArg() == 0 {
+		if *write {
+			fatalf("cannot use -w with standard input")
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		out, err := sql.Format(string(src), opts)
+		if err != nil {
+			fatalf("<stdin>: %v", err)
+		}
+		fmt.Println(out)
+		return
+	}
+
+	failed := false
+	for _, name := range flag.Args() {
+		if err := formatFile(name, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "sqlfmt: %s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(name string, opts sql.FormatOptions) error {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out, err := sql.Format(string(src), opts)
+	if err != nil {
+		return err
+	}
+	if !*write {
+		fmt.Println(out)
+		return nil
+	}
+	return ioutil.WriteFile(name, []byte(out+"\n"), 0644)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "sqlfmt: "+format+"\n", args...)
+	os.Exit(2)
+}
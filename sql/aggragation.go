@@ -1,5 +1,12 @@
 package sql
 
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
 type Aggragation struct {
 	Items []aggItem
 }
@@ -13,6 +20,14 @@ const (
 	AggMin
 	AggMax
 	AggDistinct
+	AggMedian
+	AggPercentile
+	AggStddev
+	AggVariance
+	AggArray
+	AggStringAgg
+	AggApproxDistinct
+	AggApproxPercentile
 )
 
 var (
@@ -23,10 +38,452 @@ var (
 		itemMin:      AggMin,
 		itemMax:      AggMax,
 		itemDistinct: AggDistinct,
+
+		itemMedian:              AggMedian,
+		itemPercentile:          AggPercentile,
+		itemStddev:              AggStddev,
+		itemVariance:            AggVariance,
+		itemArrayAgg:            AggArray,
+		itemStringAgg:           AggStringAgg,
+		itemApproxCountDistinct: AggApproxDistinct,
+		itemApproxPercentile:    AggApproxPercentile,
 	}
 )
 
+var aggTypeNames = [...]string{
+	AggCount:    KeyCount,
+	AggSum:      KeySum,
+	AggAverage:  KeyAverage,
+	AggMin:      KeyMin,
+	AggMax:      KeyMax,
+	AggDistinct: KeyDistinct,
+
+	AggMedian:           KeyMedian,
+	AggPercentile:       KeyPercentile,
+	AggStddev:           KeyStddev,
+	AggVariance:         KeyVariance,
+	AggArray:            KeyArrayAgg,
+	AggStringAgg:        KeyStringAgg,
+	AggApproxDistinct:   KeyApproxCountDistinct,
+	AggApproxPercentile: KeyApproxPercentile,
+}
+
+func (a AggType) String() string {
+	if a < 0 || int(a) >= len(aggTypeNames) {
+		return "unknown"
+	}
+	return aggTypeNames[a]
+}
+
+// aggParams holds the type of the constant an aggragation takes after its
+// argument: the fraction of the values a percentile falls below, and the
+// separator of string_agg.
+var aggParams = map[AggType]Type{
+	AggPercentile:       TypeFloat,
+	AggApproxPercentile: TypeFloat,
+	AggStringAgg:        TypeString,
+}
+
+// numeric reports whether the aggragation takes numbers.
+func (a AggType) numeric() bool {
+	switch a {
+	case AggSum, AggAverage, AggMedian, AggPercentile, AggStddev, AggVariance, AggApproxPercentile:
+		return true
+	}
+	return false
+}
+
+// resultType returns the type of the aggragation of values of type t.
+func (a AggType) resultType(t Type) Type {
+	switch a {
+	case AggCount, AggDistinct, AggApproxDistinct:
+		return TypeInt
+	case AggSum:
+		if !t.numeric() {
+			return TypeNumber
+		}
+		return t
+	case AggMin, AggMax:
+		return t
+	case AggArray:
+		return TypeAny
+	case AggStringAgg:
+		return TypeString
+	}
+	return TypeFloat
+}
+
 type aggItem struct {
-	Agg   AggType
-	Field string
+	Agg      AggType
+	Field    string
+	Expr     Expr          // the argument when it is more than a field; Field holds its text
+	Distinct bool          // duplicate values of the argument are skipped
+	Params   []interface{} // the constants following the argument
+}
+
+// String returns the column name of the aggragation, e.g. "count(id)",
+// "count(distinct id)" or "percentile(age, 0.9)".
+func (a aggItem) String() string {
+	arg := a.Field
+	if a.Distinct {
+		arg = KeyDistinct + Space + arg
+	}
+	for _, p := range a.Params {
+		arg += MakrComma + Space + formatValue(p)
+	}
+	return a.Agg.String() + MarkLeftParen + arg + MarkRightParen
+}
+
+// distinctCount reports whether a counts the distinct values of its
+// argument, exactly or not.
+func (a aggItem) distinctCount() bool {
+	return a.Agg == AggDistinct || a.Agg == AggApproxDistinct || a.Distinct && a.Agg == AggCount
+}
+
+// checkParams checks the constants following the argument against
+// aggParams, converting them to its type. A placeholder is checked once
+// bound.
+func (a *aggItem) checkParams() error {
+	want, ok := aggParams[a.Agg]
+	if !ok {
+		if len(a.Params) > 0 {
+			return fmt.Errorf("%s takes a single argument", a.Agg)
+		}
+		return nil
+	}
+	if len(a.Params) != 1 {
+		return fmt.Errorf("%s takes an argument and a %s constant", a.Agg, want)
+	}
+	if _, ok := a.Params[0].(Placeholder); ok {
+		return nil
+	}
+	v, ok := want.convert(a.Params[0])
+	if !ok {
+		return fmt.Errorf("%s of %s not valid, want a %s constant", a.Agg, formatValue(a.Params[0]), want)
+	}
+	if f, ok := v.(float64); ok && (f < 0 || f > 1) {
+		return fmt.Errorf("%s of %s not between 0 and 1", a.Agg, formatValue(a.Params[0]))
+	}
+	a.Params[0] = v
+	return nil
+}
+
+// accumulator folds the values of a group into an aggragation result.
+// nil values are skipped.
+type accumulator interface {
+	add(v interface{}) error
+	result() interface{}
+}
+
+// newAccumulator returns the accumulator of agg. All but the exact
+// percentiles and the arrays fold each value into a fixed amount of state.
+func newAccumulator(agg aggItem) accumulator {
+	var acc accumulator
+	switch agg.Agg {
+	case AggCount:
+		acc = &countAcc{}
+	case AggSum:
+		acc = &sumAcc{}
+	case AggAverage:
+		acc = &averageAcc{}
+	case AggMin:
+		acc = &extremeAcc{sign: -1}
+	case AggMax:
+		acc = &extremeAcc{sign: 1}
+	case AggDistinct:
+		return &distinctAcc{acc: &countAcc{}, seen: make(map[string]struct{})}
+	case AggMedian:
+		acc = &percentileAcc{agg: agg.Agg, fraction: 0.5}
+	case AggPercentile:
+		acc = &percentileAcc{agg: agg.Agg, fraction: agg.Params[0].(float64)}
+	case AggStddev, AggVariance:
+		acc = &varianceAcc{agg: agg.Agg}
+	case AggArray:
+		acc = &arrayAcc{}
+	case AggStringAgg:
+		acc = &stringAcc{sep: agg.Params[0].(string)}
+	case AggApproxDistinct:
+		return &hllAcc{sketch: newHyperLogLog()}
+	case AggApproxPercentile:
+		acc = &sketchAcc{fraction: agg.Params[0].(float64), sketch: newQuantileSketch()}
+	default:
+		return nil
+	}
+	if agg.Distinct {
+		return &distinctAcc{acc: acc, seen: make(map[string]struct{})}
+	}
+	return acc
+}
+
+type countAcc struct {
+	n int64
+}
+
+func (a *countAcc) add(v interface{}) error {
+	if v != nil {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAcc) result() interface{} {
+	return a.n
+}
+
+// sumAcc sums as int64 until a non integer value is seen.
+type sumAcc struct {
+	seen    bool
+	isFloat bool
+	i       int64
+	f       float64
+}
+
+func (a *sumAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	a.seen = true
+	if n, ok := toInt(v); ok && !a.isFloat {
+		a.i += n
+		return nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%v: %s of %q", aggError, AggSum, v)
+	}
+	if !a.isFloat {
+		a.isFloat, a.f = true, float64(a.i)
+	}
+	a.f += f
+	return nil
+}
+
+func (a *sumAcc) result() interface{} {
+	switch {
+	case !a.seen:
+		return nil
+	case a.isFloat:
+		return a.f
+	}
+	return a.i
+}
+
+type averageAcc struct {
+	n   int64
+	sum float64
+}
+
+func (a *averageAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%v: %s of %q", aggError, AggAverage, v)
+	}
+	a.n++
+	a.sum += f
+	return nil
+}
+
+func (a *averageAcc) result() interface{} {
+	if a.n == 0 {
+		return nil
+	}
+	return a.sum / float64(a.n)
+}
+
+// extremeAcc keeps the smallest value when sign is -1 and the largest when
+// sign is 1.
+type extremeAcc struct {
+	sign int
+	v    interface{}
+}
+
+func (a *extremeAcc) add(v interface{}) error {
+	if v != nil && (a.v == nil || compareValues(v, a.v) == a.sign) {
+		a.v = v
+	}
+	return nil
+}
+
+func (a *extremeAcc) result() interface{} {
+	return a.v
+}
+
+// distinctAcc passes the distinct values of a group on to acc.
+type distinctAcc struct {
+	acc  accumulator
+	seen map[string]struct{}
+}
+
+func (a *distinctAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	k := groupKey([]interface{}{v})
+	if _, ok := a.seen[k]; ok {
+		return nil
+	}
+	a.seen[k] = struct{}{}
+	return a.acc.add(v)
+}
+
+func (a *distinctAcc) result() interface{} {
+	return a.acc.result()
+}
+
+// percentileAcc keeps the values of a group to interpolate the one that the
+// fraction of them falls below, as percentile_cont does.
+type percentileAcc struct {
+	agg      AggType
+	fraction float64
+	values   []float64
+}
+
+func (a *percentileAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%v: %s of %q", aggError, a.agg, v)
+	}
+	a.values = append(a.values, f)
+	return nil
+}
+
+func (a *percentileAcc) result() interface{} {
+	if len(a.values) == 0 {
+		return nil
+	}
+	sort.Float64s(a.values)
+	rank := a.fraction * float64(len(a.values)-1)
+	i := int(rank)
+	if i == len(a.values)-1 {
+		return a.values[i]
+	}
+	return a.values[i] + (rank-float64(i))*(a.values[i+1]-a.values[i])
+}
+
+// varianceAcc computes the sample variance of a group, or its square root
+// for stddev, with Welford's update of the mean and the sum of squared
+// deviations.
+type varianceAcc struct {
+	agg  AggType
+	n    int64
+	mean float64
+	m2   float64
+}
+
+func (a *varianceAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%v: %s of %q", aggError, a.agg, v)
+	}
+	a.n++
+	d := f - a.mean
+	a.mean += d / float64(a.n)
+	a.m2 += d * (f - a.mean)
+	return nil
+}
+
+func (a *varianceAcc) result() interface{} {
+	if a.n < 2 {
+		return nil
+	}
+	variance := a.m2 / float64(a.n-1)
+	if a.agg == AggStddev {
+		return math.Sqrt(variance)
+	}
+	return variance
+}
+
+// arrayAcc collects the values of a group in the order they come.
+type arrayAcc struct {
+	values []interface{}
+}
+
+func (a *arrayAcc) add(v interface{}) error {
+	if v != nil {
+		a.values = append(a.values, v)
+	}
+	return nil
+}
+
+func (a *arrayAcc) result() interface{} {
+	if a.values == nil {
+		return nil
+	}
+	return a.values
+}
+
+// stringAcc joins the values of a group with sep.
+type stringAcc struct {
+	sep  string
+	seen bool
+	b    strings.Builder
+}
+
+func (a *stringAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if a.seen {
+		a.b.WriteString(a.sep)
+	}
+	a.seen = true
+	fmt.Fprint(&a.b, v)
+	return nil
+}
+
+func (a *stringAcc) result() interface{} {
+	if !a.seen {
+		return nil
+	}
+	return a.b.String()
+}
+
+// hllAcc estimates the number of distinct values of a group.
+type hllAcc struct {
+	sketch *hyperLogLog
+}
+
+func (a *hllAcc) add(v interface{}) error {
+	if v != nil {
+		a.sketch.add(groupKey([]interface{}{v}))
+	}
+	return nil
+}
+
+func (a *hllAcc) result() interface{} {
+	return a.sketch.count()
+}
+
+// sketchAcc estimates a percentile of a group.
+type sketchAcc struct {
+	fraction float64
+	sketch   *quantileSketch
+}
+
+func (a *sketchAcc) add(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return fmt.Errorf("%v: %s of %q", aggError, AggApproxPercentile, v)
+	}
+	a.sketch.add(f)
+	return nil
+}
+
+func (a *sketchAcc) result() interface{} {
+	if a.sketch.n == 0 {
+		return nil
+	}
+	return a.sketch.quantile(a.fraction)
 }
@@ -0,0 +1,340 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Placeholder stands for a value supplied when the query is run: "?" and
+// "$n" are positional, ":name" is named.
+type Placeholder struct {
+	Index int    // position among the arguments, from 0; -1 when named
+	Name  string // name of a ":name" placeholder
+}
+
+func (ph Placeholder) String() string {
+	if ph.Name != "" {
+		return ":" + ph.Name
+	}
+	return fmt.Sprintf("$%d", ph.Index+1)
+}
+
+var bindError = errors.New("bind error")
+
+// Bind returns a copy of the model with its positional placeholders
+// replaced by args, which must match them in number. Arguments are
+// converted to the literal types of the parser: int64, float64, string and
+// bool, or nil.
+func (m *model) Bind(args ...interface{}) (*model, error) {
+	if len(m.Names) > 0 {
+		return nil, fmt.Errorf("%v: query has named placeholders", bindError)
+	}
+	if len(args) != m.Placeholders {
+		return nil, fmt.Errorf("%v: expected %d arguments, got %d", bindError, m.Placeholders, len(args))
+	}
+	return m.bind(func(ph Placeholder) (interface{}, bool) {
+		return args[ph.Index], true
+	})
+}
+
+// BindNamed returns a copy of the model with its named placeholders
+// replaced by args. Every name must be given; extra names are an error.
+func (m *model) BindNamed(args map[string]interface{}) (*model, error) {
+	if m.Placeholders > 0 {
+		return nil, fmt.Errorf("%v: query has positional placeholders", bindError)
+	}
+	for name := range args {
+		if !contains(m.Names, name) {
+			return nil, fmt.Errorf("%v: no placeholder :%s", bindError, name)
+		}
+	}
+	return m.bind(func(ph Placeholder) (interface{}, bool) {
+		v, ok := args[ph.Name]
+		return v, ok
+	})
+}
+
+func (m *model) bind(arg func(Placeholder) (interface{}, bool)) (*model, error) {
+	b := binder{arg: arg}
+	bound := b.model(m)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return bound, nil
+}
+
+// binder copies the parts of a model holding placeholders, keeping the
+// first error.
+type binder struct {
+	arg func(Placeholder) (interface{}, bool)
+	err error
+}
+
+// model copies m and its subqueries with their placeholders bound.
+func (b *binder) model(m *model) *model {
+	bound := *m
+	bound.Placeholders, bound.Names = 0, nil
+	b.columns(&bound, m)
+	if len(m.Joins) > 0 {
+		bound.Joins = make([]*Join, len(m.Joins))
+		for i, j := range m.Joins {
+			join := *j
+			join.On = b.condition(j.On)
+			bound.Joins[i] = &join
+		}
+	}
+	bound.Set = b.assignments(m.Set)
+	if len(m.Values) > 0 {
+		bound.Values = make([][]Assignment, len(m.Values))
+		for i, row := range m.Values {
+			bound.Values[i] = b.assignments(row)
+		}
+	}
+	bound.Conditions = b.condition(m.Conditions)
+	if len(m.SetOps) > 0 {
+		bound.SetOps = make([]*SetOp, len(m.SetOps))
+		for i, op := range m.SetOps {
+			setOp := *op
+			setOp.Query = b.model(op.Query)
+			bound.SetOps[i] = &setOp
+		}
+	}
+	if m.LimitArg != nil {
+		bound.Limit, bound.LimitArg = b.count(KeyLimit, *m.LimitArg), nil
+	}
+	if m.OffsetArg != nil {
+		bound.Offset, bound.OffsetArg = b.count(KeyOffset, *m.OffsetArg), nil
+	}
+	return &bound
+}
+
+// columns binds the placeholders of the expressions, aggragations and
+// windows of m into bound. The columns named after them are renamed after
+// the bound text, which the result columns then take.
+func (b *binder) columns(bound, m *model) {
+	rename := make(map[string]string)
+	if len(m.Expressions) > 0 {
+		bound.Expressions = make([]Expr, len(m.Expressions))
+		for i, e := range m.Expressions {
+			bound.Expressions[i] = b.expr(e)
+			rename[e.String()] = bound.Expressions[i].String()
+		}
+	}
+	if len(m.Aggragations.Items) > 0 {
+		bound.Aggragations.Items = make([]aggItem, len(m.Aggragations.Items))
+		for i, agg := range m.Aggragations.Items {
+			bound.Aggragations.Items[i] = b.agg(agg)
+			rename[agg.String()] = bound.Aggragations.Items[i].String()
+		}
+	}
+	if len(m.Windows) > 0 {
+		bound.Windows = make([]*Window, len(m.Windows))
+		for i, w := range m.Windows {
+			window := *w
+			window.Agg = b.agg(w.Agg)
+			if len(w.Args) > 0 {
+				window.Args = make([]Expr, len(w.Args))
+				for j, arg := range w.Args {
+					window.Args[j] = b.expr(arg)
+				}
+			}
+			window.PartitionBy = renamed(w.PartitionBy, rename)
+			window.OrderBy = renamedOrder(w.OrderBy, rename)
+			bound.Windows[i] = &window
+		}
+		for i, w := range m.Windows {
+			rename[w.String()] = bound.Windows[i].String()
+		}
+	}
+	bound.Columns = renamed(m.Columns, rename)
+	bound.GroupBy = renamed(m.GroupBy, rename)
+	bound.OrderBy = renamedOrder(m.OrderBy, rename)
+}
+
+// renamed returns a copy of columns renamed by rename.
+func renamed(columns []string, rename map[string]string) []string {
+	if columns == nil {
+		return nil
+	}
+	list := make([]string, len(columns))
+	for i, c := range columns {
+		if to, ok := rename[c]; ok {
+			c = to
+		}
+		list[i] = c
+	}
+	return list
+}
+
+// renamedOrder returns a copy of order with its columns renamed by rename.
+func renamedOrder(order []orderItem, rename map[string]string) []orderItem {
+	if order == nil {
+		return nil
+	}
+	list := make([]orderItem, len(order))
+	for i, o := range order {
+		if to, ok := rename[o.Column]; ok {
+			o.Column = to
+		}
+		list[i] = o
+	}
+	return list
+}
+
+// agg copies agg with the placeholders of its argument and constants
+// bound.
+func (b *binder) agg(agg aggItem) aggItem {
+	if agg.Expr != nil {
+		agg.Expr = b.expr(agg.Expr)
+		agg.Field = agg.Expr.String()
+	}
+	if len(agg.Params) > 0 {
+		params := make([]interface{}, len(agg.Params))
+		for i, v := range agg.Params {
+			params[i] = b.value(v)
+		}
+		agg.Params = params
+		if err := agg.checkParams(); err != nil && b.err == nil {
+			b.err = fmt.Errorf("%v: %v", bindError, err)
+		}
+	}
+	return agg
+}
+
+// expr copies e with the placeholders among its literals bound.
+func (b *binder) expr(e Expr) Expr {
+	if e == nil {
+		return nil
+	}
+	return rewriteExpr(e, func(l *Literal) Expr {
+		if _, ok := l.Value.(Placeholder); !ok {
+			return l
+		}
+		return &Literal{Value: b.value(l.Value)}
+	})
+}
+
+func (b *binder) assignments(list []Assignment) []Assignment {
+	if list == nil {
+		return nil
+	}
+	bound := make([]Assignment, len(list))
+	for i, a := range list {
+		bound[i] = a
+		bound[i].Value = b.value(a.Value)
+		bound[i].Expr = b.expr(a.Expr)
+	}
+	return bound
+}
+
+func (b *binder) condition(c Condition) Condition {
+	switch c := c.(type) {
+	case *SingleCondition:
+		single := *c
+		single.Value = b.value(c.Value)
+		if list, ok := c.Value.([]interface{}); ok {
+			values := make([]interface{}, len(list))
+			for i, v := range list {
+				values[i] = b.value(v)
+			}
+			single.Value = values
+		}
+		single.Expr = b.expr(c.Expr)
+		if sub, ok := c.Right.(*Subquery); ok {
+			single.Right = &Subquery{Query: b.model(sub.Query)}
+		} else {
+			single.Right = b.expr(c.Right)
+		}
+		if c.Comparator.takesPattern() && b.err == nil {
+			if _, ok := single.Value.(string); !ok {
+				b.err = fmt.Errorf("%v: %s pattern for %s must be a string, got %T", bindError, c.Comparator, c.Field, single.Value)
+			} else if err := single.checkPattern(); err != nil {
+				b.err = fmt.Errorf("%v: %v", bindError, err)
+			}
+		}
+		return &single
+	case *MultiCondition:
+		multi := &MultiCondition{Logic: c.Logic, SubConditions: make([]Condition, len(c.SubConditions))}
+		for i, sub := range c.SubConditions {
+			multi.SubConditions[i] = b.condition(sub)
+		}
+		return multi
+	}
+	return c
+}
+
+// count returns the argument for the placeholder of a limit or offset,
+// which must be a non-negative integer.
+func (b *binder) count(clause string, ph Placeholder) int {
+	v := b.value(ph)
+	n, ok := v.(int64)
+	if b.err == nil && (!ok || n < 0) {
+		b.err = fmt.Errorf("%v: %s %s must be a non-negative integer, got %v", bindError, clause, ph, v)
+	}
+	return int(n)
+}
+
+// value returns v, or the argument for v if it is a placeholder.
+func (b *binder) value(v interface{}) interface{} {
+	ph, ok := v.(Placeholder)
+	if !ok {
+		return v
+	}
+	arg, ok := b.arg(ph)
+	if !ok {
+		if b.err == nil {
+			b.err = fmt.Errorf("%v: missing argument for %s", bindError, ph)
+		}
+		return nil
+	}
+	lit, err := literal(arg)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("%v: argument for %s: %v", bindError, ph, err)
+	}
+	return lit
+}
+
+// literal converts v to one of the literal types of the parser.
+func literal(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, int64, float64, string, bool, time.Time, Interval:
+		return v, nil
+	case time.Duration:
+		return Interval{Duration: v}, nil
+	case []byte:
+		return string(v), nil
+	case float32:
+		return float64(v), nil
+	}
+	if n, ok := toInt(v); ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// checkCounts reports a placeholder left as the limit or offset.
+func (m *model) checkCounts() error {
+	for _, ph := range []*Placeholder{m.LimitArg, m.OffsetArg} {
+		if ph != nil {
+			return fmt.Errorf("%v: placeholder %s not bound", bindError, ph)
+		}
+	}
+	return nil
+}
+
+// checkBound reports a placeholder left in v.
+func checkBound(v interface{}) error {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if err := checkBound(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if ph, ok := v.(Placeholder); ok {
+		return fmt.Errorf("%v: placeholder %s not bound", bindError, ph)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type bindTest struct {
+	query string
+	args  []interface{}
+	named map[string]interface{}
+	rows  []string
+	err   bool
+}
+
+var bindTests = []bindTest{
+	{query: `select name where age > ? and region = ?`, args: []interface{}{26, "cn-beijing"}, rows: []string{"alice", "carol"}},
+	{query: `select name where age > $2 or name = $1`, args: []interface{}{"bob", 30}, rows: []string{"bob", "carol"}},
+	{query: `select name where region = :region and age >= :age`, named: map[string]interface{}{"region": "cn-beijing", "age": 31}, rows: []string{"carol"}},
+	{query: `select name where name in ("bob", ?, :x)`, err: true},
+	{query: `select name where name in ("bob", ?, "erin") order by name`, args: []interface{}{[]byte("alice")}, rows: []string{"alice", "bob", "erin"}},
+	{query: `select name order by name limit ?`, args: []interface{}{2}, rows: []string{"alice", "bob"}},
+	{query: `select name order by name limit :n`, named: map[string]interface{}{"n": 1}, rows: []string{"alice"}},
+	{query: `select name limit ?`, args: []interface{}{"2"}, err: true},
+	{query: `select name order by name limit :n offset :m`, named: map[string]interface{}{"n": 1, "m": 2}, rows: []string{"carol"}},
+	{query: `select name offset ?`, args: []interface{}{-1}, err: true},
+	{query: `select name where age > ?`, args: []interface{}{}, err: true},
+	{query: `select name where age > ?`, args: []interface{}{1, 2}, err: true},
+	{query: `select name where age > ?`, args: []interface{}{struct{}{}}, err: true},
+	{query: `select name where name like ?`, args: []interface{}{1}, err: true},
+	{query: `select name where age = ?`, args: []interface{}{nil}, rows: []string{"dave"}},
+	{query: `select name where age != :age and region = :region`, named: map[string]interface{}{"age": nil, "region": "cn-shanghai"}, rows: []string{"bob"}},
+	{query: `select name where substr(region, 1, ?) = "us" order by name`, args: []interface{}{2}, rows: []string{"erin"}},
+	{query: `select upper(name) where coalesce(age, :age) < 26 order by name`, named: map[string]interface{}{"age": 0}, rows: []string{"BOB", "DAVE"}},
+	{query: `select name where age + ? > 60 order by name`, args: []interface{}{30}, rows: []string{"carol"}},
+	{query: `select upper(name || :s) where age < :a`, named: map[string]interface{}{"s": "!", "a": 26}, rows: []string{"BOB!"}},
+	{query: `select string_agg(name, ?) where region = "cn-beijing"`, args: []interface{}{"+"}, rows: []string{"alice+carol"}},
+	{query: `select percentile(age, ?)`, args: []interface{}{2}, err: true},
+	{query: `select name where age < ? * 2 + age order by name`, args: []interface{}{1}, rows: []string{"alice", "bob", "carol", "erin"}},
+	{query: `select name where name !~ ?`, args: []interface{}{"a("}, err: true},
+	{query: `select name where age > ? or age < $1`, err: true},
+	{query: `select name where age > :a`, named: map[string]interface{}{"a": 1, "b": 2}, err: true},
+	{query: `select name where age > :a`, named: map[string]interface{}{}, err: true},
+}
+
+func Test_Bind(t *testing.T) {
+	e := newTestEngine()
+	for _, test := range bindTests {
+		var rows *Rows
+		stmt, err := e.Prepare(test.query)
+		if err == nil && test.named != nil {
+			rows, err = stmt.QueryNamed(context.Background(), test.named)
+		} else if err == nil {
+			rows, err = stmt.Query(context.Background(), test.args...)
+		}
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var got []string
+		for rows.Next() {
+			var name string
+			rows.Scan(&name)
+			got = append(got, name)
+		}
+		if !reflect.DeepEqual(got, test.rows) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.rows)
+		}
+	}
+}
+
+func Test_BindKeepsModel(t *testing.T) {
+	p := NewParse(`select name where age > ? limit ?`)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := p.Bind(18, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Limit != 5 || m.Conditions.(*SingleCondition).Value != int64(18) {
+		t.Errorf("bound model %+v", m)
+	}
+	if p.LimitArg == nil || p.Conditions.(*SingleCondition).Value != (Placeholder{Index: 0}) {
+		t.Errorf("parsed model changed: %+v", p.model)
+	}
+}
+
+func Test_BindExpressions(t *testing.T) {
+	e := newTestEngine()
+	stmt, err := e.Prepare(`select name, substr(region, 1, $1) order by substr(region, 1, $1) desc, name limit 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.Query(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if columns := rows.Columns(); !reflect.DeepEqual(columns, []string{"name", "substr(region, 1, 2)"}) {
+		t.Errorf("columns %v", columns)
+	}
+	var name, region string
+	if !rows.Next() || rows.Scan(&name, &region) != nil || name != "erin" || region != "us" {
+		t.Errorf("got %s %s, %v", name, region, rows.Err())
+	}
+}
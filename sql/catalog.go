@@ -0,0 +1,172 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var schemaError = errors.New("schema error")
+
+// Column is a typed column of a table in a Catalog.
+type Column struct {
+	Name string
+	Type Type
+}
+
+func (c Column) String() string {
+	return c.Name + Space + c.Type.String()
+}
+
+// typeByName maps the type names of create table to types, taking the
+// common SQL names as aliases.
+var typeByName = map[string]Type{
+	"any":       TypeAny,
+	"int":       TypeInt,
+	"integer":   TypeInt,
+	"bigint":    TypeInt,
+	"float":     TypeFloat,
+	"double":    TypeFloat,
+	"real":      TypeFloat,
+	"number":    TypeNumber,
+	"numeric":   TypeNumber,
+	"string":    TypeString,
+	"text":      TypeString,
+	"varchar":   TypeString,
+	"bool":      TypeBool,
+	"boolean":   TypeBool,
+	"timestamp": TypeTimestamp,
+	"datetime":  TypeTimestamp,
+	"date":      TypeTimestamp,
+	"interval":  TypeInterval,
+}
+
+// newColumn returns the column name of the type named typ.
+func newColumn(name, typ string) (Column, error) {
+	t, ok := typeByName[strings.ToLower(typ)]
+	if !ok {
+		return Column{}, fmt.Errorf("unknown type %s of column %s", typ, name)
+	}
+	return Column{Name: name, Type: t}, nil
+}
+
+// Catalog holds the schemas of tables: their columns and the types of
+// them. Queries are checked against the schemas when they are prepared, and
+// the values of the tables are converted to the types when they are read
+// or written. Tables without a schema are taken as they are.
+type Catalog struct {
+	mu      sync.RWMutex
+	schemas map[string][]Column
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{schemas: make(map[string][]Column)}
+}
+
+// Define gives the table name the schema columns. A table is defined once.
+func (c *Catalog) Define(name string, columns ...Column) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("%v: table %s has no columns", schemaError, name)
+	}
+	for i, col := range columns {
+		for _, prev := range columns[:i] {
+			if prev.Name == col.Name {
+				return fmt.Errorf("%v: column %s defined twice", schemaError, col.Name)
+			}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.schemas[name]; ok {
+		return fmt.Errorf("%v: table %s already defined", schemaError, name)
+	}
+	c.schemas[name] = append([]Column(nil), columns...)
+	return nil
+}
+
+// Columns returns the schema of the table name, if it has one.
+func (c *Catalog) Columns(name string) ([]Column, bool) {
+	c.mu.RLock()
+	columns, ok := c.schemas[name]
+	c.mu.RUnlock()
+	return columns, ok
+}
+
+// types returns the types of the columns of t by the schema of the table
+// name, nil when it has none.
+func (c *Catalog) types(name string, t Table) ([]Type, error) {
+	schema, ok := c.Columns(name)
+	if !ok {
+		return nil, nil
+	}
+	columns := t.Columns()
+	types := make([]Type, len(columns))
+	for _, col := range schema {
+		idx := -1
+		for i, column := range columns {
+			if column == col.Name {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("%v: table %s has no column %s", schemaError, name, col.Name)
+		}
+		types[idx] = col.Type
+	}
+	return types, nil
+}
+
+// convertRow converts the values of row to types, in place. An empty
+// string is null for every type but strings, as file backed tables write
+// nulls that way.
+func convertRow(types []Type, columns []string, row []interface{}) error {
+	for i, t := range types {
+		if row[i] == nil || t == TypeAny {
+			continue
+		}
+		if s, ok := row[i].(string); ok && s == "" && t != TypeString {
+			row[i] = nil
+			continue
+		}
+		v, ok := t.convert(row[i])
+		if !ok {
+			return fmt.Errorf("%v: value %v of column %s is not %s", schemaError, row[i], columns[i], t)
+		}
+		row[i] = v
+	}
+	return nil
+}
+
+// typedTable converts the values of a table to the types of its schema.
+type typedTable struct {
+	Table
+	types []Type
+}
+
+func (t *typedTable) Cursor(ctx context.Context) (Cursor, error) {
+	c, err := t.Table.Cursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &typedCursor{Cursor: c, table: t}, nil
+}
+
+type typedCursor struct {
+	Cursor
+	table *typedTable
+}
+
+func (c *typedCursor) Next() ([]interface{}, error) {
+	row, err := c.Cursor.Next()
+	if err != nil {
+		return nil, err
+	}
+	// the row may be shared with the table
+	row = append([]interface{}(nil), row...)
+	if err := convertRow(c.table.types, c.table.Columns(), row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Catalog(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine()
+	if _, err := e.Exec(ctx, `create table events (id int, name string, score float, at timestamp)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Exec(ctx, `insert into events values ("1", "open", 2, "2024-01-02"), (2, ?, "", ?)`, "close", "2024-01-03T10:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	// a table of text, typed by its schema
+	e.Register("logs", NewMemTable([]string{"event", "level"},
+		[]interface{}{"1", "3"},
+		[]interface{}{"2", ""},
+	))
+	if err := e.Catalog().Define("logs", Column{"event", TypeInt}, Column{"level", TypeInt}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := e.Query(ctx, `select e.id, e.score, e.at, l.level from events e join logs l on l.event = e.id where e.at > (select min(at) from events) or l.level > 2 order by e.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readAll(ctx, rows.iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{int64(1), 2.0, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), int64(3)},
+		{int64(2), nil, time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, query := range []string{
+		`select nosuch from events`,
+		`select name from events where nosuch = 1`,
+		`select name from events order by nosuch`,
+		`select count(nosuch) from events`,
+		`select id from events where id in (select nosuch from logs)`,
+		`select e.id from events e join logs l on l.nosuch = e.id`,
+		`select id from events union select nosuch from logs`,
+		`insert into events (id, nosuch) values (1, 2)`,
+		`insert into events values (1, "x")`,
+		`update events set nosuch = 1`,
+		`delete from events where nosuch = 1`,
+	} {
+		if _, err := e.Prepare(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	for _, query := range []string{
+		`select name, upper(name) from events where exists (select id from logs where logs.event = events.id) order by upper(name)`,
+		`select nosuch from graph`,
+	} {
+		if _, err := e.Prepare(query); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+	for _, query := range []string{
+		`create table events (id int)`,
+		`insert into events (id) values ("x")`,
+		`update events set at = "never"`,
+	} {
+		if _, err := e.Exec(ctx, query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	for _, query := range []string{
+		`create table t (id int, id string)`,
+		`create table t (id blob)`,
+		`create table t ()`,
+		`create table t (id int) where id = 1`,
+		`select id where id in (create table t (id int))`,
+	} {
+		if _, err := e.Prepare(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+}
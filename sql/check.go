@@ -0,0 +1,562 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var typeError = errors.New("type error")
+
+// checker checks queries against the schemas of a catalog: the fields they
+// refer to must exist, and the types of the values they compare, compute
+// and aggragate must fit. Errors give the offset of the offending text in
+// the query when positions has it.
+type checker struct {
+	catalog   *Catalog
+	positions positions
+}
+
+// errorf returns the error err followed by the message, and the offset of
+// key in the query, an expression, a condition or a textKey.
+func (c *checker) errorf(err error, key interface{}, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if pos, ok := c.positions[key]; ok {
+		return fmt.Errorf("%v: %s at offset %d", err, msg, pos)
+	}
+	return fmt.Errorf("%v: %s", err, msg)
+}
+
+// checkScope holds the columns of the tables a query reads, as the catalog
+// knows them, for checking the fields the query refers to. Like scope, it
+// resolves fields against the enclosing queries too.
+type checkScope struct {
+	m       *model
+	tables  []string
+	columns []string
+	types   []Type
+	open    bool // a table has no schema, so any field may be one of its
+	outer   *checkScope
+}
+
+// field returns the type of the field name, or false when no table of a
+// known schema has it.
+func (s *checkScope) field(name string) (Type, bool) {
+	qualifier := ""
+	if dot := strings.LastIndex(name, MarkDot); dot >= 0 {
+		qualifier = name[:dot]
+	}
+	for q := s; q != nil; q = q.outer {
+		if qualifier != "" && !contains(q.tables, qualifier) && q.outer.qualifies(qualifier) {
+			continue
+		}
+		if idx, err := columnIndex(q.columns, q.tables, name); err == nil {
+			return q.types[idx], true
+		}
+		if q.open {
+			return TypeAny, true
+		}
+	}
+	return TypeAny, false
+}
+
+// qualifies reports whether the tables of s or of an enclosing query are
+// named name.
+func (s *checkScope) qualifies(name string) bool {
+	for q := s; q != nil; q = q.outer {
+		if contains(q.tables, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// field returns the type of the field name of the query of s.
+func (c *checker) field(s *checkScope, name string) (Type, error) {
+	t, ok := s.field(name)
+	if !ok {
+		return TypeAny, c.errorf(schemaError, textKey{s.m, name}, "unknown column %s", name)
+	}
+	return t, nil
+}
+
+// check checks m and its subqueries, annotating m with the types of its
+// columns. outer is the scope of the enclosing query.
+func (c *checker) check(m *model, outer *checkScope) error {
+	if m.Type == SqlCreate {
+		return nil
+	}
+	s := c.checkScope(m, outer)
+	if m.Type == SqlInsert {
+		return c.checkInsert(s)
+	}
+	for _, f := range m.fieldRefs() {
+		if _, err := c.field(s, f); err != nil {
+			return err
+		}
+	}
+	if err := c.checkCondition(s, m.Conditions); err != nil {
+		return err
+	}
+	for _, j := range m.Joins {
+		if err := c.checkCondition(s, j.On); err != nil {
+			return err
+		}
+	}
+	if m.Type == SqlUpdate {
+		for _, a := range m.Set {
+			if err := c.checkAssignment(s, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if m.Type != SqlSelect {
+		return nil
+	}
+	if err := c.columnTypes(s); err != nil {
+		return err
+	}
+	for _, op := range m.SetOps {
+		if err := c.check(op.Query, outer); err != nil {
+			return err
+		}
+		for i, t := range op.Query.Types {
+			if !compatible(m.Types[i], t) {
+				return c.errorf(typeError, textKey{op.Query, op.Query.Columns[i]}, "%s column %d has types %s and %s", op, i+1, m.Types[i], t)
+			}
+			switch {
+			case m.Types[i] == t:
+			case m.Types[i] != TypeAny && t != TypeAny:
+				m.Types[i] = TypeNumber
+			default:
+				m.Types[i] = TypeAny
+			}
+		}
+	}
+	return nil
+}
+
+// checkScope returns the scope of m, whose columns are qualified with
+// their tables when m joins some.
+func (c *checker) checkScope(m *model, outer *checkScope) *checkScope {
+	s := &checkScope{m: m, tables: m.tables(), outer: outer}
+	names := []string{m.TableName}
+	for _, j := range m.Joins {
+		names = append(names, j.Table)
+	}
+	for i, name := range names {
+		schema, ok := c.catalog.Columns(name)
+		if !ok {
+			s.open = true
+			continue
+		}
+		for _, col := range schema {
+			column := col.Name
+			if len(m.Joins) > 0 {
+				column = s.tables[i] + MarkDot + column
+			}
+			s.columns = append(s.columns, column)
+			s.types = append(s.types, col.Type)
+		}
+	}
+	return s
+}
+
+// checkInsert checks the columns an insert names, or the number of values
+// when it names none, and the types of the values.
+func (c *checker) checkInsert(s *checkScope) error {
+	m := s.m
+	for _, row := range m.Values {
+		if row[0].Column == "" {
+			if s.open {
+				continue
+			}
+			if len(row) != len(s.columns) {
+				return fmt.Errorf("%v: %d values for %d columns of %s", schemaError, len(row), len(s.columns), m.TableName)
+			}
+			for i, a := range row {
+				a.Column = s.columns[i]
+				if err := c.checkAssignment(s, a); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, a := range row {
+			if err := c.checkAssignment(s, a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAssignment checks that the value a assigns fits its column.
+func (c *checker) checkAssignment(s *checkScope, a Assignment) error {
+	t, err := c.field(s, a.Column)
+	if err != nil {
+		return err
+	}
+	var key interface{} = a.Expr
+	e := a.Expr
+	if e == nil {
+		if _, ok := a.Value.(Placeholder); ok {
+			return nil
+		}
+		// a value is placed by its column
+		key, e = textKey{s.m, a.Column}, &Literal{Value: a.Value}
+	}
+	u, err := c.typeOf(s, e)
+	if err != nil {
+		return err
+	}
+	if !assignable(t, e, u) {
+		return c.errorf(typeError, key, "%s %s assigned to %s column %s", u, e, t, a.Column)
+	}
+	return nil
+}
+
+// checkCondition checks that the sides of the comparisons of cond fit,
+// widening an int compared with a float.
+func (c *checker) checkCondition(s *checkScope, cond Condition) error {
+	var err error
+	walkConditions(cond, func(sc *SingleCondition) {
+		if err == nil {
+			err = c.checkSingleCondition(s, sc)
+		}
+	})
+	return err
+}
+
+func (c *checker) checkSingleCondition(s *checkScope, sc *SingleCondition) error {
+	if sub, ok := sc.Right.(*Subquery); ok {
+		if err := c.check(sub.Query, s); err != nil {
+			return err
+		}
+	}
+	if sc.Comparator == ComparatorEXISTS {
+		return nil
+	}
+	var left Expr = &Ident{Name: sc.Field}
+	if sc.Expr != nil {
+		left = sc.Expr
+	}
+	t, err := c.typeOf(s, left)
+	if err != nil {
+		return err
+	}
+	if sc.Comparator.takesPattern() && !compatible(t, TypeString) {
+		return c.errorf(typeError, sc, "%s of %s %s", sc.Comparator, t, sc.Field)
+	}
+	// the right side is a subquery, an expression, a value or a list
+	switch right := sc.Right.(type) {
+	case *Subquery:
+		if len(right.Query.Types) != 1 {
+			return nil
+		}
+		if u := right.Query.Types[0]; !compatible(t, u) {
+			return c.errorf(typeError, sc, "%s %s %s compared with %s", t, sc.Field, sc.Comparator, u)
+		}
+		return nil
+	case nil:
+	default:
+		u, err := c.typeOf(s, right)
+		if err != nil {
+			return err
+		}
+		if !comparable(t, right, u) {
+			return c.errorf(typeError, sc, "%s %s %s %s %s", t, sc.Field, sc.Comparator, u, right)
+		}
+		switch {
+		case t == TypeInt && u == TypeFloat:
+			sc.Expr = &Cast{X: left, Type: TypeFloat}
+		case t == TypeFloat && u == TypeInt:
+			sc.Right = &Cast{X: right, Type: TypeFloat}
+		}
+		return nil
+	}
+	values, ok := sc.Value.([]interface{})
+	if !ok {
+		values = []interface{}{sc.Value}
+	}
+	widen := false
+	for i, v := range values {
+		if _, ok := v.(Placeholder); ok {
+			continue
+		}
+		l := &Literal{Value: v}
+		if !comparable(t, l, exprType(l)) {
+			return c.errorf(typeError, sc, "%s %s %s %s %s", t, sc.Field, sc.Comparator, exprType(l), l)
+		}
+		switch v := v.(type) {
+		case int64:
+			if t == TypeFloat {
+				values[i] = float64(v)
+			}
+		case float64:
+			widen = widen || t == TypeInt
+		}
+	}
+	if widen {
+		sc.Expr = &Cast{X: left, Type: TypeFloat}
+	}
+	if !ok {
+		sc.Value = values[0]
+	}
+	return nil
+}
+
+// columnTypes checks the expressions, aggragations and windows of the
+// select of s and sets the types of its columns.
+func (c *checker) columnTypes(s *checkScope) error {
+	m := s.m
+	types := make(map[string]Type)
+	for _, f := range m.Fields {
+		types[f], _ = s.field(f)
+	}
+	for _, e := range m.Expressions {
+		t, err := c.typeOf(s, e)
+		if err != nil {
+			return err
+		}
+		types[e.String()] = t
+	}
+	for _, agg := range m.Aggragations.Items {
+		var arg Expr = &Ident{Name: agg.Field}
+		if agg.Expr != nil {
+			arg = agg.Expr
+		}
+		t, err := c.typeOf(s, arg)
+		if err != nil {
+			return err
+		}
+		if agg.Agg.numeric() && !compatible(t, TypeNumber) {
+			return c.errorf(typeError, textKey{m, agg.String()}, "%s of %s %s", agg.Agg, t, agg.Field)
+		}
+		types[agg.String()] = agg.Agg.resultType(t)
+	}
+	for _, w := range m.Windows {
+		t, err := c.windowType(s, w)
+		if err != nil {
+			return err
+		}
+		types[w.String()] = t
+	}
+	m.Types = make([]Type, len(m.Columns))
+	for i, column := range m.Columns {
+		m.Types[i] = types[column]
+	}
+	return nil
+}
+
+// windowType checks the arguments of the window w and returns the type of
+// its values. The default of lag and lead must fit their argument.
+func (c *checker) windowType(s *checkScope, w *Window) (Type, error) {
+	key := textKey{s.m, w.String()}
+	arg := w.arg()
+	if arg == nil {
+		return w.resultType(TypeAny), nil
+	}
+	t, err := c.typeOf(s, arg)
+	if err != nil {
+		return TypeAny, err
+	}
+	if w.Func == "" && w.Agg.Agg.numeric() && !compatible(t, TypeNumber) {
+		return TypeAny, c.errorf(typeError, key, "%s of %s %s", w.Agg.Agg, t, w.Agg.Field)
+	}
+	if len(w.Args) > 2 {
+		u, err := c.typeOf(s, w.Args[2])
+		if err != nil {
+			return TypeAny, err
+		}
+		if !assignable(t, w.Args[2], u) {
+			return TypeAny, c.errorf(typeError, key, "%s default %s %s of %s %s", w.Func, u, w.Args[2], t, arg)
+		}
+	}
+	return w.resultType(t), nil
+}
+
+// typeOf returns the type of e, checking the types of its operands and
+// arguments. An int operand of an arithmetic with a float is widened.
+func (c *checker) typeOf(s *checkScope, e Expr) (Type, error) {
+	switch e := e.(type) {
+	case *Ident:
+		return c.field(s, e.Name)
+	case *Subquery:
+		if err := c.check(e.Query, s); err != nil {
+			return TypeAny, err
+		}
+		if len(e.Query.Types) == 1 {
+			return e.Query.Types[0], nil
+		}
+		return TypeAny, nil
+	case *Call:
+		fn, ok := lookupFunction(e.Name)
+		if !ok {
+			return TypeAny, c.errorf(funcError, e, "unknown function %s", e.Name)
+		}
+		for i, arg := range e.Args {
+			t, err := c.typeOf(s, arg)
+			if err != nil {
+				return TypeAny, err
+			}
+			if want := fn.argType(i); !assignable(want, arg, t) {
+				return TypeAny, c.errorf(typeError, e, "%s argument %d is %s, not %s", e.Name, i+1, t, want)
+			}
+		}
+		return fn.Result, nil
+	case *Binary:
+		t, err := c.typeOf(s, e.Left)
+		if err != nil {
+			return TypeAny, err
+		}
+		u, err := c.typeOf(s, e.Right)
+		if err != nil {
+			return TypeAny, err
+		}
+		if e.Op == OpConcat {
+			return TypeString, nil
+		}
+		if temporal(t) || temporal(u) {
+			// a string literal may stand for the timestamp
+			if t == TypeString && assignable(TypeTimestamp, e.Left, t) {
+				t = TypeTimestamp
+			}
+			if u == TypeString && assignable(TypeTimestamp, e.Right, u) {
+				u = TypeTimestamp
+			}
+			typ, ok := timeArithType(e.Op, t, u)
+			if !ok {
+				return TypeAny, c.errorf(typeError, e, "%s %s %s in %s", t, e.Op, u, e)
+			}
+			return typ, nil
+		}
+		for _, side := range []struct {
+			e Expr
+			t Type
+		}{{e.Left, t}, {e.Right, u}} {
+			if !assignable(TypeNumber, side.e, side.t) {
+				return TypeAny, c.errorf(typeError, e, "%s %s in %s", side.t, side.e, e)
+			}
+		}
+		switch {
+		case t == TypeInt && u == TypeFloat:
+			e.Left = &Cast{X: e.Left, Type: TypeFloat}
+		case t == TypeFloat && u == TypeInt:
+			e.Right = &Cast{X: e.Right, Type: TypeFloat}
+		}
+		switch {
+		case t == TypeInt && u == TypeInt:
+			return TypeInt, nil
+		case t == TypeFloat || u == TypeFloat:
+			return TypeFloat, nil
+		}
+		return TypeNumber, nil
+	case *Unary:
+		t, err := c.typeOf(s, e.X)
+		if err != nil {
+			return TypeAny, err
+		}
+		if !assignable(TypeNumber, e.X, t) && t != TypeInterval {
+			return TypeAny, c.errorf(typeError, e, "%s %s in %s", t, e.X, e)
+		}
+		if t.numeric() || t == TypeInterval {
+			return t, nil
+		}
+		return TypeNumber, nil
+	}
+	return exprType(e), nil
+}
+
+// temporal reports whether t is a timestamp or an interval.
+func temporal(t Type) bool {
+	return t == TypeTimestamp || t == TypeInterval
+}
+
+// assignable reports whether e, of type t, may stand for a value of type
+// want: a literal converting to it, a placeholder, whose argument is only
+// known when bound, an empty string standing for null as in file backed
+// tables, or an expression of a compatible type.
+func assignable(want Type, e Expr, t Type) bool {
+	if l, ok := e.(*Literal); ok {
+		if _, ok := l.Value.(Placeholder); ok || l.Value == nil || l.Value == "" {
+			return true
+		}
+		_, ok := want.convert(l.Value)
+		return ok
+	}
+	return compatible(want, t)
+}
+
+// comparable reports whether e, of type t, may be compared with a value of
+// type want: numbers compare with numbers, and otherwise e must be
+// assignable to want.
+func comparable(want Type, e Expr, t Type) bool {
+	return want.numeric() && t.numeric() || assignable(want, e, t)
+}
+
+// fieldRefs returns the fields m refers to outside its subqueries.
+func (m *model) fieldRefs() []string {
+	refs := append([]string(nil), m.Fields...)
+	for _, e := range m.Expressions {
+		refs = append(refs, exprFields(e)...)
+	}
+	computed := make(map[string]bool)
+	for _, agg := range m.Aggragations.Items {
+		computed[agg.String()] = true
+		if agg.Expr != nil {
+			computed[agg.Field] = true
+			refs = append(refs, exprFields(agg.Expr)...)
+		} else {
+			refs = append(refs, agg.Field)
+		}
+	}
+	for _, f := range m.GroupBy {
+		if !computed[f] {
+			refs = append(refs, f)
+		}
+	}
+	for _, e := range m.Expressions {
+		computed[e.String()] = true
+	}
+	refs = append(refs, m.windowFields()...)
+	for _, w := range m.Windows {
+		computed[w.String()] = true
+	}
+	for _, o := range m.OrderBy {
+		if !computed[o.Column] && !contains(m.Columns, o.Column) {
+			refs = append(refs, o.Column)
+		}
+	}
+	walkConditions(m.Conditions, func(c *SingleCondition) {
+		refs = append(refs, conditionFields(c)...)
+	})
+	for _, j := range m.Joins {
+		walkConditions(j.On, func(c *SingleCondition) {
+			refs = append(refs, conditionFields(c)...)
+		})
+	}
+	for _, a := range m.Set {
+		refs = append(refs, a.Column)
+		if a.Expr != nil {
+			refs = append(refs, exprFields(a.Expr)...)
+		}
+	}
+	return refs
+}
+
+// conditionFields returns the fields c compares, leaving out its subquery.
+func conditionFields(c *SingleCondition) []string {
+	var fields []string
+	switch {
+	case c.Expr != nil:
+		fields = exprFields(c.Expr)
+	case c.Field != "":
+		fields = []string{c.Field}
+	}
+	if _, ok := c.Right.(*Subquery); !ok && c.Right != nil {
+		fields = append(fields, exprFields(c.Right)...)
+	}
+	return fields
+}
@@ -0,0 +1,137 @@
+package sql
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newTypedEngine(t *testing.T) *Engine {
+	e := NewEngine()
+	e.Register("people", NewMemTable([]string{"id", "name", "age", "score"},
+		[]interface{}{int64(1), "ann", int64(30), 1.5},
+		[]interface{}{int64(2), "bob", int64(3), 2.0},
+	))
+	if err := e.Catalog().Define("people",
+		Column{"id", TypeInt}, Column{"name", TypeString}, Column{"age", TypeInt}, Column{"score", TypeFloat},
+	); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func Test_TypeCheck(t *testing.T) {
+	e := newTypedEngine(t)
+	for _, test := range []struct {
+		query string
+		err   string
+	}{
+		{`select name from people where age like "1%"`, `type error: like of int age at offset 30`},
+		{`select name from people where age ~ "1"`, `type error: ~ of int age at offset 30`},
+		{`select name from people where name ~ "a("`, "syntax error: error parsing regexp: missing closing ): `a(` at offset 37"},
+		{`select sum(name) from people`, `type error: sum of string name at offset 7`},
+		{`select percentile(name, 0.5) from people`, `type error: percentile of string name at offset 7`},
+		{`select percentile(age, 1.5) from people`, `aggragation error: percentile of 1.5 not between 0 and 1 at offset 7`},
+		{`select percentile(age) from people`, `aggragation error: percentile takes an argument and a float constant at offset 7`},
+		{`select string_agg(name, age) from people`, `aggragation error: string_agg of age not a constant`},
+		{`select count(id, 1) from people`, `aggragation error: count takes a single argument at offset 7`},
+		{`select name from people order by nosuch`, `schema error: unknown column nosuch at offset 33`},
+		{`select sum(name) over () from people`, `type error: sum of string name at offset 7`},
+		{`select lag(age, 1, "x") over (order by id) from people`, `type error: lag default string "x" of int age at offset 7`},
+		{`select rank() over (partition by nosuch) from people`, `schema error: unknown column nosuch at offset 33`},
+		{`select name from people where age > "x"`, `type error: int age > string "x" at offset 30`},
+		{`select name from people where name = score`, `type error: string name = float score at offset 30`},
+		{`select name from people where age in (1, "x")`, `type error: int age in string "x" at offset 30`},
+		{`select lower(age) from people`, `type error: lower argument 1 is int, not string at offset 7`},
+		{`select id, name || "!" from people where name + 1 > 2`, `type error: string name in name + 1 at offset 41`},
+		{`select -name from people`, `type error: string name in -name at offset 7`},
+		{`select name from people where id in (select name from people)`, `type error: int id in compared with string at offset 30`},
+		{`select id from people union select name from people`, `type error: union column 1 has types int and string at offset 35`},
+		{`update people set age = "old"`, `type error: string "old" assigned to int column age at offset 18`},
+		{`insert into people values (3, 4, 5, 6)`, `type error: int 4 assigned to string column name`},
+	} {
+		_, err := e.Prepare(test.query)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		types []Type
+		rows  [][]interface{}
+	}{
+		{
+			`select name, age * score, age + 1, count(id), sum(age), average(age), max(score) from people group by name, age, score order by name`,
+			[]Type{TypeString, TypeFloat, TypeInt, TypeInt, TypeInt, TypeFloat, TypeFloat},
+			[][]interface{}{{"ann", 45.0, int64(31), int64(1), int64(30), 30.0, 1.5}, {"bob", 6.0, int64(4), int64(1), int64(3), 3.0, 2.0}},
+		},
+		{
+			`select count(distinct age), stddev(score), median(age), array_agg(name), string_agg(id, "-") from people`,
+			[]Type{TypeInt, TypeFloat, TypeFloat, TypeAny, TypeString},
+			[][]interface{}{{int64(2), math.Sqrt(0.125), 16.5, []interface{}{"ann", "bob"}, "1-2"}},
+		},
+		{
+			`select name, row_number() over (order by score desc), lag(score) over (order by id), sum(age) over (order by id) from people order by id`,
+			[]Type{TypeString, TypeInt, TypeFloat, TypeInt},
+			[][]interface{}{{"ann", int64(2), nil, int64(30)}, {"bob", int64(1), 1.5, int64(33)}},
+		},
+		{
+			`select id from people where age > 2.5 and score < 2 and age < score * 25`,
+			[]Type{TypeInt},
+			[][]interface{}{{int64(1)}},
+		},
+		{
+			`select id, upper(name) from people where age = "3" union select 1.5, "x" from people where id in (select id from people)`,
+			[]Type{TypeNumber, TypeString},
+			[][]interface{}{{int64(2), "BOB"}, {1.5, "x"}},
+		},
+	} {
+		rows, err := e.Query(context.Background(), test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows.ColumnTypes(), test.types) {
+			t.Errorf("%s: got types %v, want %v", test.query, rows.ColumnTypes(), test.types)
+		}
+		got, err := readAll(context.Background(), rows.iter)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.rows) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.rows)
+		}
+	}
+}
+
+func Test_TypeCheckWidening(t *testing.T) {
+	e := newTypedEngine(t)
+	stmt, err := e.Prepare(`select age * score from people where age > score and score = 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := stmt.model
+	if b := m.Expressions[0].(*Binary); !reflect.DeepEqual(b.Left, &Cast{X: &Ident{Name: "age"}, Type: TypeFloat}) {
+		t.Errorf("left operand %#v not widened", b.Left)
+	}
+	conds := m.Conditions.(*MultiCondition).SubConditions
+	if c := conds[0].(*SingleCondition); !reflect.DeepEqual(c.Expr, &Cast{X: &Ident{Name: "age"}, Type: TypeFloat}) {
+		t.Errorf("compared field %#v not widened", c.Expr)
+	}
+	if c := conds[1].(*SingleCondition); c.Value != 2.0 {
+		t.Errorf("compared value %#v not widened", c.Value)
+	}
+	if got := m.Format(FormatOptions{}); got != `select age * score from people where age > score and score = 2.0` {
+		t.Errorf("formatted as %s", got)
+	}
+	text, _, err := m.SQL(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT CAST("age" AS DOUBLE PRECISION) * "score" AS "age * score" FROM "people" WHERE CAST("age" AS DOUBLE PRECISION) > "score" AND "score" = $1`; text != want {
+		t.Errorf("got %s, want %s", text, want)
+	}
+}
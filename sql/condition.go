@@ -1,5 +1,10 @@
 package sql
 
+import (
+	"fmt"
+	"regexp"
+)
+
 type ComparatorType int
 
 const (
@@ -10,8 +15,61 @@ const (
 	ComparatorLT
 	ComparatorLTE
 	ComparatorLIKE
+	ComparatorILIKE  // like ignoring case
+	ComparatorREGEXP // Value holds a regular expression matched anywhere in the left side
+	ComparatorNREGEXP
+	ComparatorIN     // Value holds a []interface{}, or Right a subquery
+	ComparatorEXISTS // Right holds a subquery; there is no left side
+)
+
+var (
+	itemType2Comparator = map[itemType]ComparatorType{
+		itemEqual:        ComparatorEQ,
+		itemNotEqual:     ComparatorNEQ,
+		itemGreater:      ComparatorGT,
+		itemGreaterEqual: ComparatorGTE,
+		itemLess:         ComparatorLT,
+		itemLessEqual:    ComparatorLTE,
+		itemLike:         ComparatorLIKE,
+		itemILike:        ComparatorILIKE,
+		itemRegexp:       ComparatorREGEXP,
+		itemNotRegexp:    ComparatorNREGEXP,
+		itemIn:           ComparatorIN,
+	}
 )
 
+var comparatorNames = [...]string{
+	ComparatorEQ:      "=",
+	ComparatorNEQ:     "!=",
+	ComparatorGT:      ">",
+	ComparatorGTE:     ">=",
+	ComparatorLT:      "<",
+	ComparatorLTE:     "<=",
+	ComparatorLIKE:    KeyLike,
+	ComparatorILIKE:   KeyILike,
+	ComparatorREGEXP:  "~",
+	ComparatorNREGEXP: "!~",
+	ComparatorIN:      KeyIn,
+	ComparatorEXISTS:  KeyExists,
+}
+
+// takesPattern reports whether the comparator matches a pattern: like,
+// ilike and the regular expressions.
+func (c ComparatorType) takesPattern() bool {
+	switch c {
+	case ComparatorLIKE, ComparatorILIKE, ComparatorREGEXP, ComparatorNREGEXP:
+		return true
+	}
+	return false
+}
+
+func (c ComparatorType) String() string {
+	if c < 0 || int(c) >= len(comparatorNames) {
+		return "unknown"
+	}
+	return comparatorNames[c]
+}
+
 type LogicType int
 
 const (
@@ -20,15 +78,88 @@ const (
 	LogicNot
 )
 
+var logicNames = [...]string{
+	LogicAnd: KeyAnd,
+	LogicOr:  KeyOr,
+	LogicNot: KeyNot,
+}
+
+func (l LogicType) String() string {
+	if l < 0 || int(l) >= len(logicNames) {
+		return "unknown"
+	}
+	return logicNames[l]
+}
+
 type Condition interface {
 }
 
 type SingleCondition struct {
 	Field      string
+	Expr       Expr // the left side when it is more than a field; Field holds its text
 	Comparator ComparatorType
 	Value      interface{}
+	Right      Expr   // the right side when it is more than a value, such as another field; Value is then nil
+	Escape     string // the escape character of a like pattern, if any
 }
+
+// MultiCondition combines its sub conditions with Logic. A LogicNot
+// condition has exactly one sub condition.
 type MultiCondition struct {
-	SubConditions []*Condition
+	SubConditions []Condition
 	Logic         LogicType
 }
+
+// add appends c to the sub conditions, merging in those of a condition with
+// the same logic.
+func (m *MultiCondition) add(c Condition) {
+	if sub, ok := c.(*MultiCondition); ok && sub.Logic == m.Logic && m.Logic != LogicNot {
+		m.SubConditions = append(m.SubConditions, sub.SubConditions...)
+		return
+	}
+	m.SubConditions = append(m.SubConditions, c)
+}
+
+// left returns the left side of c as an expression.
+func (c *SingleCondition) left() Expr {
+	if c.Expr != nil {
+		return c.Expr
+	}
+	return &Ident{Name: c.Field}
+}
+
+// isLike reports whether c matches a like pattern.
+func (c *SingleCondition) isLike() bool {
+	return c.Comparator == ComparatorLIKE || c.Comparator == ComparatorILIKE
+}
+
+// like returns the compiled pattern of a like condition.
+func (c *SingleCondition) like() (*Like, error) {
+	return CompileLike(fmt.Sprint(c.Value), c.Escape, c.Comparator == ComparatorILIKE)
+}
+
+// regexp returns the compiled regular expression of a regexp condition.
+func (c *SingleCondition) regexp() (*regexp.Regexp, error) {
+	return regexp.Compile(fmt.Sprint(c.Value))
+}
+
+// checkPattern reports an error when the pattern of c does not compile.
+func (c *SingleCondition) checkPattern() error {
+	var err error
+	if c.isLike() {
+		_, err = c.like()
+	} else {
+		_, err = c.regexp()
+	}
+	return err
+}
+
+// isCondition reports whether c is a condition rather than an expression
+// parsed within parens.
+func isCondition(c Condition) bool {
+	switch c.(type) {
+	case *SingleCondition, *MultiCondition:
+		return true
+	}
+	return false
+}
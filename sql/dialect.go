@@ -0,0 +1,777 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect is the SQL of a database the model can be rendered to.
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota
+	DialectMySQL
+	DialectSQLite
+)
+
+var dialectNames = map[Dialect]string{
+	DialectPostgres: "postgres",
+	DialectMySQL:    "mysql",
+	DialectSQLite:   "sqlite",
+}
+
+func (d Dialect) String() string {
+	if name, ok := dialectNames[d]; ok {
+		return name
+	}
+	return "dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// quote quotes an identifier, quoting each part of a dotted one.
+func (d Dialect) quote(name string) string {
+	parts := strings.Split(name, MarkDot)
+	for i, part := range parts {
+		parts[i] = d.quoteName(part)
+	}
+	return strings.Join(parts, MarkDot)
+}
+
+// quoteName quotes name as a whole, as column aliases are.
+func (d Dialect) quoteName(name string) string {
+	q := `"`
+	if d == DialectMySQL {
+		q = "`"
+	}
+	return q + strings.Replace(name, q, q+q, -1) + q
+}
+
+// placeholder returns the parameter marker of the n-th argument, from 1.
+func (d Dialect) placeholder(n int) string {
+	if d == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// ToSQL parses query, binds args to its positional placeholders and renders
+// it in the dialect d.
+func ToSQL(d Dialect, query string, args ...interface{}) (string, []interface{}, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return "", nil, err
+	}
+	m, err := p.Bind(args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.SQL(d)
+}
+
+// SQL renders the model in the dialect d. Values compared with and strings
+// become arguments of the returned slice, so the text only holds quoted
+// identifiers, keywords, numbers and parameter markers; subqueries add
+// their arguments in text order. Like patterns keep their meaning: they are
+// escaped for the backslash escape of PostgreSQL and MySQL, and SQLite,
+// whose like ignores case, gets the equivalent glob instead, keeping its
+// like for ilike. Under MySQL, case sensitivity of like follows the
+// collation of the column, and ilike lowers both sides. Regular
+// expressions are passed on as they are. Timestamps are arguments; MySQL
+// adds an interval unit by unit and SQLite, which has no intervals, moves a
+// timestamp with DATETIME, so that an interval there must be added to or
+// subtracted from a timestamp. Aggragations a dialect lacks, such as the
+// percentiles of MySQL and SQLite, are errors. Placeholders must be bound
+// first.
+func (m *model) SQL(d Dialect) (string, []interface{}, error) {
+	r := sqlRenderer{dialect: d}
+	s, err := r.query(m)
+	if err == nil {
+		err = r.err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return s, r.args, nil
+}
+
+// query renders m, which may be a subquery. The order by of a query with
+// set operators refers to the columns by their position, since their names
+// are those of the first query.
+func (r *sqlRenderer) query(m *model) (string, error) {
+	if err := m.checkCounts(); err != nil {
+		return "", err
+	}
+	if m.Type != SqlSelect {
+		return r.statement(m)
+	}
+	s, err := r.selectQuery(m)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(s)
+	for i, op := range m.SetOps {
+		if err := r.checkSetOp(m, i); err != nil {
+			return "", err
+		}
+		s, err := r.selectQuery(op.Query)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" " + strings.ToUpper(op.String()) + " " + s)
+	}
+	if len(m.OrderBy) > 0 {
+		items := make([]string, len(m.OrderBy))
+		for i, o := range m.OrderBy {
+			if len(m.SetOps) > 0 {
+				items[i] = strconv.Itoa(indexOf(m.Columns, o.Column) + 1)
+			} else {
+				items[i] = r.expr(m, o.Column)
+			}
+			if o.Desc {
+				items[i] += " DESC"
+			}
+		}
+		b.WriteString(" ORDER BY " + strings.Join(items, ", "))
+	}
+	b.WriteString(m.sqlLimit(r.dialect))
+	return b.String(), nil
+}
+
+// statement renders an insert, update, delete or create table.
+func (r *sqlRenderer) statement(m *model) (string, error) {
+	table := r.dialect.quote(m.TableName)
+	switch m.Type {
+	case SqlInsert:
+		var b strings.Builder
+		b.WriteString("INSERT INTO " + table)
+		if m.Values[0][0].Column != "" {
+			columns := make([]string, len(m.Values[0]))
+			for i, a := range m.Values[0] {
+				columns[i] = r.dialect.quote(a.Column)
+			}
+			b.WriteString(" (" + strings.Join(columns, ", ") + ")")
+		}
+		b.WriteString(" VALUES ")
+		for i, row := range m.Values {
+			values, err := r.assignments(row)
+			if err != nil {
+				return "", err
+			}
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(" + strings.Join(values, ", ") + ")")
+		}
+		return b.String(), nil
+	case SqlUpdate:
+		values, err := r.assignments(m.Set)
+		if err != nil {
+			return "", err
+		}
+		for i, a := range m.Set {
+			values[i] = r.dialect.quote(a.Column) + " = " + values[i]
+		}
+		return r.where(m, "UPDATE "+table+" SET "+strings.Join(values, ", "))
+	case SqlDelete:
+		return r.where(m, "DELETE FROM "+table)
+	case SqlCreate:
+		columns := make([]string, len(m.Schema))
+		for i, c := range m.Schema {
+			typ, ok := r.dialect.columnType(c.Type)
+			if !ok {
+				return "", fmt.Errorf("%s has no column type %s", r.dialect, c.Type)
+			}
+			columns[i] = strings.TrimSpace(r.dialect.quote(c.Name) + " " + typ)
+		}
+		return "CREATE TABLE " + table + " (" + strings.Join(columns, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("%s not supported", m.Type)
+}
+
+// columnTypes are the column types of create table by dialect. SQLite
+// leaves the column of any value without a type.
+var columnTypes = map[Dialect]map[Type]string{
+	DialectPostgres: {
+		TypeInt:       "BIGINT",
+		TypeFloat:     "DOUBLE PRECISION",
+		TypeNumber:    "NUMERIC",
+		TypeString:    "TEXT",
+		TypeBool:      "BOOLEAN",
+		TypeTimestamp: "TIMESTAMP",
+		TypeInterval:  "INTERVAL",
+	},
+	DialectMySQL: {
+		TypeInt:       "BIGINT",
+		TypeFloat:     "DOUBLE",
+		TypeNumber:    "DECIMAL(65,30)",
+		TypeString:    "TEXT",
+		TypeBool:      "BOOLEAN",
+		TypeTimestamp: "DATETIME(6)",
+		TypeInterval:  "TEXT",
+	},
+	DialectSQLite: {
+		TypeAny:       "",
+		TypeInt:       "INTEGER",
+		TypeFloat:     "REAL",
+		TypeNumber:    "NUMERIC",
+		TypeString:    "TEXT",
+		TypeBool:      "BOOLEAN",
+		TypeTimestamp: "TIMESTAMP",
+		TypeInterval:  "TEXT",
+	},
+}
+
+func (d Dialect) columnType(t Type) (string, bool) {
+	typ, ok := columnTypes[d][t]
+	return typ, ok
+}
+
+// assignments renders the values of list, which become arguments unless
+// they are expressions.
+func (r *sqlRenderer) assignments(list []Assignment) ([]string, error) {
+	values := make([]string, len(list))
+	for i, a := range list {
+		if a.Expr != nil {
+			values[i] = r.render(a.Expr)
+			continue
+		}
+		if err := checkBound(a.Value); err != nil {
+			return nil, err
+		}
+		values[i] = r.arg(a.Value)
+	}
+	return values, nil
+}
+
+// where appends the where clause of m to s.
+func (r *sqlRenderer) where(m *model, s string) (string, error) {
+	if m.Conditions == nil {
+		return s, nil
+	}
+	where, err := r.condition(m.Conditions)
+	if err != nil {
+		return "", err
+	}
+	return s + " WHERE " + where, nil
+}
+
+// checkSetOp reports the i-th set operator of m when the dialect lacks it.
+// SQLite applies its set operators from left to right, so an intersect
+// there must not follow the others.
+func (r *sqlRenderer) checkSetOp(m *model, i int) error {
+	op := m.SetOps[i]
+	if r.dialect != DialectSQLite || op.Type == SetUnion {
+		return nil
+	}
+	if op.All {
+		return fmt.Errorf("%s not supported by %s", op, r.dialect)
+	}
+	if op.Type != SetIntersect {
+		return nil
+	}
+	for _, before := range m.SetOps[:i] {
+		if before.Type != SetIntersect {
+			return fmt.Errorf("%s after %s not supported by %s", op, before, r.dialect)
+		}
+	}
+	return nil
+}
+
+// selectQuery renders the clauses of m up to its group by.
+func (r *sqlRenderer) selectQuery(m *model) (string, error) {
+	d := r.dialect
+	var b strings.Builder
+	columns := make([]string, len(m.Columns))
+	for i, c := range m.Columns {
+		columns[i] = r.column(m, c)
+	}
+	b.WriteString("SELECT ")
+	if m.Distinct {
+		b.WriteString("DISTINCT ")
+	}
+	b.WriteString(strings.Join(columns, ", "))
+	b.WriteString(" FROM " + r.table(m.TableName, m.Alias))
+	for _, j := range m.Joins {
+		b.WriteString(" " + strings.ToUpper(j.Type.String()) + " " + r.table(j.Table, j.Alias))
+		if j.On != nil {
+			on, err := r.condition(j.On)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(" ON " + on)
+		}
+	}
+	if m.Conditions != nil {
+		where, err := r.condition(m.Conditions)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" WHERE " + where)
+	}
+	if len(m.GroupBy) > 0 {
+		fields := make([]string, len(m.GroupBy))
+		for i, f := range m.GroupBy {
+			fields[i] = d.quote(f)
+		}
+		b.WriteString(" GROUP BY " + strings.Join(fields, ", "))
+	}
+	return b.String(), nil
+}
+
+func (r *sqlRenderer) table(name, alias string) string {
+	if alias == "" {
+		return r.dialect.quote(name)
+	}
+	return r.dialect.quote(name) + " AS " + r.dialect.quoteName(alias)
+}
+
+// sqlLimit renders limit and offset. MySQL and SQLite only take an offset
+// after a limit, so a lone offset gets the largest limit they accept.
+func (m *model) sqlLimit(d Dialect) string {
+	limit := ""
+	if m.Limit >= 0 {
+		limit = strconv.Itoa(m.Limit)
+	} else if m.Offset > 0 && d == DialectMySQL {
+		limit = "18446744073709551615"
+	} else if m.Offset > 0 && d == DialectSQLite {
+		limit = "-1"
+	}
+	var s string
+	if limit != "" {
+		s = " LIMIT " + limit
+	}
+	if m.Offset > 0 {
+		s += " OFFSET " + strconv.Itoa(m.Offset)
+	}
+	return s
+}
+
+// sqlRenderer collects the arguments of the rendered text.
+type sqlRenderer struct {
+	dialect Dialect
+	args    []interface{}
+	err     error // the first expression the dialect cannot render
+}
+
+func (r *sqlRenderer) arg(v interface{}) string {
+	r.args = append(r.args, v)
+	return r.dialect.placeholder(len(r.args))
+}
+
+var sqlFunction = map[AggType]string{
+	AggCount:          "COUNT",
+	AggSum:            "SUM",
+	AggAverage:        "AVG",
+	AggMin:            "MIN",
+	AggMax:            "MAX",
+	AggDistinct:       "COUNT",
+	AggApproxDistinct: "COUNT",
+}
+
+// sqlAggragations holds the aggragations a dialect has beyond those of
+// sqlFunction. Percentiles are continuous ones, approximate ones included.
+var sqlAggragations = map[Dialect]map[AggType]string{
+	DialectPostgres: {
+		AggMedian:           "PERCENTILE_CONT",
+		AggPercentile:       "PERCENTILE_CONT",
+		AggApproxPercentile: "PERCENTILE_CONT",
+		AggStddev:           "STDDEV_SAMP",
+		AggVariance:         "VAR_SAMP",
+		AggArray:            "ARRAY_AGG",
+		AggStringAgg:        "STRING_AGG",
+	},
+	DialectMySQL: {
+		AggStddev:    "STDDEV_SAMP",
+		AggVariance:  "VAR_SAMP",
+		AggArray:     "JSON_ARRAYAGG",
+		AggStringAgg: "GROUP_CONCAT",
+	},
+	DialectSQLite: {
+		AggArray:     "JSON_GROUP_ARRAY",
+		AggStringAgg: "GROUP_CONCAT",
+	},
+}
+
+// expr returns the expression of a column: a field, an expression, an
+// aggragation or a window.
+func (r *sqlRenderer) expr(m *model, column string) string {
+	for _, agg := range m.Aggragations.Items {
+		if agg.String() != column {
+			continue
+		}
+		return r.aggragation(agg)
+	}
+	for _, e := range m.Expressions {
+		if e.String() == column {
+			return r.render(e)
+		}
+	}
+	for _, w := range m.Windows {
+		if w.String() == column {
+			return r.window(m, w)
+		}
+	}
+	return r.dialect.quote(column)
+}
+
+// window renders w, whose partitions and orders are columns of m.
+func (r *sqlRenderer) window(m *model, w *Window) string {
+	var call string
+	if w.Func == "" {
+		call = r.aggragation(w.Agg)
+	} else {
+		call = r.render(&Call{Name: w.Func, Args: w.Args})
+	}
+	var over []string
+	if len(w.PartitionBy) > 0 {
+		items := make([]string, len(w.PartitionBy))
+		for i, c := range w.PartitionBy {
+			items[i] = r.expr(m, c)
+		}
+		over = append(over, "PARTITION BY "+strings.Join(items, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		items := make([]string, len(w.OrderBy))
+		for i, o := range w.OrderBy {
+			items[i] = r.expr(m, o.Column)
+			if o.Desc {
+				items[i] += " DESC"
+			}
+		}
+		over = append(over, "ORDER BY "+strings.Join(items, ", "))
+	}
+	return call + " OVER (" + strings.Join(over, " ") + ")"
+}
+
+// aggragation renders agg. Approximate distinct counts are exact ones.
+func (r *sqlRenderer) aggragation(agg aggItem) string {
+	name, ok := sqlAggragations[r.dialect][agg.Agg]
+	if !ok {
+		name, ok = sqlFunction[agg.Agg]
+	}
+	percentile := name == "PERCENTILE_CONT"
+	if !ok || agg.Distinct && (percentile || agg.Agg == AggStringAgg && r.dialect == DialectSQLite) {
+		if r.err == nil {
+			r.err = fmt.Errorf("aggragation %s not supported by %s", agg, r.dialect)
+		}
+		return ""
+	}
+	if err := checkBound(agg.Params); err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return ""
+	}
+	arg := r.dialect.quote(agg.Field)
+	if agg.Expr != nil {
+		arg = r.render(agg.Expr)
+	}
+	if agg.Distinct || agg.distinctCount() {
+		arg = "DISTINCT " + arg
+	}
+	switch {
+	case percentile:
+		fraction := 0.5
+		if agg.Agg != AggMedian {
+			fraction = agg.Params[0].(float64)
+		}
+		return name + "(" + strconv.FormatFloat(fraction, 'g', -1, 64) + ") WITHIN GROUP (ORDER BY " + arg + ")"
+	case agg.Agg == AggStringAgg && r.dialect == DialectMySQL:
+		// the separator of MySQL is a literal
+		sep := strings.NewReplacer(`\`, `\\`, "'", "''").Replace(agg.Params[0].(string))
+		return name + "(" + arg + " SEPARATOR '" + sep + "')"
+	case agg.Agg == AggStringAgg:
+		return name + "(" + arg + ", " + r.arg(agg.Params[0]) + ")"
+	}
+	return name + "(" + arg + ")"
+}
+
+// column returns the select item of a column, naming computed ones after it.
+func (r *sqlRenderer) column(m *model, column string) string {
+	if contains(m.Fields, column) {
+		return r.dialect.quote(column)
+	}
+	return r.expr(m, column) + " AS " + r.dialect.quoteName(column)
+}
+
+// interval renders an interval literal. MySQL takes one of a single unit.
+func (r *sqlRenderer) interval(iv Interval) string {
+	if r.dialect == DialectPostgres {
+		return "INTERVAL '" + iv.String() + "'"
+	}
+	if terms := mysqlInterval(iv); r.dialect == DialectMySQL && len(terms) == 1 {
+		return terms[0]
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf("interval %s not supported by %s but added to a timestamp", iv, r.dialect)
+	}
+	return ""
+}
+
+// moveTime renders the timestamp of e moved by its interval literal for
+// MySQL and SQLite, and reports whether e is such an expression.
+func (r *sqlRenderer) moveTime(e *Binary) (string, bool) {
+	if r.dialect == DialectPostgres || e.Op != OpAdd && e.Op != OpSub {
+		return "", false
+	}
+	x, lit := e.Left, e.Right
+	if l, ok := e.Left.(*Literal); ok && e.Op == OpAdd {
+		if _, ok := l.Value.(Interval); ok {
+			x, lit = e.Right, e.Left
+		}
+	}
+	l, ok := lit.(*Literal)
+	if !ok {
+		return "", false
+	}
+	iv, ok := l.Value.(Interval)
+	if !ok {
+		return "", false
+	}
+	if e.Op == OpSub {
+		iv = iv.neg()
+	}
+	t := r.render(x)
+	if r.dialect == DialectSQLite {
+		args := []string{t}
+		if iv.Months != 0 {
+			args = append(args, fmt.Sprintf("'%+d months'", iv.Months))
+		}
+		if iv.Days != 0 {
+			args = append(args, fmt.Sprintf("'%+d days'", iv.Days))
+		}
+		if iv.Duration != 0 {
+			sign := "+"
+			if iv.Duration < 0 {
+				sign = ""
+			}
+			args = append(args, "'"+sign+strconv.FormatFloat(iv.Duration.Seconds(), 'f', -1, 64)+" seconds'")
+		}
+		return "DATETIME(" + strings.Join(args, ", ") + ")", true
+	}
+	if exprPrec(x) < OpAdd.prec() {
+		t = "(" + t + ")"
+	}
+	return strings.Join(append([]string{t}, mysqlInterval(iv)...), " + "), true
+}
+
+// mysqlInterval returns the terms of MySQL adding up to iv, one per unit.
+func mysqlInterval(iv Interval) []string {
+	var terms []string
+	if iv.Months != 0 {
+		terms = append(terms, fmt.Sprintf("INTERVAL %d MONTH", iv.Months))
+	}
+	if iv.Days != 0 {
+		terms = append(terms, fmt.Sprintf("INTERVAL %d DAY", iv.Days))
+	}
+	if d := iv.Duration; d%time.Second != 0 {
+		terms = append(terms, fmt.Sprintf("INTERVAL %d MICROSECOND", d/time.Microsecond))
+	} else if d != 0 || len(terms) == 0 {
+		terms = append(terms, fmt.Sprintf("INTERVAL %d SECOND", d/time.Second))
+	}
+	return terms
+}
+
+// sqlFunctionNames holds the functions named differently by a dialect.
+// Other functions, registered ones included, keep their name.
+var sqlFunctionNames = map[Dialect]map[string]string{
+	DialectMySQL: {"length": "CHAR_LENGTH"},
+}
+
+// render renders e. Numbers, bools and nulls are written out; strings
+// become arguments.
+func (r *sqlRenderer) render(e Expr) string {
+	switch e := e.(type) {
+	case *Ident:
+		return r.dialect.quote(e.Name)
+	case *Literal:
+		switch v := e.Value.(type) {
+		case string:
+			return r.arg(v)
+		case bool:
+			return strings.ToUpper(strconv.FormatBool(v))
+		case nil:
+			return "NULL"
+		case time.Time:
+			return r.arg(v)
+		case Interval:
+			return r.interval(v)
+		case Placeholder:
+			if r.err == nil {
+				r.err = checkBound(v)
+			}
+		}
+		return formatValue(e.Value)
+	case *Call:
+		if e.Name == "now" && r.dialect == DialectSQLite {
+			return "CURRENT_TIMESTAMP"
+		}
+		name, ok := sqlFunctionNames[r.dialect][e.Name]
+		if !ok {
+			name = strings.ToUpper(e.Name)
+		}
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = r.render(arg)
+		}
+		return name + "(" + strings.Join(args, ", ") + ")"
+	case *Binary:
+		if s, ok := r.moveTime(e); ok {
+			return s
+		}
+		left, right := r.render(e.Left), r.render(e.Right)
+		if e.Op == OpConcat && r.dialect == DialectMySQL {
+			// || is a logical or in MySQL
+			return "CONCAT(" + left + ", " + right + ")"
+		}
+		if exprPrec(e.Left) < e.Op.prec() {
+			left = "(" + left + ")"
+		}
+		if exprPrec(e.Right) <= e.Op.prec() {
+			right = "(" + right + ")"
+		}
+		return left + " " + e.Op.String() + " " + right
+	case *Unary:
+		if l, ok := e.X.(*Literal); ok {
+			if iv, ok := l.Value.(Interval); ok {
+				return r.interval(iv.neg())
+			}
+		}
+		x := r.render(e.X)
+		if exprPrec(e.X) < e.Op.prec() || strings.HasPrefix(x, "-") {
+			x = "(" + x + ")"
+		}
+		return "-" + x
+	case *Cast:
+		typ, ok := r.dialect.columnType(e.Type)
+		if !ok || typ == "" {
+			return r.render(e.X)
+		}
+		return "CAST(" + r.render(e.X) + " AS " + typ + ")"
+	}
+	return e.String()
+}
+
+var sqlComparator = map[ComparatorType]string{
+	ComparatorEQ:  "=",
+	ComparatorNEQ: "<>",
+	ComparatorGT:  ">",
+	ComparatorGTE: ">=",
+	ComparatorLT:  "<",
+	ComparatorLTE: "<=",
+}
+
+func (r *sqlRenderer) condition(c Condition) (string, error) {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if err := checkBound(c.Value); err != nil {
+			return "", err
+		}
+		field := r.dialect.quote(c.Field)
+		if c.Expr != nil {
+			field = r.render(c.Expr)
+		}
+		if sub, ok := c.Right.(*Subquery); ok {
+			query, err := r.query(sub.Query)
+			if err != nil {
+				return "", err
+			}
+			switch c.Comparator {
+			case ComparatorEXISTS:
+				return "EXISTS (" + query + ")", nil
+			case ComparatorIN:
+				return field + " IN (" + query + ")", nil
+			}
+			if op, ok := sqlComparator[c.Comparator]; ok {
+				return field + " " + op + " (" + query + ")", nil
+			}
+			return "", fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		switch c.Comparator {
+		case ComparatorIN:
+			list, _ := c.Value.([]interface{})
+			items := make([]string, len(list))
+			for i, v := range list {
+				items[i] = r.arg(v)
+			}
+			return field + " IN (" + strings.Join(items, ", ") + ")", nil
+		case ComparatorLIKE, ComparatorILIKE:
+			like, err := c.like()
+			if err != nil {
+				return "", err
+			}
+			return r.like(field, like), nil
+		case ComparatorREGEXP, ComparatorNREGEXP:
+			return r.regexp(field, c.Comparator == ComparatorNREGEXP, c.Value), nil
+		}
+		op, ok := sqlComparator[c.Comparator]
+		if !ok {
+			return "", fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		if c.Right != nil {
+			return field + " " + op + " " + r.render(c.Right), nil
+		}
+		if c.Value == nil && c.Comparator == ComparatorEQ {
+			return field + " IS NULL", nil
+		}
+		if c.Value == nil && c.Comparator == ComparatorNEQ {
+			return field + " IS NOT NULL", nil
+		}
+		return field + " " + op + " " + r.arg(c.Value), nil
+	case *MultiCondition:
+		subs := make([]string, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			s, err := r.condition(sub)
+			if err != nil {
+				return "", err
+			}
+			if conditionPrec(sub) < conditionPrec(c) {
+				s = "(" + s + ")"
+			}
+			subs[i] = s
+		}
+		if c.Logic == LogicNot {
+			return "NOT " + subs[0], nil
+		}
+		return strings.Join(subs, " "+strings.ToUpper(c.Logic.String())+" "), nil
+	}
+	return "", fmt.Errorf("unknown condition %T", c)
+}
+
+// like renders the match of field against like. PostgreSQL and MySQL take
+// the pattern with the backslash escape; MySQL lowers both sides to ignore
+// case. SQLite globs for a like, as its like ignores case, and uses its
+// like for an ilike.
+func (r *sqlRenderer) like(field string, like *Like) string {
+	pattern := like.escaped(`\`)
+	switch {
+	case r.dialect == DialectSQLite && like.Fold:
+		return field + " LIKE " + r.arg(pattern) + ` ESCAPE '\'`
+	case r.dialect == DialectSQLite:
+		return field + " GLOB " + r.arg(like.glob())
+	case r.dialect == DialectPostgres && like.Fold:
+		return field + " ILIKE " + r.arg(pattern)
+	case like.Fold:
+		return "LOWER(" + field + ") LIKE " + r.arg(strings.ToLower(pattern))
+	}
+	return field + " LIKE " + r.arg(pattern)
+}
+
+// regexp renders the match of field against the regular expression
+// pattern, negated when not is set. MySQL and SQLite take the REGEXP
+// operator, which SQLite leaves to a function the application defines.
+func (r *sqlRenderer) regexp(field string, not bool, pattern interface{}) string {
+	op := " REGEXP "
+	switch {
+	case r.dialect == DialectPostgres && not:
+		op = " !~ "
+	case r.dialect == DialectPostgres:
+		op = " ~ "
+	case not:
+		op = " NOT REGEXP "
+	}
+	return field + op + r.arg(pattern)
+}
@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// dialectArgs are bound to the placeholders of the queries in
+// testdata/dialect/queries.sql.
+var dialectArgs = []interface{}{21, "b%", nil}
+
+func Test_Dialect(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "dialect", "queries.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var queries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			queries = append(queries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []Dialect{DialectPostgres, DialectMySQL, DialectSQLite} {
+		var got bytes.Buffer
+		for _, query := range queries {
+			p := NewParse(query)
+			p.Generate()
+			if err := p.Err(); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			m, err := p.Bind(dialectArgs[:p.Placeholders]...)
+			if err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			text, args, err := m.SQL(d)
+			if err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			fmt.Fprintf(&got, "-- %s\n%s\n%#v\n\n", query, text, args)
+		}
+		golden := filepath.Join("testdata", "dialect", d.String()+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, got.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%s: output differs from %s:\n%s", d, golden, got.Bytes())
+		}
+	}
+}
+
+func Test_ToSQL(t *testing.T) {
+	text, args, err := ToSQL(DialectPostgres, `select name where age > $1 limit $2`, 18, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT "name" FROM "graph" WHERE "age" > $1 LIMIT 5`; text != want {
+		t.Errorf("got  %s\nwant %s", text, want)
+	}
+	if len(args) != 1 || args[0] != int64(18) {
+		t.Errorf("got args %#v", args)
+	}
+	if _, _, err := ToSQL(DialectMySQL, `select name where age > ?`); err == nil {
+		t.Error("expected error for missing argument")
+	}
+	for _, query := range []string{
+		`select name union select name from people intersect select name from bans`,
+		`select name except all select name from bans`,
+	} {
+		if _, _, err := ToSQL(DialectSQLite, query); err == nil {
+			t.Errorf("%s: expected error for sqlite", query)
+		}
+	}
+
+	query := `select median(age), percentile(age, 0.9), stddev(age), variance(age)`
+	text, _, err = ToSQL(DialectPostgres, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY "age") AS "median(age)", PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY "age") AS "percentile(age, 0.9)", STDDEV_SAMP("age") AS "stddev(age)", VAR_SAMP("age") AS "variance(age)" FROM "graph"`; text != want {
+		t.Errorf("got  %s\nwant %s", text, want)
+	}
+	for _, d := range []Dialect{DialectMySQL, DialectSQLite} {
+		if _, _, err := ToSQL(d, query); err == nil {
+			t.Errorf("%s: expected error for %s", query, d)
+		}
+	}
+}
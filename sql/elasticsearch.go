@@ -0,0 +1,261 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// esTermsSize is the number of buckets asked of a terms aggregation when
+// the query has no limit.
+const esTermsSize = 10000
+
+// ToElasticsearch parses query and returns the JSON body of the equivalent
+// Elasticsearch search request.
+func ToElasticsearch(query string) ([]byte, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	body, err := p.Elasticsearch()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+// Elasticsearch translates the model to the body of a search request on the
+// index named by the table. Conditions become bool, term, terms, range and
+// wildcard queries. A query with aggragations asks for no hits: group by
+// fields become nested terms aggregations holding the metric aggragations,
+// and order by, limit and offset apply to the buckets. Otherwise the fields
+// select the _source of the hits, sorted by order by and paged by limit and
+// offset. The fields of a select distinct become terms aggregations.
+// Placeholders must be bound first.
+func (m *model) Elasticsearch() (map[string]interface{}, error) {
+	if err := m.checkCounts(); err != nil {
+		return nil, err
+	}
+	if err := m.plainFields(); err != nil {
+		return nil, err
+	}
+	m, err := m.distinctGroups()
+	if err != nil {
+		return nil, err
+	}
+	query, err := esCondition(m.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{"query": query}
+	if !m.aggragates() {
+		if len(m.Fields) > 0 {
+			body["_source"] = m.Fields
+		}
+		if len(m.OrderBy) > 0 {
+			sort := make([]interface{}, len(m.OrderBy))
+			for i, o := range m.OrderBy {
+				sort[i] = map[string]interface{}{o.Column: map[string]interface{}{"order": esOrder(o)}}
+			}
+			body["sort"] = sort
+		}
+		if m.Offset > 0 {
+			body["from"] = m.Offset
+		}
+		if m.Limit >= 0 {
+			body["size"] = m.Limit
+		}
+		return body, nil
+	}
+
+	if m.Offset > 0 && len(m.GroupBy) == 0 {
+		return nil, fmt.Errorf("offset needs group by buckets to skip")
+	}
+	body["size"] = 0
+	aggs := make(map[string]interface{})
+	for _, agg := range m.Aggragations.Items {
+		metric, err := esAggragation(agg)
+		if err != nil {
+			return nil, err
+		}
+		aggs[agg.String()] = metric
+	}
+	// Build the terms aggregations from the innermost group outwards. Orders
+	// on aggragations apply to the innermost buckets, which hold the metrics.
+	for i := len(m.GroupBy) - 1; i >= 0; i-- {
+		field := m.GroupBy[i]
+		size := esTermsSize
+		if i == 0 && m.Limit >= 0 {
+			size = m.Offset + m.Limit
+		}
+		terms := map[string]interface{}{"field": field, "size": size}
+		var order []interface{}
+		for _, o := range m.OrderBy {
+			if o.Column == field {
+				order = append(order, map[string]interface{}{"_key": esOrder(o)})
+			} else if i == len(m.GroupBy)-1 && !contains(m.GroupBy, o.Column) {
+				order = append(order, map[string]interface{}{o.Column: esOrder(o)})
+			}
+		}
+		if len(order) > 0 {
+			terms["order"] = order
+		}
+		bucket := map[string]interface{}{"terms": terms}
+		if i == 0 && m.Offset > 0 {
+			// The limit already caps the buckets, bucket_sort skips the offset.
+			aggs[KeyOffset] = map[string]interface{}{"bucket_sort": map[string]interface{}{"from": m.Offset}}
+		}
+		if len(aggs) > 0 {
+			bucket["aggs"] = aggs
+		}
+		aggs = map[string]interface{}{field: bucket}
+	}
+	if len(aggs) > 0 {
+		body["aggs"] = aggs
+	}
+	return body, nil
+}
+
+var esMetric = map[AggType]string{
+	AggCount:            "value_count",
+	AggSum:              "sum",
+	AggAverage:          "avg",
+	AggMin:              "min",
+	AggMax:              "max",
+	AggDistinct:         "cardinality",
+	AggApproxDistinct:   "cardinality",
+	AggMedian:           "percentiles",
+	AggPercentile:       "percentiles",
+	AggApproxPercentile: "percentiles",
+}
+
+// esAggragation returns the metric aggregation of agg. Distinct counts and
+// percentiles are the approximate ones of Elasticsearch.
+func esAggragation(agg aggItem) (map[string]interface{}, error) {
+	if err := checkBound(agg.Params); err != nil {
+		return nil, err
+	}
+	metric, ok := esMetric[agg.Agg]
+	if agg.distinctCount() {
+		metric, ok = esMetric[AggDistinct], true
+	} else if agg.Distinct {
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("aggragation %s not supported", agg)
+	}
+	body := map[string]interface{}{"field": agg.Field}
+	switch agg.Agg {
+	case AggMedian:
+		body["percents"] = []interface{}{50.0}
+	case AggPercentile, AggApproxPercentile:
+		body["percents"] = []interface{}{agg.Params[0].(float64) * 100}
+	}
+	return map[string]interface{}{metric: body}, nil
+}
+
+func esOrder(o orderItem) string {
+	if o.Desc {
+		return KeyDesc
+	}
+	return KeyAsc
+}
+
+var esRange = map[ComparatorType]string{
+	ComparatorGT:  "gt",
+	ComparatorGTE: "gte",
+	ComparatorLT:  "lt",
+	ComparatorLTE: "lte",
+}
+
+func esCondition(c Condition) (map[string]interface{}, error) {
+	switch c := c.(type) {
+	case nil:
+		return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+	case *SingleCondition:
+		if err := checkBound(c.Value); err != nil {
+			return nil, err
+		}
+		switch c.Comparator {
+		case ComparatorEQ:
+			return esTerm(c.Field, c.Value), nil
+		case ComparatorNEQ:
+			return esBool("must_not", esTerm(c.Field, c.Value)), nil
+		case ComparatorLIKE, ComparatorILIKE:
+			like, err := c.like()
+			if err != nil {
+				return nil, err
+			}
+			query := map[string]interface{}{"value": like.wildcard()}
+			if like.Fold {
+				query["case_insensitive"] = true
+			}
+			return map[string]interface{}{"wildcard": map[string]interface{}{c.Field: query}}, nil
+		case ComparatorREGEXP:
+			return esRegexp(c.Field, fmt.Sprint(c.Value)), nil
+		case ComparatorNREGEXP:
+			return esBool("must_not", esRegexp(c.Field, fmt.Sprint(c.Value))), nil
+		case ComparatorIN:
+			return map[string]interface{}{"terms": map[string]interface{}{c.Field: c.Value}}, nil
+		}
+		op, ok := esRange[c.Comparator]
+		if !ok {
+			return nil, fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		return map[string]interface{}{"range": map[string]interface{}{
+			c.Field: map[string]interface{}{op: c.Value},
+		}}, nil
+	case *MultiCondition:
+		subs := make([]interface{}, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			q, err := esCondition(sub)
+			if err != nil {
+				return nil, err
+			}
+			subs[i] = q
+		}
+		switch c.Logic {
+		case LogicAnd:
+			return esBool("filter", subs...), nil
+		case LogicOr:
+			q := esBool("should", subs...)
+			q["bool"].(map[string]interface{})["minimum_should_match"] = 1
+			return q, nil
+		case LogicNot:
+			return esBool("must_not", subs...), nil
+		}
+		return nil, fmt.Errorf("unknown logic %d", c.Logic)
+	}
+	return nil, fmt.Errorf("unknown condition %T", c)
+}
+
+func esTerm(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
+
+// esRegexp returns a regexp query matching pattern anywhere in field. The
+// regular expressions of Lucene match whole terms and have no anchors, so
+// an anchor is dropped and an unanchored end gets ".*". Other syntax is
+// passed on as it is.
+func esRegexp(field, pattern string) map[string]interface{} {
+	if strings.HasPrefix(pattern, "^") {
+		pattern = pattern[1:]
+	} else {
+		pattern = ".*" + pattern
+	}
+	// the end is an anchor unless an odd number of backslashes escape it
+	body := strings.TrimSuffix(pattern, "$")
+	if body != pattern && (len(body)-len(strings.TrimRight(body, `\`)))%2 == 0 {
+		pattern = body
+	} else {
+		pattern += ".*"
+	}
+	return map[string]interface{}{"regexp": map[string]interface{}{
+		field: map[string]interface{}{"value": pattern},
+	}}
+}
+
+func esBool(occur string, queries ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{occur: queries}}
+}
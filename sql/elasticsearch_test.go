@@ -0,0 +1,121 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+type esTest struct {
+	query string
+	json  string
+}
+
+var esTests = []esTest{
+	{
+		`select name, age from logs`,
+		`{"_source": ["name", "age"], "query": {"match_all": {}}}`,
+	},
+	{
+		`select name where age >= 18 and (region = "cn" or region != "us") and not name like "a_b%*" order by age desc limit 5`,
+		`{
+			"_source": ["name"],
+			"query": {"bool": {"filter": [
+				{"range": {"age": {"gte": 18}}},
+				{"bool": {"minimum_should_match": 1, "should": [
+					{"term": {"region": "cn"}},
+					{"bool": {"must_not": [{"term": {"region": "us"}}]}}
+				]}},
+				{"bool": {"must_not": [{"wildcard": {"name": {"value": "a?b*\\*"}}}]}}
+			]}},
+			"size": 5,
+			"sort": [{"age": {"order": "desc"}}]
+		}`,
+	},
+	{
+		`select status where code in (500, 503)`,
+		`{"_source": ["status"], "query": {"terms": {"code": [500, 503]}}}`,
+	},
+	{
+		`select count(id), average(latency), distinct(user) where status = true`,
+		`{
+			"aggs": {
+				"average(latency)": {"avg": {"field": "latency"}},
+				"count(id)": {"value_count": {"field": "id"}},
+				"distinct(user)": {"cardinality": {"field": "user"}}
+			},
+			"query": {"term": {"status": true}},
+			"size": 0
+		}`,
+	},
+	{
+		`select count(distinct user), median(latency), percentile(latency, 0.99), approx_count_distinct(host)`,
+		`{
+			"aggs": {
+				"approx_count_distinct(host)": {"cardinality": {"field": "host"}},
+				"count(distinct user)": {"cardinality": {"field": "user"}},
+				"median(latency)": {"percentiles": {"field": "latency", "percents": [50]}},
+				"percentile(latency, 0.99)": {"percentiles": {"field": "latency", "percents": [99]}}
+			},
+			"query": {"match_all": {}},
+			"size": 0
+		}`,
+	},
+	{
+		`select region, host, max(latency) group by region, host order by region desc, max(latency) desc limit 3`,
+		`{
+			"aggs": {"region": {
+				"aggs": {"host": {
+					"aggs": {"max(latency)": {"max": {"field": "latency"}}},
+					"terms": {"field": "host", "order": [{"max(latency)": "desc"}], "size": 10000}
+				}},
+				"terms": {"field": "region", "order": [{"_key": "desc"}], "size": 3}
+			}},
+			"query": {"match_all": {}},
+			"size": 0
+		}`,
+	},
+}
+
+func Test_Elasticsearch(t *testing.T) {
+	for _, test := range esTests {
+		got, err := ToElasticsearch(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var want bytes.Buffer
+		if err := json.Compact(&want, []byte(test.json)); err != nil {
+			t.Fatalf("%s: %v", test.json, err)
+		}
+		// re-marshal the expectation to sort its keys and spacing like the output
+		var v interface{}
+		json.Unmarshal(want.Bytes(), &v)
+		wantJSON, _ := json.Marshal(v)
+		if !bytes.Equal(got, wantJSON) {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, got, wantJSON)
+		}
+	}
+	if _, err := ToElasticsearch(`select name where age > ?`); err == nil {
+		t.Error("expected an error for an unbound placeholder")
+	}
+	if _, err := ToElasticsearch(`select stddev(latency)`); err == nil {
+		t.Error("expected an error for an unsupported aggragation")
+	}
+}
+
+func Test_ElasticsearchRegexp(t *testing.T) {
+	for _, test := range []struct{ pattern, value string }{
+		{`^a.+`, `a.+.*`},
+		{`in$`, `.*in`},
+		{`^a$`, `a`},
+		{`a\$`, `.*a\$.*`},
+		{`a\\$`, `.*a\\`},
+		{`a\\\$`, `.*a\\\$.*`},
+	} {
+		query := esRegexp("name", test.pattern)["regexp"].(map[string]interface{})["name"]
+		if got := query.(map[string]interface{})["value"]; got != test.value {
+			t.Errorf("%s: got %s, want %s", test.pattern, got, test.value)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Engine runs queries against its registered tables, typed by the schemas
+// of its catalog.
+type Engine struct {
+	mu      sync.RWMutex
+	tables  map[string]Table
+	catalog *Catalog
+}
+
+func NewEngine() *Engine {
+	return &Engine{tables: make(map[string]Table), catalog: NewCatalog()}
+}
+
+// Catalog returns the catalog holding the schemas of the tables.
+func (e *Engine) Catalog() *Catalog {
+	return e.catalog
+}
+
+// Register makes t available to queries as name, replacing any table
+// registered under the same name.
+func (e *Engine) Register(name string, t Table) {
+	e.mu.Lock()
+	e.tables[name] = t
+	e.mu.Unlock()
+}
+
+// Table returns the table registered as name.
+func (e *Engine) Table(name string) (Table, bool) {
+	e.mu.RLock()
+	t, ok := e.tables[name]
+	e.mu.RUnlock()
+	return t, ok
+}
+
+// table returns the table registered as name, converting its values to the
+// types of its schema.
+func (e *Engine) table(name string) (Table, error) {
+	t, ok := e.Table(name)
+	if !ok {
+		return nil, fmt.Errorf("table %q not found", name)
+	}
+	types, err := e.catalog.types(name, t)
+	if err != nil || types == nil {
+		return t, err
+	}
+	return &typedTable{Table: t, types: types}, nil
+}
+
+// Query parses and runs query, binding args to its positional placeholders.
+// The returned Rows must be closed unless they are read to the end.
+func (e *Engine) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	stmt, err := e.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(ctx, args...)
+}
+
+// Exec parses and runs an insert, update, delete or create table, binding
+// args to its positional placeholders.
+func (e *Engine) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	stmt, err := e.Prepare(query)
+	if err != nil {
+		return Result{}, err
+	}
+	return stmt.Exec(ctx, args...)
+}
+
+// Prepare parses query for repeated execution.
+func (e *Engine) Prepare(query string) (*Stmt, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	c := &checker{catalog: e.catalog, positions: p.positions}
+	if err := c.check(&p.model, nil); err != nil {
+		return nil, err
+	}
+	return &Stmt{engine: e, model: &p.model}, nil
+}
+
+// Stmt is a parsed query. It is safe for concurrent use.
+type Stmt struct {
+	engine *Engine
+	model  *model
+}
+
+// NumInput returns the number of positional placeholders of the statement.
+func (s *Stmt) NumInput() int {
+	return s.model.Placeholders
+}
+
+// Named reports whether the statement uses named placeholders.
+func (s *Stmt) Named() bool {
+	return len(s.model.Names) > 0
+}
+
+// Query runs the statement with args bound to its positional placeholders.
+func (s *Stmt) Query(ctx context.Context, args ...interface{}) (*Rows, error) {
+	m, err := s.model.Bind(args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.engine.execute(ctx, m)
+}
+
+// QueryNamed runs the statement with args bound to its named placeholders.
+func (s *Stmt) QueryNamed(ctx context.Context, args map[string]interface{}) (*Rows, error) {
+	m, err := s.model.BindNamed(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.engine.execute(ctx, m)
+}
+
+// Exec runs the insert, update, delete or create table statement with args
+// bound to its positional placeholders.
+func (s *Stmt) Exec(ctx context.Context, args ...interface{}) (Result, error) {
+	m, err := s.model.Bind(args...)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.engine.modify(ctx, m)
+}
+
+// ExecNamed runs the insert, update, delete or create table statement with
+// args bound to its named placeholders.
+func (s *Stmt) ExecNamed(ctx context.Context, args map[string]interface{}) (Result, error) {
+	m, err := s.model.BindNamed(args)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.engine.modify(ctx, m)
+}
+
+func (e *Engine) execute(ctx context.Context, m *model) (*Rows, error) {
+	if m.Type != SqlSelect {
+		return nil, fmt.Errorf("%s returns no rows, use Exec", m.Type)
+	}
+	it, err := e.plan(ctx, m, &scope{})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(ctx, m.Columns, m.Types, it), nil
+}
+
+// plan plans m as a pipeline of iterators: scan, join, filter, compute,
+// group, compute, window, project, set operators, sort and limit. Fields,
+// expressions and aggragations are resolved against the tables, or against
+// the group rows when the query aggragates, and then against the enclosing
+// queries of s, the scope of m that plan fills in.
+func (e *Engine) plan(ctx context.Context, m *model, s *scope) (rowIterator, error) {
+	t, err := e.table(m.TableName)
+	if err != nil {
+		return nil, err
+	}
+	// Joined rows carry the columns of each table qualified with its name.
+	s.ctx, s.engine, s.tables = ctx, e, m.tables()
+	s.columns = t.Columns()
+	joins := make([]*joinIter, len(m.Joins))
+	if len(m.Joins) > 0 {
+		s.columns = qualify(s.tables[0], s.columns)
+	}
+	for i, j := range m.Joins {
+		jt, err := e.table(j.Table)
+		if err != nil {
+			return nil, err
+		}
+		right := qualify(s.tables[i+1], jt.Columns())
+		join, err := planJoin(j, jt, s.columns, right, s)
+		if err != nil {
+			return nil, err
+		}
+		joins[i] = join
+		s.columns = append(append([]string(nil), s.columns...), right...)
+	}
+	schema := append([]string(nil), s.columns...)
+	match, err := compileCondition(m.Conditions, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expressions are computed as extra columns named after their text:
+	// before grouping those aggragated over, after it those selected.
+	var pre, post []evaluator
+	var grouping *groupIter
+	if m.aggragates() {
+		grouping = &groupIter{ctx: ctx}
+		input := schema
+		for _, agg := range m.Aggragations.Items {
+			if agg.Expr == nil || contains(schema, agg.Field) {
+				continue
+			}
+			eval, err := compileExpr(agg.Expr, input, s)
+			if err != nil {
+				return nil, err
+			}
+			pre = append(pre, eval)
+			schema = append(schema, agg.Field)
+		}
+		for _, f := range m.GroupBy {
+			idx, err := columnIndex(schema, s.tables, f)
+			if err != nil {
+				return nil, err
+			}
+			grouping.keys = append(grouping.keys, idx)
+		}
+		for _, agg := range m.Aggragations.Items {
+			idx, err := columnIndex(schema, s.tables, agg.Field)
+			if err != nil {
+				return nil, err
+			}
+			grouping.aggs = append(grouping.aggs, aggSpec{agg: agg, index: idx})
+		}
+		schema = make([]string, 0, len(m.GroupBy)+len(m.Aggragations.Items))
+		schema = append(schema, m.GroupBy...)
+		for _, agg := range m.Aggragations.Items {
+			schema = append(schema, agg.String())
+		}
+	}
+	input := schema
+	for _, e := range m.Expressions {
+		eval, err := compileExpr(e, input, s)
+		if err != nil {
+			return nil, err
+		}
+		post = append(post, eval)
+		schema = append(schema, e.String())
+	}
+	// Windows are computed over the rows of the result before they are
+	// ordered.
+	input = schema
+	windows := make([]windowSpec, len(m.Windows))
+	for i, w := range m.Windows {
+		if windows[i], err = compileWindow(w, input, s); err != nil {
+			return nil, err
+		}
+		schema = append(schema, w.String())
+	}
+
+	// Order by columns missing from the result are carried along until the
+	// rows are sorted.
+	columns := append([]string(nil), m.Columns...)
+	keys := make([]sortKey, len(m.OrderBy))
+	for i, o := range m.OrderBy {
+		idx := indexOf(columns, o.Column)
+		if idx < 0 {
+			idx = len(columns)
+			columns = append(columns, o.Column)
+		}
+		keys[i] = sortKey{index: idx, desc: o.Desc}
+	}
+	project := make([]int, len(columns))
+	for i, c := range columns {
+		idx, err := columnIndex(schema, s.tables, c)
+		if err != nil {
+			return nil, err
+		}
+		project[i] = idx
+	}
+
+	// The queries of set operators see the same enclosing queries as m.
+	operands := make([]rowIterator, 0, len(m.SetOps))
+	closeOperands := func() {
+		for _, it := range operands {
+			it.close()
+		}
+	}
+	for _, op := range m.SetOps {
+		inner := &scope{outer: s.outer}
+		it, err := e.plan(ctx, op.Query, inner)
+		if err != nil {
+			closeOperands()
+			return nil, err
+		}
+		operands = append(operands, it)
+		s.correlated = s.correlated || inner.correlated
+	}
+
+	cursor, err := t.Cursor(ctx)
+	if err != nil {
+		closeOperands()
+		return nil, err
+	}
+	var it rowIterator = &cursorIter{cursor: cursor}
+	for _, join := range joins {
+		join.input = it
+		it = join
+	}
+	if match != nil {
+		it = &filterIter{ctx: ctx, input: it, match: match}
+	}
+	if len(pre) > 0 {
+		it = &computeIter{input: it, exprs: pre}
+	}
+	if grouping != nil {
+		grouping.input = it
+		it = grouping
+	}
+	if len(post) > 0 {
+		it = &computeIter{input: it, exprs: post}
+	}
+	if len(windows) > 0 {
+		it = &windowIter{ctx: ctx, input: it, windows: windows}
+	}
+	it = &projectIter{input: it, index: project}
+	if m.Distinct {
+		it = &distinctIter{ctx: ctx, input: it, seen: rowSet{}}
+	}
+	if len(operands) > 0 {
+		inputs := append([]rowIterator{it}, operands...)
+		it = &setIter{ctx: ctx, inputs: inputs, ops: m.SetOps}
+	}
+	if len(keys) > 0 {
+		it = &sortIter{ctx: ctx, input: it, keys: keys}
+	}
+	if len(columns) > len(m.Columns) {
+		trim := make([]int, len(m.Columns))
+		for i := range trim {
+			trim[i] = i
+		}
+		it = &projectIter{input: it, index: trim}
+	}
+	if m.Offset > 0 {
+		it = &offsetIter{input: it, n: m.Offset}
+	}
+	if m.Limit >= 0 {
+		it = &limitIter{input: it, n: m.Limit}
+	}
+	return it, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,772 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// rowIterator is a stage of the executor pipeline. Stages pull rows from
+// their input one at a time; only grouping and sorting buffer.
+type rowIterator interface {
+	// next returns the next row, or io.EOF when there are no more.
+	next() ([]interface{}, error)
+	close() error
+}
+
+type predicate func(row []interface{}) (bool, error)
+
+// evaluator computes the value of an expression for a row.
+type evaluator func(row []interface{}) (interface{}, error)
+
+// columnIndex finds name in columns, those of the tables named tables. A
+// dotted name such as "n.age" falls back to its last part when no column
+// carries the full name and n is one of tables, and a plain name matches a
+// single qualified column, as joined rows have.
+func columnIndex(columns, tables []string, name string) (int, error) {
+	for i, c := range columns {
+		if c == name {
+			return i, nil
+		}
+	}
+	if dot := strings.LastIndex(name, MarkDot); dot >= 0 {
+		if !contains(tables, name[:dot]) {
+			return -1, fmt.Errorf("column %q not found", name)
+		}
+		for i, c := range columns {
+			if c == name[dot+1:] {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found", name)
+	}
+	idx := -1
+	for i, c := range columns {
+		table := strings.TrimSuffix(c, MarkDot+name)
+		if table != c && table != "" && strings.Trim(table, identifier) == "" {
+			if idx >= 0 {
+				return -1, fmt.Errorf("column %q is ambiguous", name)
+			}
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return -1, fmt.Errorf("column %q not found", name)
+	}
+	return idx, nil
+}
+
+// scope resolves the fields of a query being run: against its own columns
+// first, then against the rows of the queries enclosing it, which is how a
+// correlated subquery sees the row it is evaluated for. A field qualified
+// with the table of an enclosing query, as in "users.id", refers to that
+// query.
+type scope struct {
+	ctx        context.Context
+	engine     *Engine
+	tables     []string      // names qualifying the columns of the query
+	columns    []string      // the table columns, which conditions see
+	row        []interface{} // the row a subquery is evaluated for
+	outer      *scope
+	correlated bool // a field resolves to an enclosing query
+}
+
+// field compiles a reference to the field name, which columns or an
+// enclosing query hold.
+func (s *scope) field(name string, columns []string) (evaluator, error) {
+	if dot := strings.LastIndex(name, MarkDot); dot >= 0 && !contains(s.tables, name[:dot]) {
+		for o := s.outer; o != nil; o = o.outer {
+			if !contains(o.tables, name[:dot]) {
+				continue
+			}
+			if idx, err := columnIndex(o.columns, o.tables, name); err == nil {
+				return s.outerField(o, idx), nil
+			}
+		}
+	}
+	idx, err := columnIndex(columns, s.tables, name)
+	if err == nil {
+		return func(row []interface{}) (interface{}, error) {
+			return row[idx], nil
+		}, nil
+	}
+	for o := s.outer; o != nil; o = o.outer {
+		if idx, err := columnIndex(o.columns, o.tables, name); err == nil {
+			return s.outerField(o, idx), nil
+		}
+	}
+	return nil, err
+}
+
+// outerField reads the column idx of the row of the enclosing query o,
+// marking the queries in between as correlated.
+func (s *scope) outerField(o *scope, idx int) evaluator {
+	for q := s; q != o; q = q.outer {
+		q.correlated = true
+	}
+	return func([]interface{}) (interface{}, error) {
+		return o.row[idx], nil
+	}
+}
+
+// compileCondition turns c into a predicate over rows of the table of s. A
+// nil condition compiles to a nil predicate.
+func compileCondition(c Condition, s *scope) (predicate, error) {
+	switch c := c.(type) {
+	case nil:
+		return nil, nil
+	case *SingleCondition:
+		if sub, ok := c.Right.(*Subquery); ok {
+			return compileSubquery(c, sub, s)
+		}
+		return compileSingleCondition(c, s)
+	case *MultiCondition:
+		subs := make([]predicate, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			match, err := compileCondition(sub, s)
+			if err != nil {
+				return nil, err
+			}
+			subs[i] = match
+		}
+		switch c.Logic {
+		case LogicAnd:
+			return func(row []interface{}) (bool, error) {
+				for _, match := range subs {
+					if ok, err := match(row); !ok || err != nil {
+						return false, err
+					}
+				}
+				return true, nil
+			}, nil
+		case LogicOr:
+			return func(row []interface{}) (bool, error) {
+				for _, match := range subs {
+					if ok, err := match(row); ok || err != nil {
+						return ok, err
+					}
+				}
+				return false, nil
+			}, nil
+		case LogicNot:
+			if len(subs) != 1 {
+				return nil, fmt.Errorf("not takes one condition, got %d", len(subs))
+			}
+			return func(row []interface{}) (bool, error) {
+				ok, err := subs[0](row)
+				return !ok && err == nil, err
+			}, nil
+		}
+		return nil, fmt.Errorf("unknown logic %d", c.Logic)
+	}
+	return nil, fmt.Errorf("unknown condition %T", c)
+}
+
+func compileSingleCondition(c *SingleCondition, s *scope) (predicate, error) {
+	eval, err := compileLeft(c, s)
+	if err != nil {
+		return nil, err
+	}
+	if c.Right != nil {
+		right, err := compileExpr(c.Right, s.columns, s)
+		if err != nil {
+			return nil, err
+		}
+		cmp := c.Comparator
+		return func(row []interface{}) (bool, error) {
+			v, err := eval(row)
+			if v == nil || err != nil {
+				return false, err
+			}
+			w, err := right(row)
+			if w == nil || err != nil {
+				return false, err
+			}
+			return compared(cmp, compareValues(v, w)), nil
+		}, nil
+	}
+	value := c.Value
+	if err := checkBound(value); err != nil {
+		return nil, err
+	}
+	var match func(v interface{}) bool
+	switch c.Comparator {
+	case ComparatorIN:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in list for %s not valid", c.Field)
+		}
+		match = func(v interface{}) bool {
+			for _, item := range values {
+				if item != nil && compareValues(v, item) == 0 {
+					return true
+				}
+			}
+			return false
+		}
+	case ComparatorLIKE, ComparatorILIKE:
+		like, err := c.like()
+		if err != nil {
+			return nil, err
+		}
+		match = func(v interface{}) bool {
+			return like.Match(fmt.Sprint(v))
+		}
+	case ComparatorREGEXP, ComparatorNREGEXP:
+		re, err := c.regexp()
+		if err != nil {
+			return nil, err
+		}
+		want := c.Comparator == ComparatorREGEXP
+		match = func(v interface{}) bool {
+			return re.MatchString(fmt.Sprint(v)) == want
+		}
+	default:
+		if value == nil {
+			return compileNull(c, eval), nil
+		}
+		cmp := c.Comparator
+		match = func(v interface{}) bool {
+			return compared(cmp, compareValues(v, value))
+		}
+	}
+	return func(row []interface{}) (bool, error) {
+		v, err := eval(row)
+		if v == nil || err != nil {
+			return false, err
+		}
+		return match(v), nil
+	}, nil
+}
+
+// compileNull compiles the comparison c with null, the value of a bound
+// placeholder. As the dialects render it, "=" tests for null and "!="
+// for a value; other comparisons with null never match.
+func compileNull(c *SingleCondition, eval evaluator) predicate {
+	if c.Comparator != ComparatorEQ && c.Comparator != ComparatorNEQ {
+		return func([]interface{}) (bool, error) { return false, nil }
+	}
+	want := c.Comparator == ComparatorEQ
+	return func(row []interface{}) (bool, error) {
+		v, err := eval(row)
+		if err != nil {
+			return false, err
+		}
+		return (v == nil) == want, nil
+	}
+}
+
+// compileLeft compiles the left side of c.
+func compileLeft(c *SingleCondition, s *scope) (evaluator, error) {
+	return compileExpr(c.left(), s.columns, s)
+}
+
+// compileSubquery compiles a condition on a subquery. The subquery runs for
+// each row the condition is evaluated for, unless it does not refer to the
+// enclosing query, when its first result is kept.
+func compileSubquery(c *SingleCondition, sub *Subquery, s *scope) (predicate, error) {
+	var left evaluator
+	if c.Comparator != ComparatorEXISTS {
+		if n := len(sub.Query.Columns); n != 1 {
+			return nil, fmt.Errorf("subquery %s returns %d columns, want 1", sub, n)
+		}
+		var err error
+		if left, err = compileLeft(c, s); err != nil {
+			return nil, err
+		}
+	}
+	var cached [][]interface{}
+	var done bool
+	run := func(row []interface{}) ([][]interface{}, error) {
+		if done {
+			return cached, nil
+		}
+		s.row = row
+		inner := &scope{outer: s}
+		it, err := s.engine.plan(s.ctx, sub.Query, inner)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := readAll(s.ctx, it)
+		if cerr := it.close(); err == nil {
+			err = cerr
+		}
+		if err == nil && !inner.correlated {
+			cached, done = rows, true
+		}
+		return rows, err
+	}
+	cmp := c.Comparator
+	return func(row []interface{}) (bool, error) {
+		var v interface{}
+		if left != nil {
+			var err error
+			if v, err = left(row); v == nil || err != nil {
+				return false, err
+			}
+		}
+		rows, err := run(row)
+		if err != nil {
+			return false, err
+		}
+		switch cmp {
+		case ComparatorEXISTS:
+			return len(rows) > 0, nil
+		case ComparatorIN:
+			for _, r := range rows {
+				if r[0] != nil && compareValues(v, r[0]) == 0 {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		if len(rows) > 1 {
+			return false, fmt.Errorf("subquery %s returns more than one row", sub)
+		}
+		if len(rows) == 0 || rows[0][0] == nil {
+			return false, nil
+		}
+		return compared(cmp, compareValues(v, rows[0][0])), nil
+	}, nil
+}
+
+// compared reports whether the result n of compareValues satisfies cmp.
+func compared(cmp ComparatorType, n int) bool {
+	switch cmp {
+	case ComparatorEQ:
+		return n == 0
+	case ComparatorNEQ:
+		return n != 0
+	case ComparatorGT:
+		return n > 0
+	case ComparatorGTE:
+		return n >= 0
+	case ComparatorLT:
+		return n < 0
+	case ComparatorLTE:
+		return n <= 0
+	}
+	return false
+}
+
+// compileExpr turns e into an evaluator over rows of columns, in the
+// query of s.
+func compileExpr(e Expr, columns []string, s *scope) (evaluator, error) {
+	switch e := e.(type) {
+	case *Ident:
+		return s.field(e.Name, columns)
+	case *Literal:
+		if err := checkBound(e.Value); err != nil {
+			return nil, err
+		}
+		return func([]interface{}) (interface{}, error) {
+			return e.Value, nil
+		}, nil
+	case *Call:
+		fn, ok := lookupFunction(e.Name)
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown function %s", funcError, e.Name)
+		}
+		args := make([]evaluator, len(e.Args))
+		for i, arg := range e.Args {
+			eval, err := compileExpr(arg, columns, s)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = eval
+		}
+		return func(row []interface{}) (interface{}, error) {
+			values := make([]interface{}, len(args))
+			for i, eval := range args {
+				v, err := eval(row)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			return fn.call(e.Name, values)
+		}, nil
+	case *Binary:
+		left, err := compileExpr(e.Left, columns, s)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileExpr(e.Right, columns, s)
+		if err != nil {
+			return nil, err
+		}
+		op := e.Op
+		return func(row []interface{}) (interface{}, error) {
+			a, err := left(row)
+			if err != nil {
+				return nil, err
+			}
+			b, err := right(row)
+			if a == nil || b == nil || err != nil {
+				return nil, err
+			}
+			if op == OpConcat {
+				return fmt.Sprint(a) + fmt.Sprint(b), nil
+			}
+			return arith(op, a, b)
+		}, nil
+	case *Unary:
+		x, err := compileExpr(e.X, columns, s)
+		if err != nil {
+			return nil, err
+		}
+		return func(row []interface{}) (interface{}, error) {
+			v, err := x(row)
+			if v == nil || err != nil {
+				return nil, err
+			}
+			return negate(v)
+		}, nil
+	case *Cast:
+		x, err := compileExpr(e.X, columns, s)
+		if err != nil {
+			return nil, err
+		}
+		t := e.Type
+		return func(row []interface{}) (interface{}, error) {
+			v, err := x(row)
+			if v == nil || err != nil {
+				return nil, err
+			}
+			converted, ok := t.convert(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %T %v to %s", v, v, t)
+			}
+			return converted, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+// cursorIter reads rows from a table.
+type cursorIter struct {
+	cursor Cursor
+}
+
+func (it *cursorIter) next() ([]interface{}, error) {
+	return it.cursor.Next()
+}
+
+func (it *cursorIter) close() error {
+	return it.cursor.Close()
+}
+
+// filterIter passes on the rows matching a condition.
+type filterIter struct {
+	ctx   context.Context
+	input rowIterator
+	match predicate
+}
+
+func (it *filterIter) next() ([]interface{}, error) {
+	for {
+		if err := it.ctx.Err(); err != nil {
+			return nil, err
+		}
+		row, err := it.input.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := it.match(row); err != nil {
+			return nil, err
+		} else if ok {
+			return row, nil
+		}
+	}
+}
+
+func (it *filterIter) close() error {
+	return it.input.close()
+}
+
+// computeIter appends the values of expressions to each row.
+type computeIter struct {
+	input rowIterator
+	exprs []evaluator
+}
+
+func (it *computeIter) next() ([]interface{}, error) {
+	row, err := it.input.next()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(row), len(row)+len(it.exprs))
+	copy(out, row)
+	for _, eval := range it.exprs {
+		v, err := eval(row)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (it *computeIter) close() error {
+	return it.input.close()
+}
+
+// projectIter picks the columns at index from each row.
+type projectIter struct {
+	input rowIterator
+	index []int
+}
+
+func (it *projectIter) next() ([]interface{}, error) {
+	row, err := it.input.next()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(it.index))
+	for i, idx := range it.index {
+		out[i] = row[idx]
+	}
+	return out, nil
+}
+
+func (it *projectIter) close() error {
+	return it.input.close()
+}
+
+// distinctIter drops the rows equal to one returned before, as a set
+// operator without all does.
+type distinctIter struct {
+	ctx   context.Context
+	input rowIterator
+	seen  rowSet
+}
+
+func (it *distinctIter) next() ([]interface{}, error) {
+	for {
+		if err := it.ctx.Err(); err != nil {
+			return nil, err
+		}
+		row, err := it.input.next()
+		if err != nil {
+			return nil, err
+		}
+		if it.seen.add(row) == 1 {
+			return row, nil
+		}
+	}
+}
+
+func (it *distinctIter) close() error {
+	it.seen = nil
+	return it.input.close()
+}
+
+// limitIter stops after n rows without reading further input.
+type limitIter struct {
+	input rowIterator
+	n     int
+}
+
+func (it *limitIter) next() ([]interface{}, error) {
+	if it.n <= 0 {
+		return nil, io.EOF
+	}
+	it.n--
+	return it.input.next()
+}
+
+func (it *limitIter) close() error {
+	return it.input.close()
+}
+
+// offsetIter skips the first n rows.
+type offsetIter struct {
+	input rowIterator
+	n     int
+}
+
+func (it *offsetIter) next() ([]interface{}, error) {
+	for ; it.n > 0; it.n-- {
+		if _, err := it.input.next(); err != nil {
+			return nil, err
+		}
+	}
+	return it.input.next()
+}
+
+func (it *offsetIter) close() error {
+	return it.input.close()
+}
+
+type sortKey struct {
+	index int
+	desc  bool
+}
+
+// sortIter buffers its whole input and returns it ordered by keys.
+type sortIter struct {
+	ctx    context.Context
+	input  rowIterator
+	keys   []sortKey
+	rows   [][]interface{}
+	sorted bool
+}
+
+func (it *sortIter) next() ([]interface{}, error) {
+	if !it.sorted {
+		rows, err := readAll(it.ctx, it.input)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return compareRows(it.keys, rows[i], rows[j]) < 0
+		})
+		it.rows, it.sorted = rows, true
+	}
+	if len(it.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+func (it *sortIter) close() error {
+	it.rows = nil
+	return it.input.close()
+}
+
+// compareRows compares the rows a and b by keys, returning a negative
+// number when a comes first and zero when they are ordered alike.
+func compareRows(keys []sortKey, a, b []interface{}) int {
+	for _, k := range keys {
+		n := compareValues(a[k.index], b[k.index])
+		if n == 0 {
+			continue
+		}
+		if k.desc {
+			return -n
+		}
+		return n
+	}
+	return 0
+}
+
+type aggSpec struct {
+	agg   aggItem
+	index int
+}
+
+type group struct {
+	keys []interface{}
+	accs []accumulator
+}
+
+// groupIter buffers its input into groups and returns one row per group:
+// the group by values followed by the aggragation results. Without group by
+// fields the whole input forms a single group.
+type groupIter struct {
+	ctx    context.Context
+	input  rowIterator
+	keys   []int
+	aggs   []aggSpec
+	groups []*group
+	done   bool
+}
+
+func (it *groupIter) next() ([]interface{}, error) {
+	if !it.done {
+		if err := it.fill(); err != nil {
+			return nil, err
+		}
+		it.done = true
+	}
+	if len(it.groups) == 0 {
+		return nil, io.EOF
+	}
+	g := it.groups[0]
+	it.groups = it.groups[1:]
+	row := make([]interface{}, 0, len(g.keys)+len(g.accs))
+	row = append(row, g.keys...)
+	for _, acc := range g.accs {
+		row = append(row, acc.result())
+	}
+	return row, nil
+}
+
+func (it *groupIter) fill() error {
+	byKey := make(map[string]*group)
+	if len(it.keys) == 0 {
+		it.groups = append(it.groups, it.newGroup(nil))
+		byKey[""] = it.groups[0]
+	}
+	for {
+		if err := it.ctx.Err(); err != nil {
+			return err
+		}
+		row, err := it.input.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keys := make([]interface{}, len(it.keys))
+		for i, idx := range it.keys {
+			keys[i] = row[idx]
+		}
+		k := groupKey(keys)
+		g, ok := byKey[k]
+		if !ok {
+			g = it.newGroup(keys)
+			byKey[k] = g
+			it.groups = append(it.groups, g)
+		}
+		for i, spec := range it.aggs {
+			if err := g.accs[i].add(row[spec.index]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (it *groupIter) newGroup(keys []interface{}) *group {
+	g := &group{keys: keys, accs: make([]accumulator, len(it.aggs))}
+	for i, spec := range it.aggs {
+		g.accs[i] = newAccumulator(spec.agg)
+	}
+	return g
+}
+
+func (it *groupIter) close() error {
+	it.groups = nil
+	return it.input.close()
+}
+
+func groupKey(values []interface{}) string {
+	var b strings.Builder
+	for _, v := range values {
+		fmt.Fprintf(&b, "%T:%v\x00", v, v)
+	}
+	return b.String()
+}
+
+// readAll drains input, checking ctx between rows.
+func readAll(ctx context.Context, input rowIterator) ([][]interface{}, error) {
+	var rows [][]interface{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		row, err := input.next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
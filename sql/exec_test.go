@@ -0,0 +1,534 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newTestEngine() *Engine {
+	e := NewEngine()
+	e.Register("graph", NewMemTable(
+		[]string{"name", "age", "region"},
+		[]interface{}{"alice", int64(30), "cn-beijing"},
+		[]interface{}{"bob", int64(25), "cn-shanghai"},
+		[]interface{}{"carol", int64(35), "cn-beijing"},
+		[]interface{}{"dave", nil, "cn-shanghai"},
+		[]interface{}{"erin", int64(28), "us-west"},
+	))
+	e.Register("orders", NewMemTable(
+		[]string{"id", "user", "total"},
+		[]interface{}{int64(1), "alice", int64(120)},
+		[]interface{}{int64(2), "alice", int64(30)},
+		[]interface{}{int64(3), "bob", int64(200)},
+		[]interface{}{int64(4), "erin", int64(50)},
+	))
+	return e
+}
+
+type queryTest struct {
+	query string
+	rows  [][]interface{}
+}
+
+var queryTests = []queryTest{
+	{
+		`select name where age > 28`,
+		[][]interface{}{{"alice"}, {"carol"}},
+	},
+	{
+		`select name, age from graph where region = "cn-beijing" or name = "bob" order by age desc`,
+		[][]interface{}{{"carol", int64(35)}, {"alice", int64(30)}, {"bob", int64(25)}},
+	},
+	{
+		`select name where not (region like "cn%") or age <= 25`,
+		[][]interface{}{{"bob"}, {"erin"}},
+	},
+	{
+		`select name order by age limit 2`,
+		[][]interface{}{{"dave"}, {"bob"}},
+	},
+	{
+		`select name order by age limit 2 offset 1`,
+		[][]interface{}{{"bob"}, {"erin"}},
+	},
+	{
+		`select name order by age offset 3`,
+		[][]interface{}{{"alice"}, {"carol"}},
+	},
+	{
+		`select region, count(age), max(age) group by region order by region`,
+		[][]interface{}{
+			{"cn-beijing", int64(2), int64(35)},
+			{"cn-shanghai", int64(1), int64(25)},
+			{"us-west", int64(1), int64(28)},
+		},
+	},
+	{
+		`select count(name), sum(age), average(age), distinct(region) where age >= 28`,
+		[][]interface{}{{int64(3), int64(93), float64(31), int64(2)}},
+	},
+	{
+		`select region, count(distinct age), avg(age), median(age), variance(age), stddev(age), array_agg(name), string_agg(name, ";") group by region order by region`,
+		[][]interface{}{
+			{"cn-beijing", int64(2), float64(32.5), float64(32.5), float64(12.5), math.Sqrt(12.5), []interface{}{"alice", "carol"}, "alice;carol"},
+			{"cn-shanghai", int64(1), float64(25), float64(25), nil, nil, []interface{}{"bob", "dave"}, "bob;dave"},
+			{"us-west", int64(1), float64(28), float64(28), nil, nil, []interface{}{"erin"}, "erin"},
+		},
+	},
+	{
+		`select count(distinct region), sum(distinct total / 100), percentile(age, 0.25), approx_count_distinct(region) from graph join orders on name = user`,
+		[][]interface{}{{int64(3), int64(3), float64(27.25), int64(3)}},
+	},
+	{
+		`select distinct substr(region, 1, 2) order by substr(region, 1, 2) desc`,
+		[][]interface{}{{"us"}, {"cn"}},
+	},
+	{
+		`select distinct user from orders where total > 40 order by user desc limit 2`,
+		[][]interface{}{{"erin"}, {"bob"}},
+	},
+	{
+		`select distinct region, count(distinct name), distinct(region) group by region order by region limit 1`,
+		[][]interface{}{{"cn-beijing", int64(2), int64(1)}},
+	},
+	{
+		`select name, region, row_number() over (partition by region order by age desc) order by name`,
+		[][]interface{}{
+			{"alice", "cn-beijing", int64(2)},
+			{"bob", "cn-shanghai", int64(1)},
+			{"carol", "cn-beijing", int64(1)},
+			{"dave", "cn-shanghai", int64(2)},
+			{"erin", "us-west", int64(1)},
+		},
+	},
+	{
+		`select name, rank() over (order by substr(region, 1, 2)), sum(age) over (partition by region), sum(age) over (order by age) order by name`,
+		[][]interface{}{
+			{"alice", int64(1), int64(65), int64(83)},
+			{"bob", int64(1), int64(25), int64(25)},
+			{"carol", int64(1), int64(65), int64(118)},
+			{"dave", int64(1), int64(25), nil},
+			{"erin", int64(5), int64(28), int64(53)},
+		},
+	},
+	{
+		`select name, lag(name) over (order by age), lead(name, 2, "none") over (order by age) order by age`,
+		[][]interface{}{
+			{"dave", nil, "erin"},
+			{"bob", "dave", "alice"},
+			{"erin", "bob", "carol"},
+			{"alice", "erin", "none"},
+			{"carol", "alice", "none"},
+		},
+	},
+	{
+		`select region, count(name), rank() over (order by count(name) desc) group by region order by region`,
+		[][]interface{}{{"cn-beijing", int64(2), int64(1)}, {"cn-shanghai", int64(2), int64(1)}, {"us-west", int64(1), int64(3)}},
+	},
+	{
+		`select name order by row_number() over (order by name desc) limit 2`,
+		[][]interface{}{{"erin"}, {"dave"}},
+	},
+	{
+		`select region group by region order by count(name) desc, region limit 1`,
+		[][]interface{}{{"cn-beijing"}},
+	},
+	{
+		`select upper(name), length(region) where lower(name) like "a%" or substr(region, 1, 2) = "us"`,
+		[][]interface{}{{"ALICE", int64(10)}, {"ERIN", int64(7)}},
+	},
+	{
+		`select name where name ilike "A%" or region not like "cn%"`,
+		[][]interface{}{{"alice"}, {"erin"}},
+	},
+	{
+		`select name where name ~ "^[a-c]" and region !~ "beijing" or name rlike "in$"`,
+		[][]interface{}{{"bob"}, {"erin"}},
+	},
+	{
+		`select name where name like "_o%" and region not ilike "%_WEST" or region like "us!-%" escape "!"`,
+		[][]interface{}{{"bob"}, {"erin"}},
+	},
+	{
+		`select name where region like "cn\\-b%" escape '\' and name not like "a%"`,
+		[][]interface{}{{"carol"}},
+	},
+	{
+		`select name order by length(name) desc, name limit 3`,
+		[][]interface{}{{"alice"}, {"carol"}, {"dave"}},
+	},
+	{
+		`select substr(region, 1, 2), sum(abs(age)), max(coalesce(age, 0)) group by region order by substr(region, 1, 2), max(coalesce(age, 0))`,
+		[][]interface{}{{"cn", int64(25), int64(25)}, {"cn", int64(65), int64(35)}, {"us", int64(28), int64(28)}},
+	},
+	{
+		`select name, age * 2 + 1, -age / 4, age % 7 where (age - 5) * 2 > 40 order by age`,
+		[][]interface{}{{"erin", int64(57), int64(-7), int64(0)}, {"alice", int64(61), int64(-7), int64(2)}, {"carol", int64(71), int64(-8), int64(0)}},
+	},
+	{
+		`select name || "@" || region, age / 2.0 where name = "bob" or age = 35 order by name`,
+		[][]interface{}{{"bob@cn-shanghai", float64(12.5)}, {"carol@cn-beijing", float64(17.5)}},
+	},
+	{
+		`select region, sum(age * 2), average(age + 0.5) group by region order by sum(age * 2) desc`,
+		[][]interface{}{
+			{"cn-beijing", int64(130), float64(33)},
+			{"us-west", int64(56), float64(28.5)},
+			{"cn-shanghai", int64(50), float64(25.5)},
+		},
+	},
+	{
+		`select name where region > name and age = age`,
+		[][]interface{}{{"alice"}, {"bob"}, {"carol"}, {"erin"}},
+	},
+	{
+		`select name where age - 5 > length(region) * 2 order by name desc`,
+		[][]interface{}{{"erin"}, {"carol"}, {"alice"}},
+	},
+	{
+		`select name where name in (select user from orders where total > 100) order by name`,
+		[][]interface{}{{"alice"}, {"bob"}},
+	},
+	{
+		`select name where name not in ("alice", "bob") and age > 20`,
+		[][]interface{}{{"carol"}, {"erin"}},
+	},
+	{
+		`select name where name not in (select user from orders)`,
+		[][]interface{}{{"carol"}, {"dave"}},
+	},
+	{
+		`select name where exists (select id from orders where orders.user = graph.name and total < 100)`,
+		[][]interface{}{{"alice"}, {"erin"}},
+	},
+	{
+		`select g.name from graph g where exists (select id from orders o where o.user = g.name and o.total > 150)`,
+		[][]interface{}{{"bob"}},
+	},
+	{
+		`select name where not exists (select id from orders where user = name)`,
+		[][]interface{}{{"carol"}, {"dave"}},
+	},
+	{
+		`select name where age > (select average(age) from graph) or region = (select region from graph where name = "erin")`,
+		[][]interface{}{{"alice"}, {"carol"}, {"erin"}},
+	},
+	{
+		`select g.name, o.total from graph g join orders o on g.name = o.user where o.total > 40 order by o.total`,
+		[][]interface{}{{"erin", int64(50)}, {"alice", int64(120)}, {"bob", int64(200)}},
+	},
+	{
+		`select name, count(o.id) from graph left join orders o on name = o.user group by name order by count(o.id) desc, name`,
+		[][]interface{}{{"alice", int64(2)}, {"bob", int64(1)}, {"erin", int64(1)}, {"carol", int64(0)}, {"dave", int64(0)}},
+	},
+	{
+		`select g.name, r.name from graph g, graph r where g.age >= 30 and r.age >= 30`,
+		[][]interface{}{{"alice", "alice"}, {"alice", "carol"}, {"carol", "alice"}, {"carol", "carol"}},
+	},
+	{
+		`select o.id from orders o inner join graph on user = name and total > age * 3`,
+		[][]interface{}{{int64(1)}, {int64(3)}},
+	},
+	{
+		`select name from graph where region = "cn-beijing" union select user from orders order by name`,
+		[][]interface{}{{"alice"}, {"bob"}, {"carol"}, {"erin"}},
+	},
+	{
+		`select user from orders union all select name from graph where age > 30`,
+		[][]interface{}{{"alice"}, {"alice"}, {"bob"}, {"erin"}, {"carol"}},
+	},
+	{
+		`select name from graph intersect select user from orders where total > 40 order by name desc`,
+		[][]interface{}{{"erin"}, {"bob"}, {"alice"}},
+	},
+	{
+		`select user from orders except select name from graph where region like "cn%"`,
+		[][]interface{}{{"erin"}},
+	},
+	{
+		`select user from orders except all select name from graph where age = 30`,
+		[][]interface{}{{"alice"}, {"bob"}, {"erin"}},
+	},
+	{
+		`select user from orders union select name from graph where age > 30 intersect select name from graph where age < 30`,
+		[][]interface{}{{"alice"}, {"bob"}, {"erin"}},
+	},
+	{
+		`select count(id) from orders union select 4.0 union select 2 + 3`,
+		[][]interface{}{{int64(4)}, {int64(5)}},
+	},
+	{
+		`select name where name in (select user from orders where total > 100 union select name from graph where age < 26)`,
+		[][]interface{}{{"alice"}, {"bob"}},
+	},
+}
+
+func Test_Query(t *testing.T) {
+	e := newTestEngine()
+	for _, test := range queryTests {
+		rows, err := e.Query(context.Background(), test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var got [][]interface{}
+		for rows.Next() {
+			row := make([]interface{}, len(rows.Columns()))
+			dest := make([]interface{}, len(row))
+			for i := range row {
+				dest[i] = &row[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Errorf("%s: %v", test.query, err)
+			}
+			got = append(got, row)
+		}
+		if err := rows.Err(); err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		}
+		if !reflect.DeepEqual(got, test.rows) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.rows)
+		} else {
+			fmt.Println(rows.Columns(), got)
+		}
+	}
+}
+
+// countingTable counts the rows handed out by its cursors.
+type countingTable struct {
+	*MemTable
+	read int
+}
+
+func (t *countingTable) Cursor(ctx context.Context) (Cursor, error) {
+	c, err := t.MemTable.Cursor(ctx)
+	return &countingCursor{c, t}, err
+}
+
+type countingCursor struct {
+	Cursor
+	t *countingTable
+}
+
+func (c *countingCursor) Next() ([]interface{}, error) {
+	row, err := c.Cursor.Next()
+	if err == nil {
+		c.t.read++
+	}
+	return row, err
+}
+
+func Test_QueryLazy(t *testing.T) {
+	table := &countingTable{MemTable: NewMemTable([]string{"id"})}
+	for i := 0; i < 100; i++ {
+		table.Insert(int64(i))
+	}
+	e := NewEngine()
+	e.Register("graph", table)
+	rows, err := e.Query(context.Background(), `select id where id >= 10 limit 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	for rows.Next() {
+		rows.Scan(&id)
+	}
+	if id != 12 || table.read != 13 {
+		t.Errorf("got last id %d after reading %d rows, want 12 after 13", id, table.read)
+	}
+}
+
+func Test_QueryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := newTestEngine().Query(ctx, `select name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("expected a row")
+	}
+	cancel()
+	if rows.Next() {
+		t.Error("expected no row after cancel")
+	}
+	if rows.Err() != context.Canceled {
+		t.Errorf("got error %v, want %v", rows.Err(), context.Canceled)
+	}
+}
+
+func Test_QueryArithmetic(t *testing.T) {
+	for _, query := range []string{
+		`select age +`,
+		`select (age + 1`,
+		`select name where (age + 1) and age > 2`,
+		`select name where not (age * 2)`,
+		`select name where age * > 2`,
+		`select name where name like region`,
+		`select name where name like "a!" escape "!"`,
+		`select name where name like "a%" escape "!!"`,
+		`select name where age > 2 escape "!"`,
+	} {
+		p := NewParse(query)
+		p.Generate()
+		if p.Err() == nil {
+			t.Errorf("%s: expected error", query)
+		} else {
+			fmt.Println(query, p.Err())
+		}
+	}
+	for _, query := range []string{`select age / 0`, `select age % (age - age)`, `select name - 1`} {
+		rows, err := newTestEngine().Query(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+		}
+		if rows.Err() == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+}
+
+func Test_QueryDistinct(t *testing.T) {
+	e := newTestEngine()
+	for _, query := range []string{
+		`select distinct name order by age`,
+		`select distinct region order by count(name)`,
+	} {
+		if _, err := e.Prepare(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	stmt, err := e.Prepare(`select distinct user from orders union all select distinct region from graph where age > ?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.Query(context.Background(), 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readAll(context.Background(), rows.iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{"alice"}, {"bob"}, {"erin"}, {"cn-beijing"}, {"us-west"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_QueryWindow(t *testing.T) {
+	e := newTestEngine()
+	for _, test := range []struct {
+		query string
+		err   string
+	}{
+		{`select name, rank()`, `syntax error: rank must be followed by over`},
+		{`select name, rank() over (order by rank() over ())`, `syntax error: window within a window at offset 42`},
+		{`select name where rank() over () > 1`, `syntax error: window function rank not allowed in an expression`},
+		{`select name, lag(name, age) over ()`, `syntax error: lag offset age not a non-negative integer`},
+		{`select name, row_number(1) over ()`, `function error: row_number takes 0 arguments, got 1`},
+		{`select name, count(distinct age) over ()`, `aggragation error: distinct count over a window not supported`},
+		{`select region, count(name), row_number() over (order by age) group by region`, `aggragation error: field "age" must appear in group by`},
+	} {
+		if _, err := e.Prepare(test.query); err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+}
+
+func Test_QuerySetOp(t *testing.T) {
+	e := newTestEngine()
+	for _, query := range []string{
+		`select name, age union select name`,
+		`select count(name) union select lower(name)`,
+		`select name limit 1 union select name`,
+		`select name union select user from orders order by user`,
+		`select name union`,
+		`select name union all all select name`,
+	} {
+		if _, err := e.Prepare(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	stmt, err := e.Prepare(`select name where age < ? union select user from orders where total > ? order by name limit ?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.Query(context.Background(), 30, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []interface{}
+	for rows.Next() {
+		var name interface{}
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	if want := []interface{}{"alice", "bob"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func Test_QuerySubquery(t *testing.T) {
+	e := newTestEngine()
+	stmt, err := e.Prepare(`select name where name in (select user from orders where total > ? limit ?) and age > ?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := stmt.NumInput(); n != 3 {
+		t.Errorf("got %d inputs, want 3", n)
+	}
+	rows, err := stmt.Query(context.Background(), 40, 1, 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []interface{}
+	for rows.Next() {
+		var name interface{}
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	if want := []interface{}{"alice"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+	for _, query := range []string{
+		`select name where name in (select user, total from orders)`,
+		`select name where age = (select age from graph)`,
+		`select name where exists (select id from nosuch)`,
+		`select x.name from graph`,
+		`select name where exists (select id from orders where x.total > 10)`,
+		`select name from graph g join graph h on g.age = h.age`,
+		`select h.name from graph g`,
+		`select name where exists (select id from orders o where x.total > 10)`,
+	} {
+		rows, err := e.Query(context.Background(), query)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+		}
+		if err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	rows, err = e.Query(context.Background(), `select graph.name where exists (select id from orders where orders.user = graph.name and orders.total > 150)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readAll(context.Background(), rows.iter); err != nil || !reflect.DeepEqual(got, [][]interface{}{{"bob"}}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for _, test := range []struct{ query, err string }{
+		{`select name where name not between (select user from orders)`, `syntax error: in, like, ilike or regexp expected after not before "between (select user from orders)"`},
+		{`select name where age ! 3`, `syntax error: != or !~ expected before "! 3"`},
+		{`select name where age @ 3`, `syntax error: comparison expected before "@ 3"`},
+	} {
+		if _, err := e.Prepare(test.query); err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+}
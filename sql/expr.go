@@ -0,0 +1,334 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Expr is a scalar expression. Its String is the canonical text of the
+// expression, which also names it as a result column.
+type Expr interface {
+	String() string
+}
+
+// Ident refers to a field, possibly dotted such as "n.age".
+type Ident struct {
+	Name string
+}
+
+// Literal is a constant: an int64, float64, string, bool or nil, or a
+// Placeholder until the query is bound.
+type Literal struct {
+	Value interface{}
+}
+
+// Call calls a function of the registry.
+type Call struct {
+	Name string // lower case
+	Args []Expr
+}
+
+// Subquery is a select nested in a condition: the list of an in, the
+// operand of exists, or a single value to compare with.
+type Subquery struct {
+	Query *model
+}
+
+// Cast converts the value of X to Type. Type checking inserts it where an
+// int meets a float, widening the int. It reads as X, so that the columns
+// named after an expression keep their name.
+type Cast struct {
+	X    Expr
+	Type Type
+}
+
+// Operator is an arithmetic or string operator.
+type Operator int
+
+const (
+	OpAdd    Operator = iota // a + b
+	OpSub                    // a - b
+	OpMul                    // a * b
+	OpDiv                    // a / b
+	OpMod                    // a % b
+	OpConcat                 // a || b
+	OpNeg                    // -a
+)
+
+var operatorNames = [...]string{
+	OpAdd:    "+",
+	OpSub:    "-",
+	OpMul:    "*",
+	OpDiv:    "/",
+	OpMod:    "%",
+	OpConcat: "||",
+	OpNeg:    "-",
+}
+
+func (op Operator) String() string {
+	if op < 0 || int(op) >= len(operatorNames) {
+		return "unknown"
+	}
+	return operatorNames[op]
+}
+
+var itemType2Operator = map[itemType]Operator{
+	itemPlus:   OpAdd,
+	itemMinus:  OpSub,
+	itemMul:    OpMul,
+	itemDiv:    OpDiv,
+	itemMod:    OpMod,
+	itemConcat: OpConcat,
+}
+
+// Precedence of operators, from loosest to tightest. Concatenation binds
+// looser than arithmetic, so a || b + 1 appends the sum.
+const (
+	precConcat = iota + 1
+	precAdd
+	precMul
+	precNeg
+	precOperand
+)
+
+func (op Operator) prec() int {
+	switch op {
+	case OpConcat:
+		return precConcat
+	case OpAdd, OpSub:
+		return precAdd
+	case OpMul, OpDiv, OpMod:
+		return precMul
+	}
+	return precNeg
+}
+
+// Binary applies an operator to two expressions.
+type Binary struct {
+	Op          Operator
+	Left, Right Expr
+}
+
+// Unary applies an operator to one expression.
+type Unary struct {
+	Op Operator
+	X  Expr
+}
+
+func exprPrec(e Expr) int {
+	switch e := e.(type) {
+	case *Binary:
+		return e.Op.prec()
+	case *Unary:
+		return e.Op.prec()
+	case *Cast:
+		return exprPrec(e.X)
+	}
+	return precOperand
+}
+
+func (e *Ident) String() string {
+	return e.Name
+}
+
+func (e *Literal) String() string {
+	return formatValue(e.Value)
+}
+
+func (e *Call) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return e.Name + MarkLeftParen + strings.Join(args, MakrComma+Space) + MarkRightParen
+}
+
+func (e *Cast) String() string {
+	return e.X.String()
+}
+
+func (e *Subquery) String() string {
+	return MarkLeftParen + e.Query.Format(FormatOptions{}) + MarkRightParen
+}
+
+// String parenthesizes the operands binding looser than the operator, and
+// a right operand binding as tight, since operators associate to the left.
+func (e *Binary) String() string {
+	left, right := e.Left.String(), e.Right.String()
+	if exprPrec(e.Left) < e.Op.prec() {
+		left = MarkLeftParen + left + MarkRightParen
+	}
+	if exprPrec(e.Right) <= e.Op.prec() {
+		right = MarkLeftParen + right + MarkRightParen
+	}
+	return left + Space + e.Op.String() + Space + right
+}
+
+func (e *Unary) String() string {
+	x := e.X.String()
+	if exprPrec(e.X) < e.Op.prec() || strings.HasPrefix(x, e.Op.String()) {
+		x = MarkLeftParen + x + MarkRightParen
+	}
+	return e.Op.String() + x
+}
+
+// walkExpr calls fn for e and every expression within it, parents first.
+func walkExpr(e Expr, fn func(Expr)) {
+	fn(e)
+	switch e := e.(type) {
+	case *Call:
+		for _, arg := range e.Args {
+			walkExpr(arg, fn)
+		}
+	case *Binary:
+		walkExpr(e.Left, fn)
+		walkExpr(e.Right, fn)
+	case *Unary:
+		walkExpr(e.X, fn)
+	case *Cast:
+		walkExpr(e.X, fn)
+	}
+}
+
+// rewriteExpr returns a copy of e with each literal l replaced by lit(l).
+func rewriteExpr(e Expr, lit func(*Literal) Expr) Expr {
+	switch e := e.(type) {
+	case *Literal:
+		return lit(e)
+	case *Call:
+		call := &Call{Name: e.Name, Args: make([]Expr, len(e.Args))}
+		for i, arg := range e.Args {
+			call.Args[i] = rewriteExpr(arg, lit)
+		}
+		return call
+	case *Binary:
+		return &Binary{Op: e.Op, Left: rewriteExpr(e.Left, lit), Right: rewriteExpr(e.Right, lit)}
+	case *Unary:
+		return &Unary{Op: e.Op, X: rewriteExpr(e.X, lit)}
+	case *Cast:
+		return &Cast{X: rewriteExpr(e.X, lit), Type: e.Type}
+	}
+	return e
+}
+
+// exprFields returns the fields e refers to.
+func exprFields(e Expr) []string {
+	var fields []string
+	walkExpr(e, func(e Expr) {
+		if ident, ok := e.(*Ident); ok {
+			fields = append(fields, ident.Name)
+		}
+	})
+	return fields
+}
+
+// exprType returns the static type of e, TypeAny when it depends on the
+// data.
+func exprType(e Expr) Type {
+	switch e := e.(type) {
+	case *Literal:
+		switch e.Value.(type) {
+		case int64:
+			return TypeInt
+		case float64:
+			return TypeFloat
+		case string:
+			return TypeString
+		case bool:
+			return TypeBool
+		case time.Time:
+			return TypeTimestamp
+		case Interval:
+			return TypeInterval
+		}
+	case *Call:
+		if fn, ok := lookupFunction(e.Name); ok {
+			return fn.Result
+		}
+	case *Binary:
+		if e.Op == OpConcat {
+			return TypeString
+		}
+		switch t, u := exprType(e.Left), exprType(e.Right); {
+		case t == TypeTimestamp || t == TypeInterval || u == TypeTimestamp || u == TypeInterval:
+			typ, _ := timeArithType(e.Op, t, u)
+			return typ
+		case t == TypeInt && u == TypeInt:
+			return TypeInt
+		case t == TypeFloat || u == TypeFloat:
+			return TypeFloat
+		}
+		return TypeNumber
+	case *Unary:
+		if t := exprType(e.X); t.numeric() || t == TypeInterval {
+			return t
+		}
+		return TypeNumber
+	case *Cast:
+		return e.Type
+	}
+	return TypeAny
+}
+
+// plainFields reports an expression of the model that is not a plain field,
+// or a join, alias, set operator, window or statement other than select, for
+// the translators that map the fields of a single table only.
+func (m *model) plainFields() error {
+	if m.Type != SqlSelect {
+		return fmt.Errorf("%s not supported", m.Type)
+	}
+	if len(m.Joins) > 0 {
+		return fmt.Errorf("%s %s not supported", m.Joins[0].Type, m.Joins[0].Table)
+	}
+	if m.Alias != "" {
+		return fmt.Errorf("table alias %s not supported", m.Alias)
+	}
+	if len(m.SetOps) > 0 {
+		return fmt.Errorf("%s not supported", m.SetOps[0])
+	}
+	if len(m.Windows) > 0 {
+		return fmt.Errorf("window %s not supported", m.Windows[0])
+	}
+	var exprs []Expr
+	exprs = append(exprs, m.Expressions...)
+	for _, agg := range m.Aggragations.Items {
+		if agg.Expr != nil {
+			exprs = append(exprs, agg.Expr)
+		}
+	}
+	walkConditions(m.Conditions, func(c *SingleCondition) {
+		if c.Expr != nil {
+			exprs = append(exprs, c.Expr)
+		}
+		if c.Right != nil {
+			exprs = append(exprs, c.Right)
+		}
+	})
+	if len(exprs) > 0 {
+		return fmt.Errorf("expression %s not supported", exprs[0])
+	}
+	return nil
+}
+
+// distinctGroups returns the model of a select distinct grouped on its
+// fields, for the translators whose targets have no distinct rows: those
+// are the rows of the groups. A select distinct aggragating on groups that
+// are all selected has distinct rows already.
+func (m *model) distinctGroups() (*model, error) {
+	if !m.Distinct {
+		return m, nil
+	}
+	if m.aggragates() {
+		for _, f := range m.GroupBy {
+			if !contains(m.Fields, f) {
+				return nil, fmt.Errorf("select distinct grouped on %s, which is not selected, not supported", f)
+			}
+		}
+		return m, nil
+	}
+	grouped := *m
+	grouped.Distinct = false
+	grouped.GroupBy = m.Fields
+	return &grouped, nil
+}
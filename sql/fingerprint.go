@@ -0,0 +1,371 @@
+package sql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// Fingerprint parses query and returns its fingerprint together with its
+// normalized text. Queries that differ only in literal values, whitespace,
+// keyword case or the order of "and" and "or" operands share a fingerprint.
+func Fingerprint(query string) (fingerprint, normalized string, err error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return "", "", err
+	}
+	normalized = p.Normalize().Format(FormatOptions{})
+	return hash(normalized), normalized, nil
+}
+
+// Fingerprint returns a stable hash of the normalized model, as 16 hex
+// digits.
+func (m *model) Fingerprint() string {
+	return hash(m.Normalize().Format(FormatOptions{}))
+}
+
+func hash(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Normalize returns a copy of the model in which every literal and
+// placeholder, including date, timestamp and interval literals and those
+// within expressions, is replaced by a positional placeholder, an in list
+// by a single one, the rows of an insert by their first, and the operands
+// of "and" and "or" are sorted by their canonical text. Placeholders are
+// numbered in the resulting order.
+func (m *model) Normalize() *model {
+	n := m.normalize()
+	var count int
+	n.number(&count)
+	n.Placeholders = count
+	return n
+}
+
+// normalize copies the model with its values replaced by placeholders, which
+// are numbered later, once the order is known.
+func (m *model) normalize() *model {
+	n := *m
+	n.Names = nil
+	n.Set = normalizeAssignments(m.Set)
+	if len(m.Values) > 0 {
+		n.Values = [][]Assignment{normalizeAssignments(m.Values[0])}
+	}
+	if len(m.Joins) > 0 {
+		n.Joins = make([]*Join, len(m.Joins))
+		for i, j := range m.Joins {
+			join := *j
+			join.On = normalizeCondition(j.On)
+			n.Joins[i] = &join
+		}
+	}
+	n.Conditions = normalizeCondition(m.Conditions)
+	if len(m.SetOps) > 0 {
+		n.SetOps = make([]*SetOp, len(m.SetOps))
+		for i, op := range m.SetOps {
+			setOp := *op
+			setOp.Query = op.Query.normalize()
+			n.SetOps[i] = &setOp
+		}
+	}
+	if m.Limit >= 0 || m.LimitArg != nil {
+		n.Limit = -1
+		n.LimitArg = &Placeholder{}
+	}
+	if m.Offset > 0 || m.OffsetArg != nil {
+		n.Offset = 0
+		n.OffsetArg = &Placeholder{}
+	}
+	return &n
+}
+
+// number numbers the placeholders of a normalized model in place, in the
+// order of its text, counting from *count. The expressions, aggragations
+// and windows are normalized here rather than by normalize, since the
+// columns named after them take their numbered text.
+func (m *model) number(count *int) {
+	numberAssignments(m.Set, count)
+	for _, row := range m.Values {
+		numberAssignments(row, count)
+	}
+	columns := newColumnNormalizer(m, count)
+	for _, c := range m.Columns {
+		columns.column(c)
+	}
+	for _, j := range m.Joins {
+		numberPlaceholders(j.On, count)
+	}
+	numberPlaceholders(m.Conditions, count)
+	for _, op := range m.SetOps {
+		op.Query.number(count)
+	}
+	// expressions and aggragations ordered on but not selected
+	columns.rest()
+	if m.LimitArg != nil {
+		m.LimitArg.Index = *count
+		*count++
+	}
+	if m.OffsetArg != nil {
+		m.OffsetArg.Index = *count
+		*count++
+	}
+}
+
+// columnNormalizer normalizes the expressions, aggragations and windows of
+// a model column by column, numbering their placeholders, and renames the
+// columns after them.
+type columnNormalizer struct {
+	m       *model
+	count   *int
+	rename  map[string]string // the text of the columns normalized so far
+	exprs   []Expr
+	aggs    []aggItem
+	windows []*Window
+}
+
+func newColumnNormalizer(m *model, count *int) *columnNormalizer {
+	return &columnNormalizer{
+		m:       m,
+		count:   count,
+		rename:  make(map[string]string),
+		exprs:   append([]Expr(nil), m.Expressions...),
+		aggs:    append([]aggItem(nil), m.Aggragations.Items...),
+		windows: append([]*Window(nil), m.Windows...),
+	}
+}
+
+// column normalizes the expression, aggragation or window named c, if not
+// done yet. A window first normalizes the columns it partitions and orders
+// on.
+func (z *columnNormalizer) column(c string) {
+	if _, ok := z.rename[c]; ok {
+		return
+	}
+	z.rename[c] = c
+	var expr Expr
+	for i, e := range z.m.Expressions {
+		if e.String() == c {
+			if expr == nil {
+				expr = normalizeExpr(e)
+				numberExpr(expr, z.count)
+			}
+			z.exprs[i] = expr
+			z.rename[c] = expr.String()
+		}
+	}
+	var agg *aggItem
+	for i, a := range z.m.Aggragations.Items {
+		if a.String() == c {
+			if agg == nil {
+				normalized := normalizeAgg(a, z.count)
+				agg = &normalized
+			}
+			z.aggs[i] = *agg
+			z.rename[c] = agg.String()
+		}
+	}
+	var window *Window
+	for i, w := range z.m.Windows {
+		if w.String() == c {
+			if window == nil {
+				for _, column := range w.columns() {
+					z.column(column)
+				}
+				window = z.window(w)
+			}
+			z.windows[i] = window
+			z.rename[c] = window.String()
+		}
+	}
+}
+
+// window copies w with its literals replaced by numbered placeholders, but
+// for the offset of lag and lead, which must be a constant.
+func (z *columnNormalizer) window(w *Window) *Window {
+	window := *w
+	if w.Func == "" {
+		window.Agg = normalizeAgg(w.Agg, z.count)
+	}
+	if len(w.Args) > 0 {
+		window.Args = make([]Expr, len(w.Args))
+		for i, arg := range w.Args {
+			if i != 1 {
+				arg = normalizeExpr(arg)
+				numberExpr(arg, z.count)
+			}
+			window.Args[i] = arg
+		}
+	}
+	window.PartitionBy = renamed(w.PartitionBy, z.rename)
+	window.OrderBy = renamedOrder(w.OrderBy, z.rename)
+	return &window
+}
+
+// rest normalizes what is not selected, and renames the columns of the
+// model.
+func (z *columnNormalizer) rest() {
+	for _, e := range z.m.Expressions {
+		z.column(e.String())
+	}
+	for _, a := range z.m.Aggragations.Items {
+		z.column(a.String())
+	}
+	for _, w := range z.m.Windows {
+		z.column(w.String())
+	}
+	m := z.m
+	m.Expressions, m.Aggragations.Items, m.Windows = z.exprs, z.aggs, z.windows
+	m.Columns = renamed(m.Columns, z.rename)
+	m.GroupBy = renamed(m.GroupBy, z.rename)
+	m.OrderBy = renamedOrder(m.OrderBy, z.rename)
+}
+
+// normalizeAgg copies agg with the literals of its argument and its
+// constants replaced by numbered placeholders.
+func normalizeAgg(agg aggItem, count *int) aggItem {
+	if agg.Expr != nil {
+		agg.Expr = normalizeExpr(agg.Expr)
+		numberExpr(agg.Expr, count)
+		agg.Field = agg.Expr.String()
+	}
+	if len(agg.Params) > 0 {
+		params := make([]interface{}, len(agg.Params))
+		for i := range params {
+			params[i] = Placeholder{Index: *count}
+			*count++
+		}
+		agg.Params = params
+	}
+	return agg
+}
+
+// normalizeExpr copies e with its literals replaced by placeholders, which
+// are numbered later.
+func normalizeExpr(e Expr) Expr {
+	if e == nil {
+		return nil
+	}
+	return rewriteExpr(e, func(*Literal) Expr {
+		return &Literal{Value: Placeholder{}}
+	})
+}
+
+// numberExpr numbers the placeholders of a normalized e in place, in the
+// order of its text, counting from *count.
+func numberExpr(e Expr, count *int) {
+	if e == nil {
+		return
+	}
+	walkExpr(e, func(e Expr) {
+		if l, ok := e.(*Literal); ok {
+			l.Value = Placeholder{Index: *count}
+			*count++
+		}
+	})
+}
+
+// normalizeAssignments copies list with its values replaced by
+// placeholders.
+func normalizeAssignments(list []Assignment) []Assignment {
+	if list == nil {
+		return nil
+	}
+	n := make([]Assignment, len(list))
+	for i, a := range list {
+		n[i] = a
+		if a.Expr == nil {
+			n[i].Value = Placeholder{}
+		} else {
+			n[i].Expr = normalizeExpr(a.Expr)
+		}
+	}
+	return n
+}
+
+// numberAssignments numbers the placeholders of list in place, counting
+// from *count.
+func numberAssignments(list []Assignment, count *int) {
+	for i := range list {
+		if list[i].Expr == nil {
+			list[i].Value = Placeholder{Index: *count}
+			*count++
+		} else {
+			numberExpr(list[i].Expr, count)
+		}
+	}
+}
+
+// normalizeCondition copies c with its values replaced by placeholders and
+// its operands sorted. The placeholders are numbered later, once the order
+// is known.
+func normalizeCondition(c Condition) Condition {
+	switch c := c.(type) {
+	case *SingleCondition:
+		single := *c
+		if c.Expr != nil {
+			single.Expr = normalizeExpr(c.Expr)
+			single.Field = single.Expr.String()
+		}
+		switch right := c.Right.(type) {
+		case *Subquery:
+			single.Right = &Subquery{Query: right.Query.normalize()}
+		case nil:
+			if _, ok := c.Value.([]interface{}); ok {
+				single.Value = []interface{}{Placeholder{}}
+			} else {
+				single.Value = Placeholder{}
+			}
+		default:
+			single.Right = normalizeExpr(right)
+		}
+		return &single
+	case *MultiCondition:
+		multi := &MultiCondition{Logic: c.Logic}
+		for _, sub := range c.SubConditions {
+			multi.add(normalizeCondition(sub))
+		}
+		if multi.Logic != LogicNot {
+			keys := make(map[Condition]string, len(multi.SubConditions))
+			for _, sub := range multi.SubConditions {
+				keys[sub] = formatCondition(sub)
+			}
+			sort.SliceStable(multi.SubConditions, func(i, j int) bool {
+				return keys[multi.SubConditions[i]] < keys[multi.SubConditions[j]]
+			})
+		}
+		return multi
+	}
+	return c
+}
+
+// numberPlaceholders numbers the placeholders of c in place, counting from
+// *count.
+func numberPlaceholders(c Condition, count *int) Condition {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if c.Expr != nil {
+			numberExpr(c.Expr, count)
+			c.Field = c.Expr.String()
+		}
+		switch right := c.Right.(type) {
+		case *Subquery:
+			right.Query.number(count)
+		case nil:
+			if list, ok := c.Value.([]interface{}); ok {
+				list[0] = Placeholder{Index: *count}
+			} else {
+				c.Value = Placeholder{Index: *count}
+			}
+			*count++
+		default:
+			numberExpr(right, count)
+		}
+	case *MultiCondition:
+		for _, sub := range c.SubConditions {
+			numberPlaceholders(sub, count)
+		}
+	}
+	return c
+}
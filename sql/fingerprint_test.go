@@ -0,0 +1,98 @@
+package sql
+
+import "testing"
+
+type fingerprintTest struct {
+	a, b string
+	same bool
+}
+
+var fingerprintTests = []fingerprintTest{
+	{`select name where age > 3`, `SELECT name   WHERE age>30`, true},
+	{`select name where age > 3 and region = "cn"`, `select name where region = "us" and age > 1`, true},
+	{`select name where a = 1 or (b = 2 and c = 3)`, `select name where (c = 9 and b = 8) or a = 7`, true},
+	{`select name where id in (1, 2, 3) limit 10`, `select name where id in (4) limit 5`, true},
+	{`select name where age > :age`, `select name where age > ?`, true},
+	{`select name where age > 3`, `select name where age < 3`, false},
+	{`select name where age > 3`, `select age where age > 3`, false},
+	{`select name where a = 1 and b = 2`, `select name where a = 1 or b = 2`, false},
+	{`select name from people`, `select name`, false},
+	{`select lower(name) where substr(name, 1, 2) = "al"`, `select lower(name) where substr(name, 2, 3) = "bo"`, true},
+	{`select name, coalesce(age, 0) order by coalesce(age, 0)`, `select name, coalesce(age, 5) order by coalesce(age, 5)`, true},
+	{`select name where substr(name, 1, 2) = "al"`, `select name where substr(name, 1) = "al"`, false},
+	{`select name where age * 2 > 10`, `select name where age * 3 > 10`, true},
+	{`select name where age * 2 > 10`, `select name where age + 2 > 10`, false},
+	{`select name, age + 1 order by age + 1`, `select name, age + 5 order by age + 5`, true},
+	{`select lower(name) || "x" where substr(name, 1, 2) = "al"`, `select lower(name) || "y" where substr(name, 2, 3) = "bo"`, true},
+	{`select region, percentile(age, 0.9), sum(age * 2) group by region`, `select region, percentile(age, 0.5), sum(age * 3) group by region`, true},
+	{`select name, lag(name, 1, "none") over (order by age + 1)`, `select name, lag(name, 1, "-") over (order by age + 2)`, true},
+	{`select name, lag(name, 1) over (order by age)`, `select name, lag(name, 2) over (order by age)`, false},
+	{`select name where a = b`, `select name where a = "b"`, false},
+	{`select name where a < b and c = 1`, `select name where c = 2 and a < b`, true},
+	{`select name where a < b + 1`, `select name where a < b + 2`, true},
+	{`select name where a < b + 1`, `select name where a < b - 1`, false},
+	{`select name where a * 2 = b + 1 and c = 1`, `select name where c = 5 and a * 4 = b + 3`, true},
+	{`select name where lower(a) = lower("X")`, `select name where lower(a) = lower("y")`, true},
+	{`select name where created > now() - interval '7 days'`, `select name where created > now() - interval '1 hour'`, true},
+	{`select name where created > date '2024-01-01' + interval '1 day'`, `select name where created > date '2025-06-30' + interval '2 days'`, true},
+	{`select name, created + interval '1 day' where created < timestamp '2024-01-01 10:00:00'`, `select name, created + interval '3 days' where created < timestamp '2025-01-01 00:00:00'`, true},
+	{`update t set due = now() + interval '1 day'`, `update t set due = now() + interval '2 days'`, true},
+	{`select name where id in (select id from t where x = 1 limit 2)`, `select name where id in (select id from t where x = 5 limit 9)`, true},
+	{`select name where id in (select id from t where x = 1)`, `select name where id in (select id from u where x = 1)`, false},
+	{`select a from t where x = 1 union select a from u limit 3`, `select a from t where x = 2 union select a from u limit 9`, true},
+	{`select a from t union select a from u`, `select a from t union all select a from u`, false},
+	{`insert into t (a, b) values (1, "x"), (2, "y")`, `insert into t (a, b) values (3, ?)`, true},
+	{`update t set a = 1 where b = 2`, `update t set a = a + 1 where b = 2`, false},
+	{`update t set b = b * 2 where c = 1`, `update t set b = b * 10 where c = 3`, true},
+}
+
+func Test_Fingerprint(t *testing.T) {
+	for _, test := range fingerprintTests {
+		fa, na, err := Fingerprint(test.a)
+		if err != nil {
+			t.Fatalf("%s: %v", test.a, err)
+		}
+		fb, nb, err := Fingerprint(test.b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.b, err)
+		}
+		if (fa == fb) != test.same {
+			t.Errorf("%q (%s) and %q (%s): same fingerprint %v, want %v", test.a, na, test.b, nb, fa == fb, test.same)
+		}
+		if again, _, _ := Fingerprint(na); again != fa {
+			t.Errorf("%s: normalized %q fingerprints differently", test.a, na)
+		}
+	}
+	_, normalized, _ := Fingerprint(`select name where (c = "x" and b in (1, 2)) or a = 7 limit 3`)
+	if want := `select name from graph where a = $1 or b in ($2) and c = $3 limit $4`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select name where a + 1 < b * 2 and c = 3`)
+	if want := `select name from graph where a + $1 < b * $2 and c = $3`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select region, percentile(age, 0.5), count(age * 2) where age % 3 = 1 group by region order by count(age * 2) desc limit 5`)
+	if want := `select region, percentile(age, $1), count(age * $2) from graph where age % $3 = $4 group by region order by count(age * $2) desc limit $5`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select name, age * 2 order by age * 2, age - 1`)
+	if want := `select name, age * $1 from graph order by age * $1, age - $2`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select name, substr(name, 1, 2) where length(name) > 3 order by substr(name, 1, 2)`)
+	if want := `select name, substr(name, $1, $2) from graph where length(name) > $3 order by substr(name, $1, $2)`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`update t set a = 1, b = b + 1 where c = "x"`)
+	if want := `update t set a = $1, b = b + $2 where c = $3`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select name where created > now() - interval '7 days'`)
+	if want := `select name from graph where created > now() - $1`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+	_, normalized, _ = Fingerprint(`select a from t where x = 1 except select b from u where y = 2 limit 3`)
+	if want := `select a from t where x = $1 except select b from u where y = $2 limit $3`; normalized != want {
+		t.Errorf("got %s, want %s", normalized, want)
+	}
+}
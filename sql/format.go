@@ -0,0 +1,302 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatOptions control the layout of Format.
+type FormatOptions struct {
+	// Width is the line length beyond which a query is laid out over several
+	// lines, one clause per line, and a field list or condition tree that
+	// still does not fit is broken one item per line. Zero keeps the query
+	// on a single line.
+	Width int
+	// Indent prefixes the items of a broken clause. It defaults to two
+	// spaces.
+	Indent string
+}
+
+// Format parses query and returns it in canonical form.
+func Format(query string, opts FormatOptions) (string, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return "", err
+	}
+	return p.Format(opts), nil
+}
+
+// Format returns the query of the model in canonical form: lower case
+// keywords, single spaces, quoted strings, and parentheses only where the
+// precedence of "not", "and" and "or" needs them. Parsing the result yields
+// an equal model.
+func (m *model) Format(opts FormatOptions) string {
+	clauses := m.clauses()
+	parts := make([]string, len(clauses))
+	for i, c := range clauses {
+		parts[i] = c.String()
+	}
+	line := strings.Join(parts, Space)
+	if opts.Width <= 0 || len(line) <= opts.Width {
+		return line
+	}
+	indent := opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	for i, c := range clauses {
+		if len(parts[i]) > opts.Width && len(c.items) > 1 {
+			parts[i] = c.broken(indent)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// clause is a keyword followed by items joined by sep, either a comma or a
+// logic operator.
+type clause struct {
+	keyword string
+	items   []string
+	sep     string
+}
+
+func (c clause) String() string {
+	if len(c.items) == 0 {
+		return c.keyword
+	}
+	sep := c.sep + Space
+	if c.sep != MakrComma {
+		sep = Space + sep
+	}
+	return c.keyword + Space + strings.Join(c.items, sep)
+}
+
+// broken lays the clause out one item per line. Commas end their line while
+// logic operators start the line of the following item.
+func (c clause) broken(indent string) string {
+	var b strings.Builder
+	b.WriteString(c.keyword)
+	for i, item := range c.items {
+		b.WriteString("\n" + indent)
+		if i > 0 && c.sep != MakrComma {
+			b.WriteString(c.sep + Space)
+		}
+		b.WriteString(item)
+		if i < len(c.items)-1 && c.sep == MakrComma {
+			b.WriteString(c.sep)
+		}
+	}
+	return b.String()
+}
+
+// clauses returns the clauses of the model, the queries of its set
+// operators following its group by, each after its operator.
+func (m *model) clauses() []clause {
+	switch m.Type {
+	case SqlInsert:
+		return m.insertClauses()
+	case SqlUpdate:
+		set := clause{keyword: KeySet, sep: MakrComma}
+		for _, a := range m.Set {
+			set.items = append(set.items, a.Column+Space+ComparatorEQ.String()+Space+a.String())
+		}
+		return m.appendWhere([]clause{{keyword: KeyUpdate, items: []string{m.TableName}}, set})
+	case SqlDelete:
+		return m.appendWhere([]clause{{keyword: KeyDelete + Space + KeyFrom, items: []string{m.TableName}}})
+	case SqlCreate:
+		columns := make([]string, len(m.Schema))
+		for i, c := range m.Schema {
+			columns[i] = c.String()
+		}
+		table := m.TableName + Space + MarkLeftParen + strings.Join(columns, MakrComma+Space) + MarkRightParen
+		return []clause{{keyword: m.Type.String(), items: []string{table}}}
+	}
+	clauses := m.selectClauses()
+	for _, op := range m.SetOps {
+		clauses = append(clauses, clause{keyword: op.String()})
+		clauses = append(clauses, op.Query.selectClauses()...)
+	}
+	if len(m.OrderBy) > 0 {
+		order := clause{keyword: "order by", sep: MakrComma}
+		for _, o := range m.OrderBy {
+			if o.Desc {
+				order.items = append(order.items, o.Column+Space+KeyDesc)
+			} else {
+				order.items = append(order.items, o.Column)
+			}
+		}
+		clauses = append(clauses, order)
+	}
+	if m.LimitArg != nil {
+		clauses = append(clauses, clause{keyword: KeyLimit, items: []string{m.LimitArg.String()}})
+	} else if m.Limit >= 0 {
+		clauses = append(clauses, clause{keyword: KeyLimit, items: []string{strconv.Itoa(m.Limit)}})
+	}
+	if m.OffsetArg != nil {
+		clauses = append(clauses, clause{keyword: KeyOffset, items: []string{m.OffsetArg.String()}})
+	} else if m.Offset > 0 {
+		clauses = append(clauses, clause{keyword: KeyOffset, items: []string{strconv.Itoa(m.Offset)}})
+	}
+	return clauses
+}
+
+// selectClauses returns the clauses of the model up to its group by.
+func (m *model) selectClauses() []clause {
+	keyword := KeySelect
+	if m.Distinct {
+		keyword += Space + KeyDistinct
+	}
+	clauses := []clause{
+		{keyword: keyword, items: m.Columns, sep: MakrComma},
+		{keyword: KeyFrom, items: []string{m.formatFrom()}},
+	}
+	clauses = m.appendWhere(clauses)
+	if len(m.GroupBy) > 0 {
+		clauses = append(clauses, clause{keyword: "group by", items: m.GroupBy, sep: MakrComma})
+	}
+	return clauses
+}
+
+// appendWhere appends the where clause of the model, if it has one.
+func (m *model) appendWhere(clauses []clause) []clause {
+	if m.Conditions != nil {
+		where := clause{keyword: KeyWhere, items: []string{formatCondition(m.Conditions)}}
+		if multi, ok := m.Conditions.(*MultiCondition); ok && multi.Logic != LogicNot {
+			where.items = make([]string, len(multi.SubConditions))
+			for i, sub := range multi.SubConditions {
+				where.items[i] = formatOperand(sub, conditionPrec(multi))
+			}
+			where.sep = multi.Logic.String()
+		}
+		clauses = append(clauses, where)
+	}
+	return clauses
+}
+
+// insertClauses returns the clauses of an insert. The columns are those of
+// the first row, the same for every row.
+func (m *model) insertClauses() []clause {
+	table := m.TableName
+	if len(m.Values) > 0 && m.Values[0][0].Column != "" {
+		columns := make([]string, len(m.Values[0]))
+		for i, a := range m.Values[0] {
+			columns[i] = a.Column
+		}
+		table += Space + MarkLeftParen + strings.Join(columns, MakrComma+Space) + MarkRightParen
+	}
+	values := clause{keyword: KeyValues, sep: MakrComma}
+	for _, row := range m.Values {
+		items := make([]string, len(row))
+		for i, a := range row {
+			items[i] = a.String()
+		}
+		values.items = append(values.items, MarkLeftParen+strings.Join(items, MakrComma+Space)+MarkRightParen)
+	}
+	return []clause{{keyword: KeyInsert + Space + KeyInto, items: []string{table}}, values}
+}
+
+// formatFrom returns the tables of the from clause. Cross joins are written
+// with a comma.
+func (m *model) formatFrom() string {
+	s := formatTable(m.TableName, m.Alias)
+	for _, j := range m.Joins {
+		if j.Type == JoinCross {
+			s += MakrComma + Space + formatTable(j.Table, j.Alias)
+			continue
+		}
+		s += Space + j.Type.String() + Space + formatTable(j.Table, j.Alias) + Space + KeyOn + Space + formatCondition(j.On)
+	}
+	return s
+}
+
+func formatTable(name, alias string) string {
+	if alias == "" {
+		return name
+	}
+	return name + Space + alias
+}
+
+// Precedence of conditions, from loosest to tightest.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precSingle
+)
+
+func conditionPrec(c Condition) int {
+	if multi, ok := c.(*MultiCondition); ok {
+		switch multi.Logic {
+		case LogicOr:
+			return precOr
+		case LogicAnd:
+			return precAnd
+		}
+		return precNot
+	}
+	return precSingle
+}
+
+func formatCondition(c Condition) string {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if c.Comparator == ComparatorEXISTS {
+			return KeyExists + Space + c.Right.String()
+		}
+		if c.Right != nil {
+			return c.Field + Space + c.Comparator.String() + Space + c.Right.String()
+		}
+		s := c.Field + Space + c.Comparator.String() + Space + formatValue(c.Value)
+		if c.Escape != "" {
+			s += Space + KeyEscape + Space + formatValue(c.Escape)
+		}
+		return s
+	case *MultiCondition:
+		if c.Logic == LogicNot {
+			return KeyNot + Space + formatOperand(c.SubConditions[0], precNot)
+		}
+		items := make([]string, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			items[i] = formatOperand(sub, conditionPrec(c))
+		}
+		return strings.Join(items, Space+c.Logic.String()+Space)
+	}
+	return fmt.Sprint(c)
+}
+
+// formatOperand parenthesizes c when it binds looser than prec.
+func formatOperand(c Condition, prec int) string {
+	if conditionPrec(c) < prec {
+		return MarkLeftParen + formatCondition(c) + MarkRightParen
+	}
+	return formatCondition(c)
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		return s
+	case Placeholder:
+		return v.String()
+	case time.Time:
+		return KeyTimestamp + Space + strconv.Quote(v.Format(time.RFC3339Nano))
+	case Interval:
+		return KeyInterval + Space + strconv.Quote(v.String())
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return MarkLeftParen + strings.Join(items, MakrComma+Space) + MarkRightParen
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+type formatTest struct {
+	input  string
+	width  int
+	output string
+}
+
+var formatTests = []formatTest{
+	{
+		`select  name,count( id )   where age>3 group by name`, 0,
+		`select name, count(id) from graph where age > 3 group by name`,
+	},
+	{
+		`select name where (a = 1 and (b = 2 and c = 3)) or (d = "x" or not (e = 4 or f = 5))`, 0,
+		`select name from graph where a = 1 and b = 2 and c = 3 or d = "x" or not (e = 4 or f = 5)`,
+	},
+	{
+		`select name where a = 1 and (b = 2 or c = 3) and not not d like "a%"`, 0,
+		`select name from graph where a = 1 and (b = 2 or c = 3) and not not d like "a%"`,
+	},
+	{
+		`select name where a ILIKE "x!%" Escape "!" and b not like "y%"`, 0,
+		`select name from graph where a ilike "x!%" escape "!" and not b like "y%"`,
+	},
+	{
+		`select name where a like "x\\%" ESCAPE '\'`, 0,
+		`select name from graph where a like "x\\%" escape "\\"`,
+	},
+	{
+		`select name where s = "say \"hi\"" and f = 2.0 and t = true and u = bare and v in (1, "2", ?)`, 0,
+		`select name from graph where s = "say \"hi\"" and f = 2.0 and t = true and u = bare and v in (1, "2", $1)`,
+	},
+	{
+		`select region, count(id) from people group by region order by count(id) desc, region asc limit :n`, 0,
+		`select region, count(id) from people group by region order by count(id) desc, region limit :n`,
+	},
+	{
+		`select COUNT( DISTINCT id ), Avg(age), Percentile(age,1), string_agg(name,", "), distinct(x) group by region`, 0,
+		`select count(distinct id), average(age), percentile(age, 1.0), string_agg(name, ", "), distinct(x) from graph group by region`,
+	},
+	{
+		`SELECT DISTINCT region,name from people order by name`, 0,
+		`select distinct region, name from people order by name`,
+	},
+	{
+		`select name,RANK() over (partition by region order by age DESC, name),avg(age) OVER()`, 0,
+		`select name, rank() over (partition by region order by age desc, name), average(age) over () from graph`,
+	},
+	{
+		`select name LIMIT 5 Offset 10`, 0,
+		`select name from graph limit 5 offset 10`,
+	},
+	{
+		`select LOWER( name ),sum(Abs(age)) where Substr(region,1,2)="cn" group by name order by length(name)`, 0,
+		`select lower(name), sum(abs(age)) from graph where substr(region, 1, 2) = "cn" group by name order by length(name)`,
+	},
+	{
+		`select (a+b)*c, a-(b-c), a-b-c, -(a+1), - -2, x||y+1, (x||y)+1 where (a + b) * 2 > 10 and ((a) - 1 = 2 or -a < 3) order by a*b`, 0,
+		`select (a + b) * c, a - (b - c), a - b - c, -(a + 1), 2, x || y + 1, (x || y) + 1 from graph where (a + b) * 2 > 10 and (a - 1 = 2 or -a < 3) order by a * b`,
+	},
+	{
+		`select name where src.region=dst.region and created_at<updated_at+1 and x != -y`, 0,
+		`select name from graph where src.region = dst.region and created_at < updated_at + 1 and x != -y`,
+	},
+	{
+		`select name where id IN ( SELECT user_id FROM orders WHERE total>100 ) and not exists(select id from bans where bans.user=graph.id) and age>(select average(age))`, 0,
+		`select name from graph where id in (select user_id from orders where total > 100) and not exists (select id from bans where bans.user = graph.id) and age > (select average(age) from graph)`,
+	},
+	{
+		`select u.name, o.total from users AS u join orders o on u.id=o.user_id LEFT OUTER JOIN bans on bans.user_id = u.id and bans.active = true, tags cross join roles where o.total > 3`, 0,
+		`select u.name, o.total from users u join orders o on u.id = o.user_id left join bans on bans.user_id = u.id and bans.active = true, tags, roles where o.total > 3`,
+	},
+	{
+		`select name from people UNION ALL select user from orders where total>3 intersect select name from bans order by name desc limit 3`, 0,
+		`select name from people union all select user from orders where total > 3 intersect select name from bans order by name desc limit 3`,
+	},
+	{
+		`INSERT INTO items(id,name) VALUES (1, "cup"),( -2, ? ),(3, upper( "x" ))`, 0,
+		`insert into items (id, name) values (1, "cup"), (-2, $1), (3, upper("x"))`,
+	},
+	{
+		`update items set price=price*2, name = :name where id in (1,2)`, 0,
+		`update items set price = price * 2, name = :name where id in (1, 2)`,
+	},
+	{
+		`DELETE  FROM items where price>3 or name like "a%"`, 0,
+		`delete from items where price > 3 or name like "a%"`,
+	},
+	{
+		`CREATE TABLE events(id INTEGER,name text , at DateTime)`, 0,
+		`create table events (id int, name string, at timestamp)`,
+	},
+	{
+		`select name, age where age > 3`, 50,
+		`select name, age from graph where age > 3`,
+	},
+	{
+		`select name, region, count(id), max(age) from people where region = "cn-beijing" and (age > 30 or name like "a%") group by name, region order by name limit 10`, 30,
+		"select\n  name,\n  region,\n  count(id),\n  max(age)\nfrom people\nwhere\n  region = \"cn-beijing\"\n  and (age > 30 or name like \"a%\")\ngroup by name, region\norder by name\nlimit 10",
+	},
+}
+
+func parseModel(t *testing.T, query string) *model {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return &p.model
+}
+
+func Test_Format(t *testing.T) {
+	for _, test := range formatTests {
+		m := parseModel(t, test.input)
+		out := m.Format(FormatOptions{Width: test.width})
+		if out != test.output {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.input, out, test.output)
+		}
+		if again := parseModel(t, out); !reflect.DeepEqual(m, again) {
+			t.Errorf("%s: round trip through %q gives %+v, want %+v", test.input, out, again, m)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Type is the type of a value in a query.
+type Type int
+
+const (
+	TypeAny       Type = iota // any value
+	TypeInt                   // int64
+	TypeFloat                 // float64
+	TypeNumber                // int64 or float64
+	TypeString                // string
+	TypeBool                  // bool
+	TypeTimestamp             // time.Time
+	TypeInterval              // Interval
+)
+
+var typeNames = [...]string{
+	TypeAny:       "any",
+	TypeInt:       "int",
+	TypeFloat:     "float",
+	TypeNumber:    "number",
+	TypeString:    "string",
+	TypeBool:      "bool",
+	TypeTimestamp: "timestamp",
+	TypeInterval:  "interval",
+}
+
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return "unknown"
+	}
+	return typeNames[t]
+}
+
+// numeric reports whether the values of t are numbers.
+func (t Type) numeric() bool {
+	return t == TypeInt || t == TypeFloat || t == TypeNumber
+}
+
+// convert converts v to the type t. Strings holding a number or a bool are
+// accepted for those types, since file backed tables carry every value as
+// text.
+func (t Type) convert(v interface{}) (interface{}, bool) {
+	switch t {
+	case TypeAny:
+		return v, true
+	case TypeInt:
+		if n, ok := toInt(v); ok {
+			return n, true
+		}
+		if s, ok := v.(string); ok {
+			n, err := strconv.ParseInt(s, 10, 64)
+			return n, err == nil
+		}
+	case TypeFloat:
+		if f, ok := toNumber(v); ok {
+			return f, true
+		}
+	case TypeNumber:
+		if n, ok := TypeInt.convert(v); ok {
+			return n, true
+		}
+		return TypeFloat.convert(v)
+	case TypeString:
+		s, ok := v.(string)
+		return s, ok
+	case TypeBool:
+		if s, ok := v.(string); ok {
+			b, err := strconv.ParseBool(s)
+			return b, err == nil
+		}
+		b, ok := v.(bool)
+		return b, ok
+	case TypeTimestamp:
+		if s, ok := v.(string); ok {
+			return parseTimestamp(s)
+		}
+		ts, ok := v.(time.Time)
+		return ts, ok
+	case TypeInterval:
+		switch v := v.(type) {
+		case Interval:
+			return v, true
+		case time.Duration:
+			return Interval{Duration: v}, true
+		case string:
+			iv, err := ParseInterval(v)
+			return iv, err == nil
+		}
+	}
+	return nil, false
+}
+
+// timestampLayouts are the layouts of the strings accepted as timestamps.
+// Those without a time zone are taken as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Function is a scalar function callable in queries, such as lower(name).
+type Function struct {
+	// Args are the types of the arguments. The last Optional of them may be
+	// left out, and the last one repeats when Variadic is set.
+	Args     []Type
+	Optional int
+	Variadic bool
+	// Result is the type of the result. A TypeNumber result follows the
+	// numeric arguments, being an int64 when they are.
+	Result Type
+	// Nulls passes null arguments to Call. Otherwise a null argument makes
+	// the result null without calling it.
+	Nulls bool
+	// Call computes the result from the arguments, converted to their
+	// declared types.
+	Call func(args []interface{}) (interface{}, error)
+}
+
+var funcError = errors.New("function error")
+
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string]*Function{
+		"lower":    {Args: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.ToLower)},
+		"upper":    {Args: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.ToUpper)},
+		"trim":     {Args: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.TrimSpace)},
+		"length":   {Args: []Type{TypeString}, Result: TypeInt, Call: length},
+		"substr":   {Args: []Type{TypeString, TypeInt, TypeInt}, Optional: 1, Result: TypeString, Call: substr},
+		"abs":      {Args: []Type{TypeNumber}, Result: TypeNumber, Call: abs},
+		"round":    {Args: []Type{TypeNumber, TypeInt}, Optional: 1, Result: TypeNumber, Call: round},
+		"coalesce": {Args: []Type{TypeAny}, Variadic: true, Result: TypeAny, Nulls: true, Call: coalesce},
+		"now":      {Result: TypeTimestamp, Call: now},
+	}
+)
+
+// RegisterFunction makes fn callable in queries as name, which matches in
+// any case. It replaces a function registered under the same name, but
+// cannot take the name of an aggragation or a window function.
+func RegisterFunction(name string, fn Function) error {
+	name = strings.ToLower(name)
+	if name == "" || !strings.ContainsRune(letter, rune(name[0])) || strings.Trim(name, identifier) != "" {
+		return fmt.Errorf("%v: name %q not valid", funcError, name)
+	}
+	if _, ok := AggragationToType[name]; ok {
+		return fmt.Errorf("%v: %s is an aggragation", funcError, name)
+	}
+	if _, ok := windowFunctions[name]; ok {
+		return fmt.Errorf("%v: %s is a window function", funcError, name)
+	}
+	if fn.Call == nil || len(fn.Args) == 0 && fn.Variadic || fn.Optional < 0 || fn.Optional > len(fn.Args) {
+		return fmt.Errorf("%v: %s not valid", funcError, name)
+	}
+	functionsMu.Lock()
+	functions[name] = &fn
+	functionsMu.Unlock()
+	return nil
+}
+
+func lookupFunction(name string) (*Function, bool) {
+	functionsMu.RLock()
+	fn, ok := functions[strings.ToLower(name)]
+	functionsMu.RUnlock()
+	return fn, ok
+}
+
+// checkArgs reports a number of arguments fn does not take.
+func (fn *Function) checkArgs(name string, n int) error {
+	min, max := len(fn.Args)-fn.Optional, len(fn.Args)
+	switch {
+	case fn.Variadic && n >= min, n >= min && n <= max:
+		return nil
+	case fn.Variadic:
+		return fmt.Errorf("%v: %s takes at least %d arguments, got %d", funcError, name, min, n)
+	case min == max:
+		return fmt.Errorf("%v: %s takes %d arguments, got %d", funcError, name, min, n)
+	}
+	return fmt.Errorf("%v: %s takes %d to %d arguments, got %d", funcError, name, min, max, n)
+}
+
+// argType returns the declared type of the i-th argument.
+func (fn *Function) argType(i int) Type {
+	if i >= len(fn.Args) {
+		return fn.Args[len(fn.Args)-1]
+	}
+	return fn.Args[i]
+}
+
+// call converts args and calls fn, returning a literal.
+func (fn *Function) call(name string, args []interface{}) (interface{}, error) {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			if !fn.Nulls {
+				return nil, nil
+			}
+			continue
+		}
+		v, ok := fn.argType(i).convert(arg)
+		if !ok {
+			return nil, fmt.Errorf("%v: %s argument %d is %T, not %s", funcError, name, i+1, arg, fn.argType(i))
+		}
+		converted[i] = v
+	}
+	v, err := fn.Call(converted)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s: %v", funcError, name, err)
+	}
+	if v, err = literal(v); err != nil {
+		return nil, fmt.Errorf("%v: %s result: %v", funcError, name, err)
+	}
+	return v, nil
+}
+
+func stringFunc(f func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		return f(args[0].(string)), nil
+	}
+}
+
+func length(args []interface{}) (interface{}, error) {
+	return int64(utf8.RuneCountInString(args[0].(string))), nil
+}
+
+// substr returns the characters of s from the 1-based start on, as many as
+// the optional length. Like SQL, positions before the first character count
+// towards the length.
+func substr(args []interface{}) (interface{}, error) {
+	s := []rune(args[0].(string))
+	start := args[1].(int64) - 1
+	end := int64(len(s))
+	if len(args) > 2 {
+		n := args[2].(int64)
+		if n < 0 {
+			return nil, fmt.Errorf("negative length %d", n)
+		}
+		if start+n < end {
+			end = start + n
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= end {
+		return "", nil
+	}
+	return string(s[start:end]), nil
+}
+
+func abs(args []interface{}) (interface{}, error) {
+	if n, ok := args[0].(int64); ok {
+		if n < 0 {
+			return -n, nil
+		}
+		return n, nil
+	}
+	return math.Abs(args[0].(float64)), nil
+}
+
+// round rounds half away from zero to the optional number of decimal
+// digits. Integers are returned as they are.
+func round(args []interface{}) (interface{}, error) {
+	f, ok := args[0].(float64)
+	if !ok {
+		return args[0], nil
+	}
+	var digits int64
+	if len(args) > 1 {
+		digits = args[1].(int64)
+	}
+	scale := math.Pow(10, float64(digits))
+	return math.Round(f*scale) / scale, nil
+}
+
+func coalesce(args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
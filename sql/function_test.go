@@ -0,0 +1,104 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type callTest struct {
+	call   string
+	args   []interface{}
+	result interface{}
+}
+
+var callTests = []callTest{
+	{"lower", []interface{}{"AbC"}, "abc"},
+	{"length", []interface{}{"héllo"}, int64(5)},
+	{"substr", []interface{}{"abcdef", int64(2), int64(3)}, "bcd"},
+	{"substr", []interface{}{"abcdef", int64(0), int64(2)}, "a"},
+	{"substr", []interface{}{"abcdef", "4"}, "def"},
+	{"substr", []interface{}{"abc", int64(5)}, ""},
+	{"abs", []interface{}{int64(-3)}, int64(3)},
+	{"abs", []interface{}{"-2.5"}, 2.5},
+	{"round", []interface{}{2.345, int64(2)}, 2.35},
+	{"round", []interface{}{int64(7)}, int64(7)},
+	{"coalesce", []interface{}{nil, nil, "x", "y"}, "x"},
+	{"upper", []interface{}{nil}, nil},
+}
+
+func Test_FunctionCall(t *testing.T) {
+	for _, test := range callTests {
+		fn, ok := lookupFunction(test.call)
+		if !ok {
+			t.Fatalf("%s not registered", test.call)
+		}
+		got, err := fn.call(test.call, test.args)
+		if err != nil {
+			t.Errorf("%s%v: %v", test.call, test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.result) {
+			t.Errorf("%s%v: got %#v, want %#v", test.call, test.args, got, test.result)
+		}
+	}
+	fn, _ := lookupFunction("lower")
+	if _, err := fn.call("lower", []interface{}{true}); err == nil {
+		t.Error("lower(true): expected error")
+	}
+}
+
+func Test_RegisterFunction(t *testing.T) {
+	err := RegisterFunction("Repeat_Str", Function{
+		Args:   []Type{TypeString, TypeInt},
+		Result: TypeString,
+		Call: func(args []interface{}) (interface{}, error) {
+			var s string
+			for i := int64(0); i < args[1].(int64); i++ {
+				s += args[0].(string)
+			}
+			return s, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := newTestEngine().Query(context.Background(), `select repeat_str(name, 2) where REPEAT_STR(region, 1) = "us-west"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if !rows.Next() || rows.Scan(&s) != nil || s != "erinerin" {
+		t.Errorf("got %q, %v", s, rows.Err())
+	}
+	rows.Close()
+
+	for _, name := range []string{"count", "1x", "a-b", ""} {
+		if err := RegisterFunction(name, Function{Args: []Type{TypeAny}, Call: coalesce}); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func Test_FunctionParse(t *testing.T) {
+	for _, query := range []string{
+		`select nosuch(name)`,
+		`select lower(name, 1)`,
+		`select substr(name)`,
+		`select abs(count(age))`,
+		`select upper(name) group by region`,
+		`select name order by 1`,
+	} {
+		p := NewParse(query)
+		p.Generate()
+		if p.Err() == nil {
+			t.Errorf("%s: expected error", query)
+		} else {
+			fmt.Println(query, p.Err())
+		}
+	}
+	if _, err := ToElasticsearch(`select name where lower(name) = "a"`); err == nil {
+		t.Error("expected elasticsearch to reject lower(name)")
+	}
+}
@@ -0,0 +1,623 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Queries on a graph name properties through pattern variables: "n.age" is
+// the property age of the node n, and a field without a variable belongs to
+// n. A query naming the edge e or the node m at its end matches the pattern
+// (n)-[e]->(m) instead of (n). The table names the label of n, except for
+// DefaultTable which matches every node.
+const (
+	graphNode = "n"
+	graphEdge = "e"
+	graphEnd  = "m"
+)
+
+// graphProperty splits field into its pattern variable and property.
+func graphProperty(field string) (variable, property string, err error) {
+	variable, property = graphNode, field
+	if dot := strings.Index(field, MarkDot); dot >= 0 {
+		variable, property = field[:dot], field[dot+1:]
+	}
+	switch variable {
+	case graphNode, graphEdge, graphEnd:
+		return variable, property, nil
+	}
+	return "", "", fmt.Errorf("%q names unknown variable %q, not one of %s, %s or %s", field, variable, graphNode, graphEdge, graphEnd)
+}
+
+// graphVariables returns the pattern variables the model refers to.
+func (m *model) graphVariables() (map[string]bool, error) {
+	vars := make(map[string]bool)
+	var fields []string
+	fields = append(fields, m.Fields...)
+	fields = append(fields, m.GroupBy...)
+	for _, agg := range m.Aggragations.Items {
+		fields = append(fields, agg.Field)
+	}
+	walkConditions(m.Conditions, func(c *SingleCondition) {
+		fields = append(fields, c.Field)
+	})
+	for _, f := range fields {
+		v, _, err := graphProperty(f)
+		if err != nil {
+			return nil, err
+		}
+		vars[v] = true
+	}
+	return vars, nil
+}
+
+// walkConditions calls fn for every single condition of c.
+func walkConditions(c Condition, fn func(*SingleCondition)) {
+	switch c := c.(type) {
+	case *SingleCondition:
+		fn(c)
+	case *MultiCondition:
+		for _, sub := range c.SubConditions {
+			walkConditions(sub, fn)
+		}
+	}
+}
+
+// ToCypher parses query and translates it to Cypher.
+func ToCypher(query string) (string, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return "", err
+	}
+	return p.Cypher()
+}
+
+// Cypher translates the model to a Cypher query:
+//
+//	MATCH (n:label) WHERE ... RETURN ... ORDER BY ... SKIP ... LIMIT ...
+//
+// Aggragations are named after their column, e.g. `count(id)`. When a group
+// by field is not selected, a WITH clause groups before RETURN. Placeholders
+// must be bound first.
+func (m *model) Cypher() (string, error) {
+	if err := m.checkCounts(); err != nil {
+		return "", err
+	}
+	if err := m.plainFields(); err != nil {
+		return "", err
+	}
+	vars, err := m.graphVariables()
+	if err != nil {
+		return "", err
+	}
+	node := graphNode
+	if m.TableName != DefaultTable {
+		node += ":" + cypherName(m.TableName)
+	}
+	var b strings.Builder
+	if vars[graphEdge] || vars[graphEnd] {
+		fmt.Fprintf(&b, "MATCH (%s)-[%s]->(%s)", node, graphEdge, graphEnd)
+	} else {
+		fmt.Fprintf(&b, "MATCH (%s)", node)
+	}
+	if m.Conditions != nil {
+		where, err := cypherCondition(m.Conditions)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" WHERE " + where)
+	}
+
+	if !m.aggragates() {
+		columns := make([]string, len(m.Columns))
+		for i, c := range m.Columns {
+			columns[i] = cypherProperty(c)
+		}
+		b.WriteString(m.cypherReturn() + strings.Join(columns, ", "))
+		m.cypherOrderBy(&b, cypherProperty)
+	} else {
+		// Results are named after their column. A WITH clause computes the
+		// groups first when RETURN alone would group or order differently.
+		expr := make(map[string]string)
+		for _, f := range m.GroupBy {
+			expr[f] = cypherProperty(f)
+		}
+		for _, agg := range m.Aggragations.Items {
+			e, err := cypherAggragation(agg)
+			if err != nil {
+				return "", err
+			}
+			expr[agg.String()] = e
+		}
+		with := false
+		for _, f := range m.GroupBy {
+			with = with || !contains(m.Fields, f)
+		}
+		for _, o := range m.OrderBy {
+			with = with || !contains(m.Columns, o.Column)
+		}
+		columns := make([]string, len(m.Columns))
+		if with {
+			items := make([]string, 0, len(expr))
+			for _, f := range m.GroupBy {
+				items = append(items, expr[f]+" AS "+cypherAlias(f))
+			}
+			for _, agg := range m.Aggragations.Items {
+				items = append(items, expr[agg.String()]+" AS "+cypherAlias(agg.String()))
+			}
+			b.WriteString(" WITH " + strings.Join(items, ", "))
+			for i, c := range m.Columns {
+				columns[i] = cypherAlias(c)
+			}
+		} else {
+			for i, c := range m.Columns {
+				columns[i] = expr[c] + " AS " + cypherAlias(c)
+			}
+		}
+		b.WriteString(m.cypherReturn() + strings.Join(columns, ", "))
+		m.cypherOrderBy(&b, cypherAlias)
+	}
+	if m.Offset > 0 {
+		b.WriteString(" SKIP " + strconv.Itoa(m.Offset))
+	}
+	if m.Limit >= 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(m.Limit))
+	}
+	return b.String(), nil
+}
+
+// cypherReturn returns the RETURN keyword of the model, with DISTINCT for a
+// select distinct.
+func (m *model) cypherReturn() string {
+	if m.Distinct {
+		return " RETURN DISTINCT "
+	}
+	return " RETURN "
+}
+
+func (m *model) cypherOrderBy(b *strings.Builder, name func(string) string) {
+	if len(m.OrderBy) == 0 {
+		return
+	}
+	items := make([]string, len(m.OrderBy))
+	for i, o := range m.OrderBy {
+		items[i] = name(o.Column)
+		if o.Desc {
+			items[i] += " DESC"
+		}
+	}
+	b.WriteString(" ORDER BY " + strings.Join(items, ", "))
+}
+
+// cypherProperty returns the property access of field, e.g. n.age for age.
+func cypherProperty(field string) string {
+	variable, property, _ := graphProperty(field)
+	return variable + MarkDot + cypherName(property)
+}
+
+// cypherName quotes name with backticks unless it is a plain identifier.
+func cypherName(name string) string {
+	for _, r := range name {
+		if !strings.ContainsRune(letter+digits+"_", r) {
+			return "`" + strings.Replace(name, "`", "``", -1) + "`"
+		}
+	}
+	return name
+}
+
+// cypherAlias is the name a result column is returned as.
+func cypherAlias(column string) string {
+	return cypherName(column)
+}
+
+var cypherFunction = map[AggType]string{
+	AggCount:            "count",
+	AggSum:              "sum",
+	AggAverage:          "avg",
+	AggMin:              "min",
+	AggMax:              "max",
+	AggMedian:           "percentileCont",
+	AggPercentile:       "percentileCont",
+	AggApproxPercentile: "percentileCont",
+	AggStddev:           "stDev",
+	AggVariance:         "stDev",
+	AggArray:            "collect",
+}
+
+// cypherAggragation returns the aggregating function call of agg. A
+// variance is the square of the deviation.
+func cypherAggragation(agg aggItem) (string, error) {
+	if err := checkBound(agg.Params); err != nil {
+		return "", err
+	}
+	arg := cypherProperty(agg.Field)
+	if agg.distinctCount() {
+		return "count(DISTINCT " + arg + ")", nil
+	}
+	name, ok := cypherFunction[agg.Agg]
+	if !ok {
+		return "", fmt.Errorf("aggragation %s not supported", agg)
+	}
+	if agg.Distinct {
+		arg = "DISTINCT " + arg
+	}
+	switch agg.Agg {
+	case AggMedian:
+		return name + "(" + arg + ", 0.5)", nil
+	case AggPercentile, AggApproxPercentile:
+		return name + "(" + arg + ", " + formatValue(agg.Params[0]) + ")", nil
+	case AggVariance:
+		return name + "(" + arg + ") ^ 2", nil
+	}
+	return name + "(" + arg + ")", nil
+}
+
+var cypherComparator = map[ComparatorType]string{
+	ComparatorEQ:      "=",
+	ComparatorNEQ:     "<>",
+	ComparatorGT:      ">",
+	ComparatorGTE:     ">=",
+	ComparatorLT:      "<",
+	ComparatorLTE:     "<=",
+	ComparatorLIKE:    "=~",
+	ComparatorILIKE:   "=~",
+	ComparatorREGEXP:  "=~",
+	ComparatorNREGEXP: "=~", // negated
+	ComparatorIN:      "IN",
+}
+
+func cypherCondition(c Condition) (string, error) {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if err := checkBound(c.Value); err != nil {
+			return "", err
+		}
+		if err := checkGraphLiteral(c.Value); err != nil {
+			return "", err
+		}
+		op, ok := cypherComparator[c.Comparator]
+		if !ok {
+			return "", fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		value := c.Value
+		if c.isLike() {
+			like, err := c.like()
+			if err != nil {
+				return "", err
+			}
+			value = like.Regexp()
+		}
+		if c.Comparator == ComparatorREGEXP || c.Comparator == ComparatorNREGEXP {
+			// =~ matches the whole string
+			value = "(?s:.*)(?:" + fmt.Sprint(value) + ")(?s:.*)"
+		}
+		s := cypherProperty(c.Field) + " " + op + " " + graphLiteral(value, "[", "]")
+		if c.Comparator == ComparatorNREGEXP {
+			s = "NOT " + s
+		}
+		return s, nil
+	case *MultiCondition:
+		subs := make([]string, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			s, err := cypherCondition(sub)
+			if err != nil {
+				return "", err
+			}
+			if conditionPrec(sub) < conditionPrec(c) {
+				s = "(" + s + ")"
+			}
+			subs[i] = s
+		}
+		if c.Logic == LogicNot {
+			return "NOT " + subs[0], nil
+		}
+		return strings.Join(subs, " "+strings.ToUpper(c.Logic.String())+" "), nil
+	}
+	return "", fmt.Errorf("unknown condition %T", c)
+}
+
+// graphLiteral writes v as a Cypher or Groovy literal, enclosing lists in
+// open and close. A time is written as a call of datetime.
+func graphLiteral(v interface{}, open, close string) string {
+	switch v := v.(type) {
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+		return "'" + r.Replace(v) + "'"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = graphLiteral(item, open, close)
+		}
+		return open + strings.Join(items, ", ") + close
+	case time.Time:
+		return "datetime(" + graphLiteral(v.Format(time.RFC3339Nano), open, close) + ")"
+	case nil:
+		return "null"
+	}
+	return formatValue(v)
+}
+
+// checkGraphLiteral reports a value graphLiteral has no literal for, such
+// as an interval.
+func checkGraphLiteral(v interface{}) error {
+	switch v := v.(type) {
+	case string, int64, float64, bool, time.Time, nil:
+		return nil
+	case []interface{}:
+		for _, item := range v {
+			if err := checkGraphLiteral(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("value %s not supported", formatValue(v))
+}
+
+// ToGremlin parses query and translates it to a Gremlin traversal.
+func ToGremlin(query string) (string, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return "", err
+	}
+	return p.Gremlin()
+}
+
+// Gremlin translates the model to a Gremlin traversal over the vertices, or
+// over the edges when the query names properties of e. A single traversal
+// cannot mix the two. Conditions become has steps; fields are projected by
+// name, and aggragations are computed over the folded traversers or, with
+// group by, over each group whose key is a map of the group by fields.
+// Groups are unfolded to entries, which order by and limit then apply to.
+// The fields of a select distinct are grouped on. Placeholders must be
+// bound first.
+func (m *model) Gremlin() (string, error) {
+	if err := m.checkCounts(); err != nil {
+		return "", err
+	}
+	if err := m.plainFields(); err != nil {
+		return "", err
+	}
+	m, err := m.distinctGroups()
+	if err != nil {
+		return "", err
+	}
+	vars, err := m.graphVariables()
+	if err != nil {
+		return "", err
+	}
+	if vars[graphEnd] {
+		return "", fmt.Errorf("gremlin traversal cannot refer to %s", graphEnd)
+	}
+	var b strings.Builder
+	if vars[graphEdge] {
+		if vars[graphNode] {
+			return "", fmt.Errorf("gremlin traversal cannot refer to both %s and %s", graphNode, graphEdge)
+		}
+		b.WriteString("g.E()")
+	} else {
+		b.WriteString("g.V()")
+	}
+	if m.TableName != DefaultTable {
+		b.WriteString(".hasLabel(" + graphLiteral(m.TableName, "", "") + ")")
+	}
+	if m.Conditions != nil {
+		steps, err := gremlinCondition(m.Conditions, true)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(steps)
+	}
+
+	if !m.aggragates() {
+		if len(m.OrderBy) > 0 {
+			b.WriteString(".order()")
+			for _, o := range m.OrderBy {
+				b.WriteString(".by(" + gremlinKey(o.Column) + ", " + gremlinOrder(o) + ")")
+			}
+		}
+		if m.Offset > 0 {
+			fmt.Fprintf(&b, ".skip(%d)", m.Offset)
+		}
+		if m.Limit >= 0 {
+			fmt.Fprintf(&b, ".limit(%d)", m.Limit)
+		}
+		b.WriteString(gremlinProject(m.Columns, gremlinKey))
+		return b.String(), nil
+	}
+
+	steps := make(map[string]string)
+	for _, agg := range m.Aggragations.Items {
+		step, err := gremlinAggragation(agg)
+		if err != nil {
+			return "", err
+		}
+		steps[agg.String()] = step
+	}
+	aggs := gremlinProject(m.Columns, func(c string) string {
+		if step, ok := steps[c]; ok {
+			return "unfold()." + step
+		}
+		return "unfold().values(" + gremlinKey(c) + ").limit(1)"
+	})
+	if len(m.GroupBy) == 0 {
+		b.WriteString(".fold()" + aggs)
+	} else {
+		keys := gremlinProject(m.GroupBy, gremlinKey)
+		b.WriteString(".group().by(" + strings.TrimPrefix(keys, ".") + ").by(fold()" + aggs + ").unfold()")
+	}
+	if len(m.OrderBy) > 0 {
+		b.WriteString(".order()")
+		for _, o := range m.OrderBy {
+			from := "values"
+			if contains(m.GroupBy, o.Column) {
+				from = "keys"
+			}
+			fmt.Fprintf(&b, ".by(select(%s).select(%s), %s)", from, graphLiteral(o.Column, "", ""), gremlinOrder(o))
+		}
+	}
+	if m.Offset > 0 {
+		fmt.Fprintf(&b, ".skip(%d)", m.Offset)
+	}
+	if m.Limit >= 0 {
+		fmt.Fprintf(&b, ".limit(%d)", m.Limit)
+	}
+	if len(m.GroupBy) > 0 {
+		b.WriteString(".select(values)")
+	}
+	return b.String(), nil
+}
+
+// gremlinKey returns the quoted property name of field.
+func gremlinKey(field string) string {
+	_, property, _ := graphProperty(field)
+	return graphLiteral(property, "", "")
+}
+
+func gremlinOrder(o orderItem) string {
+	if o.Desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+// gremlinProject projects the columns, each by the traversal from by.
+func gremlinProject(columns []string, by func(string) string) string {
+	names := make([]string, len(columns))
+	var steps strings.Builder
+	for i, c := range columns {
+		names[i] = graphLiteral(c, "", "")
+		steps.WriteString(".by(" + by(c) + ")")
+	}
+	return ".project(" + strings.Join(names, ", ") + ")" + steps.String()
+}
+
+var gremlinStep = map[AggType]string{
+	AggCount:   "count()",
+	AggSum:     "sum()",
+	AggAverage: "mean()",
+	AggMin:     "min()",
+	AggMax:     "max()",
+	AggArray:   "fold()",
+}
+
+func gremlinAggragation(agg aggItem) (string, error) {
+	values := "values(" + gremlinKey(agg.Field) + ")."
+	if agg.distinctCount() {
+		return values + "dedup().count()", nil
+	}
+	step, ok := gremlinStep[agg.Agg]
+	if !ok {
+		return "", fmt.Errorf("aggragation %s not supported", agg)
+	}
+	if agg.Distinct {
+		values += "dedup()."
+	}
+	return values + step, nil
+}
+
+var gremlinPredicate = map[ComparatorType]string{
+	ComparatorEQ:  "eq",
+	ComparatorNEQ: "neq",
+	ComparatorGT:  "gt",
+	ComparatorGTE: "gte",
+	ComparatorLT:  "lt",
+	ComparatorLTE: "lte",
+	ComparatorIN:  "within",
+	// the text predicates of TinkerPop 3.6
+	ComparatorREGEXP:  "regex",
+	ComparatorNREGEXP: "notRegex",
+}
+
+// gremlinCondition translates c to steps appended to a traversal when top
+// is set, or else to an anonymous traversal for and, or and not.
+func gremlinCondition(c Condition, top bool) (string, error) {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if err := checkBound(c.Value); err != nil {
+			return "", err
+		}
+		if err := checkGraphLiteral(c.Value); err != nil {
+			return "", err
+		}
+		var predicate string
+		if c.isLike() {
+			like, err := c.like()
+			if err != nil {
+				return "", err
+			}
+			predicate = gremlinLike(like)
+		} else if name, ok := gremlinPredicate[c.Comparator]; ok {
+			predicate = name + "(" + graphLiteral(c.Value, "", "") + ")"
+		} else {
+			return "", fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		step := "has(" + gremlinKey(c.Field) + ", " + predicate + ")"
+		if top {
+			return "." + step, nil
+		}
+		return "__." + step, nil
+	case *MultiCondition:
+		if c.Logic == LogicAnd && top {
+			var b strings.Builder
+			for _, sub := range c.SubConditions {
+				s, err := gremlinCondition(sub, true)
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(s)
+			}
+			return b.String(), nil
+		}
+		subs := make([]string, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			s, err := gremlinCondition(sub, false)
+			if err != nil {
+				return "", err
+			}
+			subs[i] = s
+		}
+		step := c.Logic.String() + "(" + strings.Join(subs, ", ") + ")"
+		if top {
+			return "." + step, nil
+		}
+		return "__." + step, nil
+	}
+	return "", fmt.Errorf("unknown condition %T", c)
+}
+
+// gremlinLike translates a like pattern to a text predicate, using the
+// simpler startingWith, endingWith and containing where they suffice.
+// Patterns ignoring case need a regex.
+func gremlinLike(like *Like) string {
+	parts := like.parts
+	prefix := len(parts) > 0 && parts[0].wild == '%'
+	if prefix {
+		parts = parts[1:]
+	}
+	suffix := len(parts) > 0 && parts[len(parts)-1].wild == '%'
+	if suffix {
+		parts = parts[:len(parts)-1]
+	}
+	if !like.Fold && len(parts) <= 1 && (len(parts) == 0 || parts[0].wild == 0) {
+		var inner string
+		if len(parts) == 1 {
+			inner = parts[0].text
+		}
+		switch {
+		case prefix && suffix && inner != "":
+			return "containing(" + graphLiteral(inner, "", "") + ")"
+		case suffix && !prefix:
+			return "startingWith(" + graphLiteral(inner, "", "") + ")"
+		case prefix && !suffix:
+			return "endingWith(" + graphLiteral(inner, "", "") + ")"
+		case !prefix && !suffix:
+			return "eq(" + graphLiteral(inner, "", "") + ")"
+		}
+	}
+	return "regex(" + graphLiteral(like.Regexp(), "", "") + ")"
+}
@@ -0,0 +1,109 @@
+package sql
+
+import "testing"
+
+type graphTest struct {
+	query   string
+	cypher  string
+	gremlin string
+}
+
+var graphTests = []graphTest{
+	{
+		`select name, age where age > 18 order by age desc limit 5`,
+		`MATCH (n) WHERE n.age > 18 RETURN n.name, n.age ORDER BY n.age DESC LIMIT 5`,
+		`g.V().has('age', gt(18)).order().by('age', desc).limit(5).project('name', 'age').by('name').by('age')`,
+	},
+	{
+		`select name from person where (age < 20 or age > 60) and not region in ("a", "it's")`,
+		`MATCH (n:person) WHERE (n.age < 20 OR n.age > 60) AND NOT n.region IN ['a', 'it\'s'] RETURN n.name`,
+		`g.V().hasLabel('person').or(__.has('age', lt(20)), __.has('age', gt(60))).not(__.has('region', within('a', 'it\'s'))).project('name').by('name')`,
+	},
+	{
+		`select name where name like "a_b%" or name like "%x"`,
+		`MATCH (n) WHERE n.name =~ '(?s)^a.b.*$' OR n.name =~ '(?s)^.*x$' RETURN n.name`,
+		`g.V().or(__.has('name', regex('(?s)^a.b.*$')), __.has('name', endingWith('x'))).project('name').by('name')`,
+	},
+	{
+		`select name where name regexp "^a[0-9]+" and region !~ "cn" and name ilike "b%"`,
+		`MATCH (n) WHERE n.name =~ '(?s:.*)(?:^a[0-9]+)(?s:.*)' AND NOT n.region =~ '(?s:.*)(?:cn)(?s:.*)' AND n.name =~ '(?is)^b.*$' RETURN n.name`,
+		`g.V().has('name', regex('^a[0-9]+')).has('region', notRegex('cn')).has('name', regex('(?is)^b.*$')).project('name').by('name')`,
+	},
+	{
+		`select region, count(id) group by region order by count(id) desc limit 3`,
+		"MATCH (n) RETURN n.region AS region, count(n.id) AS `count(id)` ORDER BY `count(id)` DESC LIMIT 3",
+		`g.V().group().by(project('region').by('region')).by(fold().project('region', 'count(id)').by(unfold().values('region').limit(1)).by(unfold().values('id').count())).unfold().order().by(select(values).select('count(id)'), desc).limit(3).select(values)`,
+	},
+	{
+		`select count(id) group by region`,
+		"MATCH (n) WITH n.region AS region, count(n.id) AS `count(id)` RETURN `count(id)`",
+		`g.V().group().by(project('region').by('region')).by(fold().project('count(id)').by(unfold().values('id').count())).unfold().select(values)`,
+	},
+	{
+		`select sum(age), distinct(region)`,
+		"MATCH (n) RETURN sum(n.age) AS `sum(age)`, count(DISTINCT n.region) AS `distinct(region)`",
+		`g.V().fold().project('sum(age)', 'distinct(region)').by(unfold().values('age').sum()).by(unfold().values('region').dedup().count())`,
+	},
+	{
+		`select count(distinct region), percentile(age, 0.9), variance(age), array_agg(distinct name)`,
+		"MATCH (n) RETURN count(DISTINCT n.region) AS `count(distinct region)`, percentileCont(n.age, 0.9) AS `percentile(age, 0.9)`, stDev(n.age) ^ 2 AS `variance(age)`, collect(DISTINCT n.name) AS `array_agg(distinct name)`",
+		``,
+	},
+	{
+		`select distinct region, name where age > 18 order by name`,
+		`MATCH (n) WHERE n.age > 18 RETURN DISTINCT n.region, n.name ORDER BY n.name`,
+		`g.V().has('age', gt(18)).group().by(project('region', 'name').by('region').by('name')).by(fold().project('region', 'name').by(unfold().values('region').limit(1)).by(unfold().values('name').limit(1))).unfold().order().by(select(keys).select('name'), asc).select(values)`,
+	},
+	{
+		`select approx_count_distinct(region), array_agg(distinct name)`,
+		"MATCH (n) RETURN count(DISTINCT n.region) AS `approx_count_distinct(region)`, collect(DISTINCT n.name) AS `array_agg(distinct name)`",
+		`g.V().fold().project('approx_count_distinct(region)', 'array_agg(distinct name)').by(unfold().values('region').dedup().count()).by(unfold().values('name').dedup().fold())`,
+	},
+	{
+		`select e.weight where e.weight > 1 and e.since like "2019%" order by e.weight`,
+		`MATCH (n)-[e]->(m) WHERE e.weight > 1 AND e.since =~ '(?s)^2019.*$' RETURN e.weight ORDER BY e.weight`,
+		`g.E().has('weight', gt(1)).has('since', startingWith('2019')).order().by('weight', asc).project('e.weight').by('weight')`,
+	},
+	{
+		`select n.name, m.name from knows where e.weight >= 0.5`,
+		`MATCH (n:knows)-[e]->(m) WHERE e.weight >= 0.5 RETURN n.name, m.name`,
+		``,
+	},
+}
+
+func Test_Graph(t *testing.T) {
+	for _, test := range graphTests {
+		cypher, err := ToCypher(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		} else if cypher != test.cypher {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, cypher, test.cypher)
+		}
+		gremlin, err := ToGremlin(test.query)
+		if test.gremlin == "" {
+			if err == nil {
+				t.Errorf("%s: expected gremlin error, got %s", test.query, gremlin)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		} else if gremlin != test.gremlin {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, gremlin, test.gremlin)
+		}
+	}
+}
+
+func Test_GraphUnknownVariable(t *testing.T) {
+	if _, err := ToCypher(`select x.name`); err == nil {
+		t.Error("expected error for unknown variable x")
+	}
+}
+
+func Test_GraphLiteral(t *testing.T) {
+	query := `select name where wait > interval '1 day'`
+	if _, err := ToCypher(query); err == nil || err.Error() != `value interval "1 day" not supported` {
+		t.Errorf("cypher: got error %v", err)
+	}
+	if _, err := ToGremlin(query); err == nil || err.Error() != `value interval "1 day" not supported` {
+		t.Errorf("gremlin: got error %v", err)
+	}
+}
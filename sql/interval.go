@@ -0,0 +1,219 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval is a span of time, as written in interval '1 month 2 days'. Its
+// months and days have no fixed length: they are added to a timestamp on
+// the calendar of its location, so that a day across a change of daylight
+// saving time keeps the time of day.
+type Interval struct {
+	Months   int
+	Days     int
+	Duration time.Duration
+}
+
+// intervalUnits maps the units of an interval to the months, days or
+// duration of one of them.
+var intervalUnits = map[string]Interval{
+	"microsecond": {Duration: time.Microsecond},
+	"millisecond": {Duration: time.Millisecond},
+	"second":      {Duration: time.Second},
+	"sec":         {Duration: time.Second},
+	"minute":      {Duration: time.Minute},
+	"min":         {Duration: time.Minute},
+	"hour":        {Duration: time.Hour},
+	"day":         {Days: 1},
+	"week":        {Days: 7},
+	"month":       {Months: 1},
+	"mon":         {Months: 1},
+	"year":        {Months: 12},
+}
+
+// ParseInterval parses an interval of numbers followed by their units, such
+// as "1 year 2 months", "-7 days" or "1.5 hours", or a duration of Go such
+// as "1h30m". Units are singular or plural; months, days and the longer
+// units take whole numbers.
+func ParseInterval(s string) (Interval, error) {
+	var iv Interval
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 1 {
+		if d, err := time.ParseDuration(fields[0]); err == nil {
+			return Interval{Duration: d}, nil
+		}
+	}
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return iv, fmt.Errorf("interval %q not valid", s)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[i+1], "s")]
+		if !ok {
+			return iv, fmt.Errorf("interval %q has an unknown unit %s", s, fields[i+1])
+		}
+		if unit.Duration != 0 {
+			f, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return iv, fmt.Errorf("interval %q not valid", s)
+			}
+			iv.Duration += time.Duration(f * float64(unit.Duration))
+			continue
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return iv, fmt.Errorf("interval %q needs a whole number of %s", s, fields[i+1])
+		}
+		iv.Months += n * unit.Months
+		iv.Days += n * unit.Days
+	}
+	return iv, nil
+}
+
+// String returns the interval in the form ParseInterval takes, e.g.
+// "1 year 2 months 3 days 4 hours 30 minutes 1.5 seconds".
+func (iv Interval) String() string {
+	var parts []string
+	add := func(n int64, unit string) {
+		if n == 1 || n == -1 {
+			parts = append(parts, strconv.FormatInt(n, 10)+" "+unit)
+		} else if n != 0 {
+			parts = append(parts, strconv.FormatInt(n, 10)+" "+unit+"s")
+		}
+	}
+	add(int64(iv.Months/12), "year")
+	add(int64(iv.Months%12), "month")
+	add(int64(iv.Days), "day")
+	d := iv.Duration
+	add(int64(d/time.Hour), "hour")
+	add(int64(d%time.Hour/time.Minute), "minute")
+	if d %= time.Minute; d%time.Second == 0 {
+		add(int64(d/time.Second), "second")
+	} else {
+		sign := ""
+		if d < 0 {
+			sign, d = "-", -d
+		}
+		frac := strings.TrimRight(fmt.Sprintf("%09d", d%time.Second), "0")
+		parts = append(parts, fmt.Sprintf("%s%d.%s seconds", sign, d/time.Second, frac))
+	}
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, " ")
+}
+
+func (iv Interval) neg() Interval {
+	return Interval{Months: -iv.Months, Days: -iv.Days, Duration: -iv.Duration}
+}
+
+func (iv Interval) plus(other Interval) Interval {
+	return Interval{Months: iv.Months + other.Months, Days: iv.Days + other.Days, Duration: iv.Duration + other.Duration}
+}
+
+// addTo returns t moved by the interval, its months and days on the
+// calendar of the location of t.
+func (iv Interval) addTo(t time.Time) time.Time {
+	return t.AddDate(0, iv.Months, iv.Days).Add(iv.Duration)
+}
+
+// approx returns the length of the interval taking a month as 30 days and
+// a day as 24 hours, to order intervals.
+func (iv Interval) approx() time.Duration {
+	return time.Duration(iv.Months*30+iv.Days)*24*time.Hour + iv.Duration
+}
+
+// toTime returns v as a time: a time.Time, or a string holding a timestamp.
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		return parseTimestamp(v)
+	}
+	return time.Time{}, false
+}
+
+// isTemporal reports whether v is a time or an interval.
+func isTemporal(v interface{}) bool {
+	switch v.(type) {
+	case time.Time, Interval:
+		return true
+	}
+	return false
+}
+
+// timeArith computes a op b for a time or an interval: a time moved by an
+// interval, the interval between two times, or the sum or difference of two
+// intervals. A string standing for the time or interval is converted.
+func timeArith(op Operator, a, b interface{}) (interface{}, error) {
+	if s, ok := a.(string); ok {
+		if t, ok := parseTimestamp(s); ok {
+			a = t
+		}
+	}
+	if s, ok := b.(string); ok {
+		if t, ok := parseTimestamp(s); ok {
+			b = t
+		} else if iv, err := ParseInterval(s); err == nil {
+			b = iv
+		}
+	}
+	switch x := a.(type) {
+	case time.Time:
+		switch y := b.(type) {
+		case Interval:
+			if op == OpAdd {
+				return y.addTo(x), nil
+			}
+			if op == OpSub {
+				return y.neg().addTo(x), nil
+			}
+		case time.Time:
+			if op == OpSub {
+				return Interval{Duration: x.Sub(y)}, nil
+			}
+		}
+	case Interval:
+		switch y := b.(type) {
+		case time.Time:
+			if op == OpAdd {
+				return x.addTo(y), nil
+			}
+		case Interval:
+			if op == OpAdd {
+				return x.plus(y), nil
+			}
+			if op == OpSub {
+				return x.plus(y.neg()), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("operator %s not supported for %v and %v", op, a, b)
+}
+
+// timeArithType returns the type of t op u for the types of timeArith, and
+// false when op does not apply to them. TypeAny operands give TypeAny.
+func timeArithType(op Operator, t, u Type) (Type, bool) {
+	if op != OpAdd && op != OpSub {
+		return TypeAny, false
+	}
+	switch {
+	case t == TypeTimestamp && u == TypeInterval, op == OpAdd && t == TypeInterval && u == TypeTimestamp:
+		return TypeTimestamp, true
+	case op == OpSub && t == TypeTimestamp && u == TypeTimestamp, t == TypeInterval && u == TypeInterval:
+		return TypeInterval, true
+	case t == TypeAny || u == TypeAny:
+		return TypeAny, true
+	}
+	return TypeAny, false
+}
+
+// timeNow returns the current time for now(); tests replace it.
+var timeNow = time.Now
+
+func now([]interface{}) (interface{}, error) {
+	return timeNow(), nil
+}
@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Interval(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want Interval
+		s    string
+	}{
+		{"7 days", Interval{Days: 7}, "7 days"},
+		{"1 Year 2 mons -1 week", Interval{Months: 14, Days: -7}, "1 year 2 months -7 days"},
+		{"1.5 hours 1 minute", Interval{Duration: 91 * time.Minute}, "1 hour 31 minutes"},
+		{"1h0m0.25s", Interval{Duration: time.Hour + 250*time.Millisecond}, "1 hour 0.25 seconds"},
+		{"-2 seconds 500 milliseconds", Interval{Duration: -1500 * time.Millisecond}, "-1.5 seconds"},
+		{"0 days", Interval{}, "0 seconds"},
+	} {
+		iv, err := ParseInterval(test.text)
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+			continue
+		}
+		if iv != test.want {
+			t.Errorf("%s: got %#v, want %#v", test.text, iv, test.want)
+		}
+		if s := iv.String(); s != test.s {
+			t.Errorf("%s: got %s, want %s", test.text, s, test.s)
+		}
+		if again, err := ParseInterval(iv.String()); err != nil || again != iv {
+			t.Errorf("%s: %s parsed as %#v, %v", test.text, iv, again, err)
+		}
+	}
+	for _, text := range []string{"", "7", "7 fortnights", "1.5 days"} {
+		if _, err := ParseInterval(text); err == nil {
+			t.Errorf("%s: expected error", text)
+		}
+	}
+
+	// a day keeps the time of day across the change to daylight saving time
+	if loc, err := time.LoadLocation("America/New_York"); err == nil {
+		t0 := time.Date(2024, 3, 9, 12, 0, 0, 0, loc)
+		if got := (Interval{Days: 1}).addTo(t0); !got.Equal(time.Date(2024, 3, 10, 12, 0, 0, 0, loc)) {
+			t.Errorf("got %v", got)
+		}
+		if got := (Interval{Duration: 24 * time.Hour}).addTo(t0); got.Hour() != 13 {
+			t.Errorf("got %v", got)
+		}
+	}
+}
+
+func Test_TimeQuery(t *testing.T) {
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC) }
+
+	ctx := context.Background()
+	e := NewEngine()
+	if _, err := e.Exec(ctx, `create table events (name string, created timestamp, every interval)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Exec(ctx, `insert into events values ("a", "2024-03-01", "1 day"), ("b", "2024-03-09T20:00:00-05:00", ?), ("c", timestamp '2024-03-09 12:00:00+02:00', interval '1 month')`, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := e.Query(ctx, `select name, created + every, created - date '2024-03-01' from events where created > now() - interval '7 days' and created <= "2024-03-10T01:00:00Z" order by created`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Type{TypeString, TypeTimestamp, TypeInterval}; !reflect.DeepEqual(rows.ColumnTypes(), want) {
+		t.Errorf("got types %v", rows.ColumnTypes())
+	}
+	got, err := readAll(ctx, rows.iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{"c", time.Date(2024, 4, 9, 10, 0, 0, 0, time.UTC), Interval{Duration: 8*24*time.Hour + 10*time.Hour}},
+		{"b", time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), Interval{Duration: 9*24*time.Hour + time.Hour}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, row := range got {
+		if row[0] != want[i][0] || !row[1].(time.Time).Equal(want[i][1].(time.Time)) || row[2] != want[i][2] {
+			t.Errorf("row %d: got %v, want %v", i, row, want[i])
+		}
+	}
+
+	for _, test := range []struct{ query, err string }{
+		{`select name from events where created > now() + 1`, `type error: timestamp + int in now() + 1 at offset 40`},
+		{`select every * 2 from events`, `type error: interval * int in every * 2 at offset 7`},
+		{`select name from events where created > date '2024-13-01'`, `syntax error: date "2024-13-01" not valid at offset 40`},
+		{`select interval '1 fortnight'`, `syntax error: interval "1 fortnight" has an unknown unit fortnight at offset 7`},
+	} {
+		_, err := e.Prepare(test.query)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+}
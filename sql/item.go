@@ -5,6 +5,7 @@ import "fmt"
 type item struct {
 	typ itemType
 	val string
+	pos int // byte offset of the item in the input
 }
 type itemType int
 
@@ -15,6 +16,10 @@ const (
 	itemCharConstant                 // character constant
 	itemNumber                       // simple number, including imaginary
 	itemIdentifier                   // alphanumeric identifier
+	itemPlaceholder                  // "?", "$1" or ":name" standing for a value bound later
+	itemDate                         // date '2024-01-01', keyword and quoted text
+	itemTimestamp                    // timestamp '2024-01-01 10:00:00+02:00'
+	itemInterval                     // interval '7 days'
 
 	itemEqual        // "="
 	itemGreater      // ">"
@@ -23,6 +28,12 @@ const (
 	itemLessEqual    // "<="
 	itemNotEqual     // "!="
 	//itemNotEqual2    // "<>"
+	itemPlus   // "+"
+	itemMinus  // "-"
+	itemMul    // "*"
+	itemDiv    // "/"
+	itemMod    // "%"
+	itemConcat // "||"
 	itemEOF
 
 	itemComma      // ,
@@ -36,14 +47,40 @@ const (
 	// Keywords appear after all the rest.
 	itemKeyword // used only to delimit the keywords
 	itemSelect
+	itemInsert // "insert into"
+	itemValues
+	itemUpdate
+	itemSet
+	itemDelete // "delete from"
+	itemCreate // "create table"
 	itemAs
 	itemFrom
+	itemJoin      // "join" or "inner join"
+	itemLeftJoin  // "left join" or "left outer join"
+	itemCrossJoin // "cross join"
+	itemOn
 	itemWhere
 	itemGroupBy
 	itemOrderBy
+	itemPartitionBy // "partition by" of a window
+	itemOver
 	itemAsc
 	itemDesc
+	itemLimit
+	itemOffset
 	itemLike
+	itemILike
+	itemEscape    // the escape of a like pattern
+	itemRegexp    // "regexp", "rlike" or "~"
+	itemNotRegexp // "!~"
+	itemIn
+	itemExists
+	itemUnion
+	itemIntersect
+	itemExcept
+	itemAll
+	// "distinct" before the select list or the argument of an aggragation
+	itemDistinctArg
 	itemAnd // and
 	itemOr  // or
 	itemNot // not
@@ -55,6 +92,14 @@ const (
 	itemMin
 	itemSum
 	itemDistinct
+	itemMedian
+	itemPercentile
+	itemStddev
+	itemVariance
+	itemArrayAgg
+	itemStringAgg
+	itemApproxCountDistinct
+	itemApproxPercentile
 )
 
 func (i item) String() string {
@@ -72,29 +117,56 @@ func (i item) String() string {
 }
 
 const (
-	eof         = -1
-	KeySelect   = "select"
-	KeyAs       = "as"
-	KeyFrom     = "from"
-	KeyWhere    = "where"
-	KeyNot      = "not"
-	KeyAnd      = "and"
-	KeyOr       = "or"
-	KeyCount    = "count"
-	KeyMax      = "max"
-	KeyMin      = "min"
-	KeySum      = "sum"
-	KeyAverage  = "average"
-	KeyDistinct = "distinct"
-	KeyLike     = "like"
-	KeyGroupBy  = "groupby"
-	KeyOrderBy  = "orderby"
-	KeyDesc     = "desc"
-	KeyAsc      = "asc"
-	KeyLimit    = "limit"
-	KeyTrue     = "true"
-	KeyFalse    = "false"
-	Space       = " "
+	eof          = -1
+	KeySelect    = "select"
+	KeyInsert    = "insert"
+	KeyInto      = "into"
+	KeyValues    = "values"
+	KeyUpdate    = "update"
+	KeySet       = "set"
+	KeyDelete    = "delete"
+	KeyCreate    = "create"
+	KeyTable     = "table"
+	KeyAs        = "as"
+	KeyFrom      = "from"
+	KeyJoin      = "join"
+	KeyInnerJoin = "innerjoin"
+	KeyLeftJoin  = "leftjoin"
+	KeyCrossJoin = "crossjoin"
+	KeyOn        = "on"
+	KeyWhere     = "where"
+	KeyNot       = "not"
+	KeyAnd       = "and"
+	KeyOr        = "or"
+	KeyCount     = "count"
+	KeyMax       = "max"
+	KeyMin       = "min"
+	KeySum       = "sum"
+	KeyAverage   = "average"
+	KeyDistinct  = "distinct"
+	KeyLike      = "like"
+	KeyILike     = "ilike"
+	KeyEscape    = "escape"
+	KeyRegexp    = "regexp"
+	KeyRLike     = "rlike"
+	KeyIn        = "in"
+	KeyExists    = "exists"
+	KeyUnion     = "union"
+	KeyIntersect = "intersect"
+	KeyExcept    = "except"
+	KeyAll       = "all"
+	KeyGroupBy   = "groupby"
+	KeyOrderBy   = "orderby"
+	KeyDesc      = "desc"
+	KeyAsc       = "asc"
+	KeyLimit     = "limit"
+	KeyOffset    = "offset"
+	KeyDate      = "date"
+	KeyTimestamp = "timestamp"
+	KeyInterval  = "interval"
+	KeyTrue      = "true"
+	KeyFalse     = "false"
+	Space        = " "
 
 	MakrComma      = ","
 	MarkDot        = "."
@@ -102,6 +174,29 @@ const (
 	MarkRightParen = ")"
 )
 
+// The aggragations beyond the basic ones, avg standing for average.
+const (
+	KeyAvg                 = "avg"
+	KeyMedian              = "median"
+	KeyPercentile          = "percentile"
+	KeyStddev              = "stddev"
+	KeyVariance            = "variance"
+	KeyArrayAgg            = "array_agg"
+	KeyStringAgg           = "string_agg"
+	KeyApproxCountDistinct = "approx_count_distinct"
+	KeyApproxPercentile    = "approx_percentile"
+)
+
+// The keywords of windows and the functions computed over them only.
+const (
+	KeyOver        = "over"
+	KeyPartitionBy = "partitionby"
+	KeyRowNumber   = "row_number"
+	KeyRank        = "rank"
+	KeyLag         = "lag"
+	KeyLead        = "lead"
+)
+
 var (
 	AggragationToType = map[string]itemType{
 		KeyCount:    itemCount,
@@ -110,12 +205,36 @@ var (
 		KeySum:      itemSum,
 		KeyAverage:  itemAverage,
 		KeyDistinct: itemDistinct,
+
+		KeyAvg:                 itemAverage,
+		KeyMedian:              itemMedian,
+		KeyPercentile:          itemPercentile,
+		KeyStddev:              itemStddev,
+		KeyVariance:            itemVariance,
+		KeyArrayAgg:            itemArrayAgg,
+		KeyStringAgg:           itemStringAgg,
+		KeyApproxCountDistinct: itemApproxCountDistinct,
+		KeyApproxPercentile:    itemApproxPercentile,
+	}
+	setOperators = map[string]itemType{
+		KeyUnion:     itemUnion,
+		KeyIntersect: itemIntersect,
+		KeyExcept:    itemExcept,
+	}
+	typedLiterals = map[string]itemType{
+		KeyDate:      itemDate,
+		KeyTimestamp: itemTimestamp,
+		KeyInterval:  itemInterval,
 	}
 	LogicOperator = map[string]itemType{
 		KeyAnd: itemAnd,
 		KeyOr:  itemOr,
 		KeyNot: itemNot,
 	}
-	letter = "abcdefghijklmnopqrstuvwxyz"
-	digits = "0123456789"
+	letter     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	whitespace = Space + "\t\r\n"
+	digits     = "0123456789"
+	// identifier holds the characters allowed after the first letter of an
+	// identifier
+	identifier = letter + digits + "_"
 )
@@ -0,0 +1,287 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type JoinType int
+
+const (
+	JoinInner JoinType = iota
+	JoinLeft
+	JoinCross
+)
+
+var joinNames = [...]string{
+	JoinInner: "join",
+	JoinLeft:  "left join",
+	JoinCross: "cross join",
+}
+
+func (j JoinType) String() string {
+	if j < 0 || int(j) >= len(joinNames) {
+		return "unknown"
+	}
+	return joinNames[j]
+}
+
+// Join joins a table to the tables before it in the from clause, so the
+// joins of a model form a left deep tree over its first table.
+type Join struct {
+	Type  JoinType
+	Table string
+	Alias string
+	On    Condition // nil for a cross join
+}
+
+// name returns the name the columns of the joined table are qualified
+// with: its alias, or else the table name.
+func (j *Join) name() string {
+	if j.Alias != "" {
+		return j.Alias
+	}
+	return j.Table
+}
+
+// tables returns the names qualifying the columns of each table of the
+// model, in from clause order.
+func (m *model) tables() []string {
+	first := m.TableName
+	if m.Alias != "" {
+		first = m.Alias
+	}
+	names := []string{first}
+	for _, j := range m.Joins {
+		names = append(names, j.name())
+	}
+	return names
+}
+
+// qualify prefixes columns with the name of their table.
+func qualify(table string, columns []string) []string {
+	q := make([]string, len(columns))
+	for i, c := range columns {
+		q[i] = table + MarkDot + c
+	}
+	return q
+}
+
+// planJoin compiles the join j of the table t to rows of the columns left,
+// the table columns being right. Equalities between an expression of the
+// left rows and one of the right rows key a hash join; the rest of the
+// condition filters the joined rows.
+func planJoin(j *Join, t Table, left, right []string, s *scope) (*joinIter, error) {
+	joined := append(append([]string(nil), left...), right...)
+	js := &scope{ctx: s.ctx, engine: s.engine, tables: s.tables, columns: joined, outer: s.outer}
+	it := &joinIter{ctx: s.ctx, table: t, left: j.Type == JoinLeft, width: len(right)}
+	var rest []Condition
+	for _, c := range conjuncts(j.On) {
+		if single, ok := c.(*SingleCondition); ok && single.Comparator == ComparatorEQ && single.Right != nil {
+			l, r := single.left(), single.Right
+			if resolves(l, right, s.tables) && resolves(r, left, s.tables) {
+				l, r = r, l
+			}
+			if resolves(l, left, s.tables) && resolves(r, right, s.tables) {
+				lkey, err := compileExpr(l, left, js)
+				if err != nil {
+					return nil, err
+				}
+				rkey, err := compileExpr(r, right, js)
+				if err != nil {
+					return nil, err
+				}
+				it.leftKeys = append(it.leftKeys, lkey)
+				it.rightKeys = append(it.rightKeys, rkey)
+				continue
+			}
+		}
+		rest = append(rest, c)
+	}
+	var err error
+	if len(rest) == 1 {
+		it.match, err = compileCondition(rest[0], js)
+	} else if len(rest) > 1 {
+		it.match, err = compileCondition(&MultiCondition{SubConditions: rest, Logic: LogicAnd}, js)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// conjuncts returns the conditions c requires together.
+func conjuncts(c Condition) []Condition {
+	switch c := c.(type) {
+	case nil:
+		return nil
+	case *MultiCondition:
+		if c.Logic == LogicAnd {
+			return c.SubConditions
+		}
+	}
+	return []Condition{c}
+}
+
+// resolves reports whether e refers to fields, all of them among columns
+// of the tables named tables.
+func resolves(e Expr, columns, tables []string) bool {
+	fields := exprFields(e)
+	for _, f := range fields {
+		if _, err := columnIndex(columns, tables, f); err != nil {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+type joinRow struct {
+	row  []interface{}
+	keys []interface{}
+}
+
+// joinIter joins its input rows with the rows of a table, which it first
+// reads into a hash table by their keys. Rows pair up when their keys are
+// equal and the rest of the join condition holds; without keys every pair
+// is tried. A left join passes on the input rows without a match, padded
+// with nulls.
+type joinIter struct {
+	ctx       context.Context
+	input     rowIterator
+	table     Table
+	leftKeys  []evaluator
+	rightKeys []evaluator
+	match     predicate
+	left      bool
+	width     int // number of columns of the table
+	buckets   map[string][]joinRow
+	pending   [][]interface{}
+}
+
+func (it *joinIter) next() ([]interface{}, error) {
+	if it.buckets == nil {
+		if err := it.build(); err != nil {
+			return nil, err
+		}
+	}
+	for len(it.pending) == 0 {
+		row, err := it.input.next()
+		if err != nil {
+			return nil, err
+		}
+		if err := it.probe(row); err != nil {
+			return nil, err
+		}
+	}
+	row := it.pending[0]
+	it.pending = it.pending[1:]
+	return row, nil
+}
+
+func (it *joinIter) build() error {
+	cursor, err := it.table.Cursor(it.ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := readAll(it.ctx, &cursorIter{cursor: cursor})
+	if cerr := cursor.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	it.buckets = make(map[string][]joinRow)
+	for _, row := range rows {
+		keys, ok, err := evalKeys(it.rightKeys, row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			k := hashKey(keys)
+			it.buckets[k] = append(it.buckets[k], joinRow{row: row, keys: keys})
+		}
+	}
+	return nil
+}
+
+// probe queues the joined rows of the input row.
+func (it *joinIter) probe(row []interface{}) error {
+	keys, ok, err := evalKeys(it.leftKeys, row)
+	if err != nil {
+		return err
+	}
+	matched := false
+	if ok {
+		for _, r := range it.buckets[hashKey(keys)] {
+			if !equalKeys(keys, r.keys) {
+				continue
+			}
+			joined := make([]interface{}, 0, len(row)+it.width)
+			joined = append(append(joined, row...), r.row...)
+			if it.match != nil {
+				if ok, err := it.match(joined); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+			it.pending = append(it.pending, joined)
+			matched = true
+		}
+	}
+	if !matched && it.left {
+		joined := make([]interface{}, len(row)+it.width)
+		copy(joined, row)
+		it.pending = append(it.pending, joined)
+	}
+	return nil
+}
+
+func (it *joinIter) close() error {
+	it.buckets, it.pending = nil, nil
+	return it.input.close()
+}
+
+// evalKeys evaluates the keys of row. A null key matches nothing, which ok
+// reports as false.
+func evalKeys(evals []evaluator, row []interface{}) (keys []interface{}, ok bool, err error) {
+	keys = make([]interface{}, len(evals))
+	for i, eval := range evals {
+		if keys[i], err = eval(row); keys[i] == nil || err != nil {
+			return nil, false, err
+		}
+	}
+	return keys, true, nil
+}
+
+// hashKey hashes keys so that values compareValues takes as equal share a
+// key: numbers, also those held by strings, hash by their value.
+func hashKey(keys []interface{}) string {
+	var b strings.Builder
+	for _, v := range keys {
+		if _, ok := v.(bool); !ok {
+			if f, ok := toNumber(v); ok {
+				b.WriteString("n:" + strconv.FormatFloat(f, 'g', -1, 64) + "\x00")
+				continue
+			}
+		}
+		if t, ok := v.(time.Time); ok {
+			// the same instant in another location is equal
+			v = t.UTC()
+		}
+		fmt.Fprintf(&b, "%T:%v\x00", v, v)
+	}
+	return b.String()
+}
+
+func equalKeys(a, b []interface{}) bool {
+	for i := range a {
+		if compareValues(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -14,6 +14,7 @@ type lexer struct {
 	pos        int    // current position in the input
 	width      int    // width of last rune read
 	parenDepth int
+	outerDepth []int     // paren depths of the queries enclosing a subquery
 	items      chan item // channel of scanned items
 }
 type stateFunc func(*lexer) stateFunc
@@ -48,7 +49,7 @@ func (l *lexer) run() {
 	close(l.items)
 }
 func (l *lexer) emit(t itemType) {
-	l.items <- item{typ: t, val: l.input[l.start:l.pos]}
+	l.items <- item{typ: t, val: l.input[l.start:l.pos], pos: l.start}
 	l.start = l.pos
 }
 func (l *lexer) next() (r rune) {
@@ -74,23 +75,34 @@ func (l *lexer) ignore() {
 	l.start = l.pos
 }
 
+// nextTerm returns the next word in lower case, so that keywords match in
+// any case. The emitted item keeps the case of the input.
 func (l *lexer) nextTerm() string {
 	l.skipSpace()
-	l.acceptRun(letter)
+	l.acceptTerm()
 	l.width = l.pos - l.start
-	return l.input[l.start:l.pos]
+	return strings.ToLower(l.input[l.start:l.pos])
 }
 func (l *lexer) nextTermWithDot() (string, bool) {
 	l.skipSpace()
-	l.acceptRun(letter)
+	l.acceptTerm()
 	for l.accept(MarkDot) {
-		if sub := l.nextTerm(); sub == "" {
-			return l.input[l.start:l.pos], false
+		pos := l.pos
+		if l.acceptTerm(); l.pos == pos {
+			return strings.ToLower(l.input[l.start:l.pos]), false
 		}
 	}
 	l.width = l.pos - l.start
-	return l.input[l.start:l.pos], true
+	return strings.ToLower(l.input[l.start:l.pos]), true
 }
+
+// acceptTerm consumes an identifier or keyword, if one starts here.
+func (l *lexer) acceptTerm() {
+	if l.accept(letter) {
+		l.acceptRun(identifier)
+	}
+}
+
 func (l *lexer) backupTerm() {
 	l.pos -= l.width
 }
@@ -100,17 +112,81 @@ func (l *lexer) peekTerm() string {
 	return s
 }
 
+// peekClause returns the keyword starting at the current position without
+// consuming it. The two-word keywords "group by", "order by" and
+// "partition by" are reported as KeyGroupBy, KeyOrderBy and
+// KeyPartitionBy, and the joins "inner join", "left [outer] join" and
+// "cross join" as KeyInnerJoin, KeyLeftJoin and KeyCrossJoin.
+func (l *lexer) peekClause() string {
+	start, pos := l.start, l.pos
+	defer func() {
+		l.start, l.pos = start, pos
+	}()
+	switch n := l.nextTerm(); n {
+	case "group":
+		if l.nextTerm() == "by" {
+			return KeyGroupBy
+		}
+		return n
+	case "order":
+		if l.nextTerm() == "by" {
+			return KeyOrderBy
+		}
+		return n
+	case "partition":
+		if l.nextTerm() == "by" {
+			return KeyPartitionBy
+		}
+		return n
+	case "inner", "cross":
+		if l.nextTerm() == KeyJoin {
+			return n + KeyJoin
+		}
+		return n
+	case "left":
+		t := l.nextTerm()
+		if t == "outer" {
+			t = l.nextTerm()
+		}
+		if t == KeyJoin {
+			return KeyLeftJoin
+		}
+		return n
+	default:
+		return n
+	}
+}
+
+// acceptClause consumes a two-word keyword such as "group by", keeping
+// both words in the pending item.
+func (l *lexer) acceptClause() {
+	l.nextTerm()
+	start := l.start
+	l.nextTerm()
+	l.start = start
+}
+
+// acceptJoin consumes the words of a join keyword such as "left outer
+// join", keeping them in the pending item.
+func (l *lexer) acceptJoin() {
+	start := l.start
+	for t := l.nextTerm(); t != KeyJoin && t != ""; t = l.nextTerm() {
+	}
+	l.start = start
+}
+
 func (l *lexer) errorf(format string, args ...interface{}) stateFunc {
 	l.items <- item{
 		itemError,
 		fmt.Sprintf(format, args...),
+		l.pos,
 	}
 	return nil
 }
 func (l *lexer) skipSpace() bool {
 	skipped := false
 	for {
-		if l.accept(Space) {
+		if l.accept(whitespace) {
 			skipped = true
 			continue
 		}
@@ -134,61 +210,66 @@ func (l *lexer) acceptRun(valid string) {
 }
 func lexStart(l *lexer) stateFunc {
 	l.skipSpace()
-	if l.nextTerm() == KeySelect {
+	switch l.nextTerm() {
+	case KeySelect:
 		l.emit(itemSelect)
 		return lexField
+	case KeyInsert:
+		if !l.acceptKeyword(KeyInto) {
+			return l.errorf("syntax error: into expected after insert")
+		}
+		l.emit(itemInsert)
+		return lexInsert
+	case KeyUpdate:
+		l.emit(itemUpdate)
+		return lexUpdate
+	case KeyDelete:
+		if !l.acceptKeyword(KeyFrom) {
+			return l.errorf("syntax error: from expected after delete")
+		}
+		l.emit(itemDelete)
+		return lexDelete
+	case KeyCreate:
+		if !l.acceptKeyword(KeyTable) {
+			return l.errorf("syntax error: table expected after create")
+		}
+		l.emit(itemCreate)
+		return lexCreate
 	}
 	l.backupTerm()
+	if l.pos >= len(l.input) {
+		return l.errorf("syntax error: select expected at end")
+	}
 	return l.errorf("syntax error: start with %q", l.input[l.pos])
 }
 
+// acceptKeyword consumes the word following a keyword when it is key,
+// keeping both in the pending item.
+func (l *lexer) acceptKeyword(key string) bool {
+	start := l.start
+	ok := l.nextTerm() == key
+	l.start = start
+	return ok
+}
+
 // TODO: rewrite this function and add " as xxx "/ "as "xxx" "
 // TODO: add "*" to indicating get all the fields
 func lexField(l *lexer) stateFunc {
-	l.skipSpace()
+	if l.peekTerm() == KeyDistinct {
+		// select distinct(x) is an aggragation, select distinct x a modifier
+		l.acceptDistinct()
+	}
 	for {
-		if s, ok := l.nextTermWithDot(); s != "" && ok {
-			if agg, ok := AggragationToType[s]; ok {
-				l.emit(agg)
-				l.skipSpace()
-				if !l.accept(MarkLeftParen) {
-					return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-				}
-				l.emit(itemLeftParen)
-				// TODO: take count(*) into consideration
-				if aggField, ok := l.nextTermWithDot(); aggField != "" && ok {
-					l.emit(itemIdentifier)
-					l.skipSpace()
-					if !l.accept(MarkRightParen) {
-						return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-					}
-					l.emit(itemRightParen)
-					l.skipSpace()
-					if !l.accept(MakrComma) {
-						break
-					} else {
-						l.emit(itemComma)
-					}
-				} else {
-					return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-				}
-			} else {
-				l.emit(itemIdentifier)
-				l.skipSpace()
-				// if n := l.nextTerm(); n == KeyAs {
-				//
-				// }
-				if !l.accept(MakrComma) {
-					break
-				} else {
-					l.emit(itemComma)
-				}
-			}
-		} else {
-			return l.errorf("syntax error: query field %q not valid", l.input[l.pos:])
+		if !l.emitExpr() {
+			return nil
 		}
+		l.skipSpace()
+		if !l.accept(MakrComma) {
+			break
+		}
+		l.emit(itemComma)
 	}
-	switch l.peekTerm() {
+	switch l.peekClause() {
 	case KeyFrom:
 		return lexFrom
 	case KeyWhere:
@@ -197,6 +278,10 @@ func lexField(l *lexer) stateFunc {
 		return lexGroupBy
 	case KeyOrderBy:
 		return lexOrderBy
+	case KeyLimit:
+		return lexLimit
+	case KeyOffset:
+		return lexOffset
 	}
 	return lexCheckEnd
 }
@@ -204,20 +289,222 @@ func lexField(l *lexer) stateFunc {
 func lexFrom(l *lexer) stateFunc {
 	l.nextTerm()
 	l.emit(itemFrom)
-	if table := l.nextTerm(); table != "" {
+	return lexTable
+}
+
+// tableKeywords are the words that may follow a table, which cannot alias
+// it.
+var tableKeywords = map[string]bool{
+	KeyWhere: true, "group": true, "order": true, KeyLimit: true, KeyOffset: true,
+	KeyJoin: true, "inner": true, "left": true, "cross": true, KeyOn: true,
+	KeyUnion: true, KeyIntersect: true, KeyExcept: true,
+}
+
+// lexTable lexes a table of the from clause and its optional alias.
+func lexTable(l *lexer) stateFunc {
+	if table := l.nextTerm(); table == "" || tableKeywords[table] {
+		return l.errorf("syntax error: table name %q not valid", l.input[l.start:])
+	}
+	l.emit(itemIdentifier)
+	if l.peekTerm() == KeyAs {
+		l.nextTerm()
+		l.emit(itemAs)
+		if alias := l.nextTerm(); alias == "" || tableKeywords[alias] {
+			return l.errorf("syntax error: table alias %q not valid", l.input[l.start:])
+		}
+		l.emit(itemIdentifier)
+	} else if alias := l.peekTerm(); alias != "" && !tableKeywords[alias] {
+		l.nextTerm()
+		l.emit(itemIdentifier)
+	}
+	return lexJoin
+}
+
+// lexJoin lexes what follows a table of the from clause: another table
+// after a comma or a join, the condition of a join, or the next clause.
+func lexJoin(l *lexer) stateFunc {
+	l.skipSpace()
+	if l.accept(MakrComma) {
+		l.emit(itemComma)
+		return lexTable
+	}
+	switch l.peekClause() {
+	case KeyJoin, KeyInnerJoin:
+		l.acceptJoin()
+		l.emit(itemJoin)
+		return lexTable
+	case KeyLeftJoin:
+		l.acceptJoin()
+		l.emit(itemLeftJoin)
+		return lexTable
+	case KeyCrossJoin:
+		l.acceptJoin()
+		l.emit(itemCrossJoin)
+		return lexTable
+	case KeyOn:
+		l.nextTerm()
+		l.emit(itemOn)
+		return lexCondition
+	case KeyWhere:
+		return lexWhere
+	case KeyGroupBy:
+		return lexGroupBy
+	case KeyOrderBy:
+		return lexOrderBy
+	case KeyLimit:
+		return lexLimit
+	case KeyOffset:
+		return lexOffset
+	}
+	return lexCheckEnd
+}
+
+// lexInsert lexes the table of an insert, its optional list of columns and
+// the rows of values.
+func lexInsert(l *lexer) stateFunc {
+	if table := l.nextTerm(); table == "" || table == KeyValues {
+		return l.errorf("syntax error: table name %q not valid", l.input[l.start:])
+	}
+	l.emit(itemIdentifier)
+	l.skipSpace()
+	if l.accept(MarkLeftParen) {
+		l.emit(itemLeftParen)
+		for {
+			if !l.emitColumn() {
+				return nil
+			}
+			l.skipSpace()
+			if l.accept(MarkRightParen) {
+				l.emit(itemRightParen)
+				break
+			}
+			if !l.accept(MakrComma) {
+				return l.errorf("syntax error: columns %q not valid", l.input[l.pos:])
+			}
+			l.emit(itemComma)
+		}
+	}
+	if l.nextTerm() != KeyValues {
+		l.backupTerm()
+		return l.errorf("syntax error: values expected before %q", l.input[l.pos:])
+	}
+	l.emit(itemValues)
+	for {
+		l.skipSpace()
+		if !l.accept(MarkLeftParen) {
+			return l.errorf("syntax error: values %q not valid", l.input[l.pos:])
+		}
+		l.emit(itemLeftParen)
+		for {
+			if !l.emitExpr() {
+				return nil
+			}
+			l.skipSpace()
+			if l.accept(MarkRightParen) {
+				l.emit(itemRightParen)
+				break
+			}
+			if !l.accept(MakrComma) {
+				return l.errorf("syntax error: values %q not valid", l.input[l.pos:])
+			}
+			l.emit(itemComma)
+		}
+		l.skipSpace()
+		if !l.accept(MakrComma) {
+			return lexCheckEnd
+		}
+		l.emit(itemComma)
+	}
+}
+
+// lexUpdate lexes the table of an update and its assignments.
+func lexUpdate(l *lexer) stateFunc {
+	if table := l.nextTerm(); table == "" || table == KeySet {
+		return l.errorf("syntax error: table name %q not valid", l.input[l.start:])
+	}
+	l.emit(itemIdentifier)
+	if l.nextTerm() != KeySet {
+		l.backupTerm()
+		return l.errorf("syntax error: set expected before %q", l.input[l.pos:])
+	}
+	l.emit(itemSet)
+	for {
+		if !l.emitColumn() {
+			return nil
+		}
+		l.skipSpace()
+		if !l.accept("=") {
+			return l.errorf("syntax error: = expected before %q", l.input[l.pos:])
+		}
+		l.emit(itemEqual)
+		if !l.emitExpr() {
+			return nil
+		}
+		l.skipSpace()
+		if !l.accept(MakrComma) {
+			break
+		}
+		l.emit(itemComma)
+	}
+	if l.peekClause() == KeyWhere {
+		return lexWhere
+	}
+	return lexCheckEnd
+}
+
+// lexDelete lexes the table of a delete.
+func lexDelete(l *lexer) stateFunc {
+	if table := l.nextTerm(); table == "" || tableKeywords[table] {
+		return l.errorf("syntax error: table name %q not valid", l.input[l.start:])
+	}
+	l.emit(itemIdentifier)
+	if l.peekClause() == KeyWhere {
+		return lexWhere
+	}
+	return lexCheckEnd
+}
+
+// lexCreate lexes the table and the columns with their types of a create
+// table statement.
+func lexCreate(l *lexer) stateFunc {
+	if table := l.nextTerm(); table == "" {
+		return l.errorf("syntax error: table name %q not valid", l.input[l.start:])
+	}
+	l.emit(itemIdentifier)
+	l.skipSpace()
+	if !l.accept(MarkLeftParen) {
+		return l.errorf("syntax error: columns expected before %q", l.input[l.pos:])
+	}
+	l.emit(itemLeftParen)
+	for {
+		if !l.emitColumn() {
+			return nil
+		}
+		if l.nextTerm() == "" {
+			return l.errorf("syntax error: column type expected before %q", l.input[l.pos:])
+		}
 		l.emit(itemIdentifier)
-		switch l.peekTerm() {
-		case KeyWhere:
-			return lexWhere
-		case KeyGroupBy:
-			return lexGroupBy
-		case KeyOrderBy:
-			return lexOrderBy
+		l.skipSpace()
+		if l.accept(MarkRightParen) {
+			l.emit(itemRightParen)
+			break
+		}
+		if !l.accept(MakrComma) {
+			return l.errorf("syntax error: columns %q not valid", l.input[l.pos:])
 		}
-		return lexCheckEnd
-	} else {
-		return l.errorf("syntax error: table name %q not valid", l.input[l.start:l.pos])
+		l.emit(itemComma)
 	}
+	return lexCheckEnd
+}
+
+// emitColumn emits the column an insert or update assigns.
+func (l *lexer) emitColumn() bool {
+	if s, ok := l.nextTermWithDot(); s == "" || !ok {
+		l.errorf("syntax error: column %q not valid", l.input[l.start:])
+		return false
+	}
+	l.emit(itemIdentifier)
+	return true
 }
 
 func lexWhere(l *lexer) stateFunc {
@@ -232,18 +519,21 @@ func lexCondition(l *lexer) stateFunc {
 	case r == '(':
 		l.emit(itemLeftParen)
 		l.parenDepth++
+		return lexCondition
 	case r == ')':
-		l.emit(itemRightParen)
-		l.parenDepth--
-		if l.parenDepth < 0 {
-			return l.errorf("unexpected right paren")
-		}
+		return l.errorf("unexpected right paren")
 	default:
 		l.backup()
 		term := l.nextTerm()
 		if term == KeyNot {
 			l.emit(itemNot)
 			return lexCondition
+		} else if term == KeyExists {
+			l.emit(itemExists)
+			if !l.peekSubquery() {
+				return l.errorf("syntax error: exists takes a subquery, got %q", l.input[l.pos:])
+			}
+			return lexSubquery
 		} else {
 			l.backupTerm()
 		}
@@ -251,47 +541,19 @@ func lexCondition(l *lexer) stateFunc {
 	return lexLeftHandSide
 }
 func lexLeftHandSide(l *lexer) stateFunc {
-	l.skipSpace()
-	switch r := l.next(); {
-	case r == '"':
-		for n := l.next(); n != '"'; {
-			if n == eof {
-				l.errorf("upclosed string")
-			}
-		}
-		l.emit(itemString)
-	case r == '+' || r == '-' || '0' <= r && r <= '9':
-		curDigits := digits
-		l.backup()
-		l.accept("+-")
-
-		if l.accept("0") && l.accept("xX") {
-			curDigits += "abcdefABCDEF"
-		}
-
-		l.acceptRun(curDigits)
-		if l.accept(".") {
-			l.acceptRun(curDigits)
-		}
-
-		if l.accept("eE") {
-			l.accept("+-")
-			l.acceptRun(digits)
-		}
-		l.emit(itemNumber)
-	case unicode.IsLetter(r):
-		l.backup()
-		l.nextTermWithDot()
-		l.emit(itemIdentifier)
-	default:
-		return l.errorf("syntax error: condition")
+	if !l.emitExpr() {
+		return nil
 	}
 	return lexCompare
 }
 
 func lexCompare(l *lexer) stateFunc {
 	l.skipSpace()
-	switch r := l.next(); {
+	if l.peek() == ')' {
+		// the paren closes an expression, which the comparison follows
+		return lexLogic
+	}
+	switch r := unicode.ToLower(l.next()); {
 	case r == '>':
 		if l.accept("=") {
 			l.emit(itemGreaterEqual)
@@ -307,33 +569,407 @@ func lexCompare(l *lexer) stateFunc {
 	case r == '!':
 		if l.accept("=") {
 			l.emit(itemNotEqual)
+		} else if l.accept("~") {
+			l.emit(itemNotRegexp)
+			return lexPattern
 		} else {
-			return l.errorf("syntax error: ")
+			return l.errorf("syntax error: != or !~ expected before %q", l.input[l.start:])
 		}
 	case r == '=':
 		l.emit(itemEqual)
-	case r == 'l':
+	case r == '~':
+		l.emit(itemRegexp)
+		return lexPattern
+	case r == 'l' || r == 'i' || r == 'n' || r == 'r':
 		l.backup()
-		if n := l.nextTerm(); n == KeyLike {
-			l.emit(itemLike)
-		} else {
-			return l.errorf("syntax error: ")
+		switch n := l.nextTerm(); n {
+		case KeyNot:
+			l.emit(itemNot)
+			switch n := l.nextTerm(); {
+			case n == KeyIn:
+				l.emit(itemIn)
+				return lexInList
+			case !l.emitMatch(n):
+				return l.errorf("syntax error: in, like, ilike or regexp expected after not before %q", l.input[l.start:])
+			}
+			return lexPattern
+		case KeyLike, KeyILike, KeyRegexp, KeyRLike:
+			l.emitMatch(n)
+			return lexPattern
+		case KeyIn:
+			l.emit(itemIn)
+			return lexInList
 		}
+		return l.errorf("syntax error: comparison expected before %q", l.input[l.start:])
 	default:
-		return l.errorf("syntax error: ")
+		return l.errorf("syntax error: comparison expected before %q", l.input[l.start:])
 	}
 	return lexRightHandSide
 }
 
+// peekComparator reports whether a comparison operator starts here.
+func (l *lexer) peekComparator() bool {
+	if strings.ContainsRune("<>=!~", l.peek()) {
+		return true
+	}
+	start, pos := l.start, l.pos
+	defer func() {
+		l.start, l.pos = start, pos
+	}()
+	switch l.nextTerm() {
+	case KeyLike, KeyILike, KeyRegexp, KeyRLike, KeyIn:
+		return true
+	case KeyNot:
+		switch l.nextTerm() {
+		case KeyLike, KeyILike, KeyRegexp, KeyRLike, KeyIn:
+			return true
+		}
+	}
+	return false
+}
+
+// emitMatch emits the like, ilike or regexp of the word term, reporting
+// whether it was one. rlike is another name for regexp.
+func (l *lexer) emitMatch(term string) bool {
+	switch term {
+	case KeyLike:
+		l.emit(itemLike)
+	case KeyILike:
+		l.emit(itemILike)
+	case KeyRegexp, KeyRLike:
+		l.emit(itemRegexp)
+	default:
+		return false
+	}
+	return true
+}
+
+// lexPattern lexes the pattern following like or regexp, and the escape
+// character of a like, if any. The escape may be in single quotes, where a
+// backslash stands for itself, as in escape '\'.
+func lexPattern(l *lexer) stateFunc {
+	if !l.emitExpr() {
+		return nil
+	}
+	l.skipSpace()
+	if l.peekTerm() == KeyEscape {
+		l.nextTerm()
+		l.emit(itemEscape)
+		l.skipSpace()
+		if l.peek() == '\'' {
+			if !l.emitQuoted(itemString) {
+				return nil
+			}
+		} else if !l.emitValue() {
+			return nil
+		}
+	}
+	return lexLogic
+}
+
+// emitQuoted emits the quoted string that follows as an item of type typ,
+// along with the keyword of a typed literal if one was consumed. The text
+// may be in single quotes, which are doubled within it, or in double quotes
+// like other strings.
+func (l *lexer) emitQuoted(typ itemType) bool {
+	l.acceptRun(whitespace)
+	quote := l.next()
+	for {
+		switch n := l.next(); {
+		case n == eof:
+			l.errorf("unclosed string")
+			return false
+		case n == '\\' && quote == '"':
+			l.next()
+		case n == quote && quote == '\'' && l.peek() == '\'':
+			l.next()
+		case n == quote:
+			l.emit(typ)
+			return true
+		}
+	}
+}
+
 func lexRightHandSide(l *lexer) stateFunc {
+	if l.peekSubquery() {
+		return lexSubquery
+	}
+	if !l.emitExpr() {
+		return nil
+	}
+	return lexLogic
+}
+
+// peekSubquery reports whether a parenthesized select starts here.
+func (l *lexer) peekSubquery() bool {
+	rest := strings.TrimLeft(l.input[l.pos:], whitespace)
+	if !strings.HasPrefix(rest, MarkLeftParen) {
+		return false
+	}
+	rest = strings.TrimLeft(rest[1:], whitespace)
+	n := len(KeySelect)
+	return len(rest) >= n && strings.EqualFold(rest[:n], KeySelect) &&
+		(len(rest) == n || !strings.ContainsRune(identifier, rune(rest[n])))
+}
+
+// lexSubquery lexes the paren opening a subquery, which is lexed as a query
+// of its own. Its parens are counted apart from those of the enclosing
+// query, which goes on with lexLogic once lexCheckEnd meets the closing
+// paren.
+func lexSubquery(l *lexer) stateFunc {
+	l.skipSpace()
+	l.accept(MarkLeftParen)
+	l.emit(itemLeftParen)
+	l.outerDepth = append(l.outerDepth, l.parenDepth)
+	l.parenDepth = 0
+	return lexStart
+}
+
+// lexInList lexes the parenthesized values following "in".
+func lexInList(l *lexer) stateFunc {
+	if l.peekSubquery() {
+		return lexSubquery
+	}
+	l.skipSpace()
+	if !l.accept(MarkLeftParen) {
+		return l.errorf("syntax error: in list %q not valid", l.input[l.pos:])
+	}
+	l.emit(itemLeftParen)
+	for {
+		if !l.emitValue() {
+			return nil
+		}
+		l.skipSpace()
+		if l.accept(MarkRightParen) {
+			l.emit(itemRightParen)
+			return lexLogic
+		}
+		if !l.accept(MakrComma) {
+			return l.errorf("syntax error: in list %q not valid", l.input[l.pos:])
+		}
+		l.emit(itemComma)
+	}
+}
+
+// emitExpr emits the items of an expression: operands joined by arithmetic
+// and string operators. It reports an error and returns false when there is
+// no expression.
+func (l *lexer) emitExpr() bool {
+	for {
+		if !l.emitOperand() {
+			return false
+		}
+		if !l.acceptOperator() {
+			return true
+		}
+	}
+}
+
+// acceptOperator emits the arithmetic or string operator at the current
+// position, if there is one.
+func (l *lexer) acceptOperator() bool {
+	l.skipSpace()
+	if strings.HasPrefix(l.input[l.pos:], "||") {
+		l.pos += 2
+		l.emit(itemConcat)
+		return true
+	}
+	switch l.next() {
+	case '+':
+		l.emit(itemPlus)
+	case '-':
+		l.emit(itemMinus)
+	case '*':
+		l.emit(itemMul)
+	case '/':
+		l.emit(itemDiv)
+	case '%':
+		l.emit(itemMod)
+	default:
+		l.backup()
+		return false
+	}
+	return true
+}
+
+// emitOperand emits an operand: a field, a literal, a parenthesized or
+// negated expression, or a call of a function or an aggragation, whose
+// arguments are expressions again, and the window it may be computed over.
+// An aggragation name not followed by a paren is taken as a field.
+func (l *lexer) emitOperand() bool {
 	l.skipSpace()
 	switch r := l.next(); {
+	case r == '(':
+		l.emit(itemLeftParen)
+		if !l.emitExpr() {
+			return false
+		}
+		l.skipSpace()
+		if !l.accept(MarkRightParen) {
+			l.errorf("syntax error: unclosed paren in %q", l.input[l.pos:])
+			return false
+		}
+		l.emit(itemRightParen)
+		return true
+	case (r == '-' || r == '+') && !strings.ContainsRune(digits+MarkDot, l.peek()):
+		if r == '-' {
+			l.emit(itemMinus)
+		} else {
+			l.emit(itemPlus)
+		}
+		return l.emitOperand()
+	case !unicode.IsLetter(r):
+		l.backup()
+		if strings.ContainsRune(`?$:"+-.`+digits, r) {
+			return l.emitValue()
+		}
+		l.errorf("syntax error: query field %q not valid", l.input[l.pos:])
+		return false
+	}
+	l.backup()
+	s, ok := l.nextTermWithDot()
+	if !ok {
+		l.errorf("syntax error: query field %q not valid", l.input[l.start:])
+		return false
+	}
+	if typ, ok := typedLiterals[s]; ok && l.peekQuote() {
+		return l.emitQuoted(typ)
+	}
+	call := strings.HasPrefix(strings.TrimLeft(l.input[l.pos:], whitespace), MarkLeftParen)
+	agg, isAgg := AggragationToType[s]
+	switch {
+	case call && isAgg:
+		l.emit(agg)
+	case call:
+		l.emit(itemIdentifier)
+	case s == KeyTrue || s == KeyFalse:
+		l.emit(itemBool)
+		return true
+	default:
+		l.emit(itemIdentifier)
+		return true
+	}
+	l.skipSpace()
+	l.accept(MarkLeftParen)
+	l.emit(itemLeftParen)
+	l.skipSpace()
+	// TODO: take count(*) into consideration
+	if l.accept(MarkRightParen) {
+		l.emit(itemRightParen)
+		return l.acceptOver()
+	}
+	if isAgg && l.peekTerm() == KeyDistinct {
+		// distinct(x) is an aggragation itself, count(distinct x) a modifier
+		l.acceptDistinct()
+	}
+	for {
+		if !l.emitExpr() {
+			return false
+		}
+		l.skipSpace()
+		if l.accept(MarkRightParen) {
+			l.emit(itemRightParen)
+			return l.acceptOver()
+		}
+		if !l.accept(MakrComma) {
+			l.errorf("syntax error: arguments %q not valid", l.input[l.pos:])
+			return false
+		}
+		l.emit(itemComma)
+	}
+}
+
+// acceptOver emits the over clause following a call, if there is one: the
+// partition by and the order by of a window, both optional, in parens. It
+// reports an error and returns false when the clause is not valid.
+func (l *lexer) acceptOver() bool {
+	if l.peekTerm() != KeyOver {
+		return true
+	}
+	l.nextTerm()
+	l.emit(itemOver)
+	l.skipSpace()
+	if !l.accept(MarkLeftParen) {
+		l.errorf("syntax error: window %q not valid", l.input[l.pos:])
+		return false
+	}
+	l.emit(itemLeftParen)
+	if l.peekClause() == KeyPartitionBy {
+		l.acceptClause()
+		l.emit(itemPartitionBy)
+		for {
+			if !l.emitExpr() {
+				return false
+			}
+			l.skipSpace()
+			if !l.accept(MakrComma) {
+				break
+			}
+			l.emit(itemComma)
+		}
+	}
+	if l.peekClause() == KeyOrderBy {
+		l.acceptClause()
+		l.emit(itemOrderBy)
+		for {
+			if !l.emitExpr() {
+				return false
+			}
+			l.acceptDirection()
+			if !l.accept(MakrComma) {
+				break
+			}
+			l.emit(itemComma)
+		}
+	}
+	l.skipSpace()
+	if !l.accept(MarkRightParen) {
+		l.errorf("syntax error: window %q not valid", l.input[l.pos:])
+		return false
+	}
+	l.emit(itemRightParen)
+	return true
+}
+
+// acceptDistinct emits the distinct modifying a select or the argument of
+// an aggragation. It leaves distinct alone when a paren, a comma or nothing
+// follows, where it is the distinct aggragation or a field.
+func (l *lexer) acceptDistinct() {
+	l.nextTerm()
+	if rest := strings.TrimLeft(l.input[l.pos:], whitespace); rest == "" || strings.ContainsRune("(,)", rune(rest[0])) {
+		l.backupTerm()
+		return
+	}
+	l.emit(itemDistinctArg)
+}
+
+// emitValue emits the literal or placeholder at the current position. It
+// reports an error and returns false when there is none.
+func (l *lexer) emitValue() bool {
+	l.skipSpace()
+	switch r := l.next(); {
+	case r == '?':
+		l.emit(itemPlaceholder)
+	case r == '$' || r == ':':
+		if r == '$' {
+			l.acceptRun(digits)
+		} else {
+			l.acceptTerm()
+		}
+		if l.pos-l.start == 1 {
+			l.errorf("syntax error: placeholder %q not valid", l.input[l.start:])
+			return false
+		}
+		l.emit(itemPlaceholder)
 	case r == '"':
-		for n := l.next(); n != '"'; {
+		for n := l.next(); n != '"'; n = l.next() {
+			if n == '\\' {
+				n = l.next()
+			}
 			if n == eof {
-				l.errorf("upclosed string")
+				l.errorf("unclosed string")
+				return false
 			}
-			n = l.next()
 		}
 		l.emit(itemString)
 	case r == '+' || r == '-' || '0' <= r && r <= '9':
@@ -358,78 +994,84 @@ func lexRightHandSide(l *lexer) stateFunc {
 	case unicode.IsLetter(r):
 		l.backup()
 		s, _ := l.nextTermWithDot()
+		if typ, ok := typedLiterals[s]; ok && l.peekQuote() {
+			return l.emitQuoted(typ)
+		}
 		if s == KeyTrue || s == KeyFalse {
 			l.emit(itemBool)
 		} else {
 			l.emit(itemIdentifier)
 		}
-
 	default:
-		return l.errorf("syntax error: condition")
+		l.errorf("syntax error: condition")
+		return false
 	}
-	return lexLogic
+	return true
+}
+
+// peekQuote reports whether a quoted string follows.
+func (l *lexer) peekQuote() bool {
+	rest := strings.TrimLeft(l.input[l.pos:], whitespace)
+	return rest != "" && (rest[0] == '\'' || rest[0] == '"')
 }
 
 func lexLogic(l *lexer) stateFunc {
 	l.skipSpace()
-	for l.accept(")") {
+	closed := false
+	// a right paren at depth 0 of a subquery closes it
+	for (l.parenDepth > 0 || len(l.outerDepth) == 0) && l.accept(MarkRightParen) {
 		l.emit(itemRightParen)
 		l.parenDepth--
 		if l.parenDepth < 0 {
 			return l.errorf("unexpected right paren")
 		}
 		l.skipSpace()
+		closed = true
 	}
-	l.skipSpace()
-	switch r := l.next(); {
-	case r == 'a':
-		l.backup()
-		if n := l.nextTerm(); n != KeyAnd {
-			return l.errorf("syntax error: ")
+	// A parenthesized expression goes on with an operator or a comparison.
+	if closed && l.acceptOperator() {
+		if !l.emitExpr() {
+			return nil
 		}
+		return lexCompare
+	}
+	if closed && l.peekComparator() {
+		return lexCompare
+	}
+	switch l.peekClause() {
+	case KeyAnd:
+		l.nextTerm()
 		l.emit(itemAnd)
-	case r == 'o':
-		l.backup()
-		n := l.nextTerm()
-		if n == KeyOr {
-			l.emit(itemOr)
-		} else if n == "order" {
-			if l.parenDepth != 0 {
-				return l.errorf("syntax error: unclosed paren")
-			}
-			start := l.start
-			if l.nextTerm() == "by" {
-				l.start = start
-				return lexOrderBy
-			}
-			return l.errorf("syntax error: ")
-		} else {
-			return l.errorf("syntax error: ")
-		}
-
-	case r == 'g':
-		l.backup()
-		// TODO : pos not correct
-		if l.nextTerm() == "group" {
-			if l.parenDepth != 0 {
-				return l.errorf("syntax error: unclosed paren")
-			}
-			start := l.start
-			if l.nextTerm() == "by" {
-				l.start = start
-				return lexGroupBy
-			}
-			return l.errorf("syntax error: ")
-		} else {
-			return l.errorf("syntax error: ")
-		}
-	case r == eof:
-		return nil
+		return lexCondition
+	case KeyOr:
+		l.nextTerm()
+		l.emit(itemOr)
+		return lexCondition
 	}
-	return lexCondition
+	if l.parenDepth != 0 {
+		return l.errorf("syntax error: unclosed paren")
+	}
+	if l.peek() == ',' {
+		// a table following the condition of a join
+		return lexJoin
+	}
+	switch l.peekClause() {
+	case KeyWhere, KeyJoin, KeyInnerJoin, KeyLeftJoin, KeyCrossJoin:
+		return lexJoin
+	case KeyGroupBy:
+		return lexGroupBy
+	case KeyOrderBy:
+		return lexOrderBy
+	case KeyLimit:
+		return lexLimit
+	case KeyOffset:
+		return lexOffset
+	}
+	return lexCheckEnd
 }
 
 func lexGroupBy(l *lexer) stateFunc {
+	l.acceptClause()
 	l.emit(itemGroupBy)
 	for {
 		if s, ok := l.nextTermWithDot(); s != "" && ok {
@@ -469,79 +1111,107 @@ func lexGroupBy(l *lexer) stateFunc {
 			return l.errorf("syntax error: query field %q not valid", l.input[l.pos:])
 		}
 	}
-	n := l.nextTerm()
-	if n == "order" {
-		start := l.start
-		if l.nextTerm() == "by" {
-			l.start = start
-			return lexOrderBy
-		}
-		return l.errorf("syntax error: ")
+	switch l.peekClause() {
+	case KeyOrderBy:
+		return lexOrderBy
+	case KeyLimit:
+		return lexLimit
+	case KeyOffset:
+		return lexOffset
 	}
 	return lexCheckEnd
 }
 
 func lexOrderBy(l *lexer) stateFunc {
+	l.acceptClause()
 	l.emit(itemOrderBy)
 	for {
-		if s, ok := l.nextTermWithDot(); s != "" && ok {
-			if agg, ok := AggragationToType[s]; ok {
-				l.emit(agg)
-				l.skipSpace()
-				if !l.accept(MarkLeftParen) {
-					return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-				}
-				l.emit(itemLeftParen)
-				if aggField, ok := l.nextTermWithDot(); aggField != "" && ok {
-					l.emit(itemIdentifier)
-					l.skipSpace()
-					if !l.accept(MarkRightParen) {
-						return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-					}
-					l.emit(itemRightParen)
-					l.skipSpace()
-					if !l.accept(MakrComma) {
-						break
-					} else {
-						l.emit(itemComma)
-					}
-				} else {
-					return l.errorf("syntax error: aggragation error, %q", l.input[l.pos:])
-				}
-			} else {
-				l.emit(itemIdentifier)
-				l.skipSpace()
-				if !l.accept(MakrComma) {
-					break
-				} else {
-					l.emit(itemComma)
-				}
-			}
-		} else {
-			return l.errorf("syntax error: query field %q not valid", l.input[l.pos:])
+		if !l.emitExpr() {
+			return nil
 		}
+		l.acceptDirection()
+		if !l.accept(MakrComma) {
+			break
+		}
+		l.emit(itemComma)
 	}
-	n := l.nextTerm()
-	if n == KeyDesc {
-		return lexDesc
-	} else if n == KeyAsc {
-		return lexAsc
+	switch l.peekClause() {
+	case KeyLimit:
+		return lexLimit
+	case KeyOffset:
+		return lexOffset
 	}
 	return lexCheckEnd
 }
 
-func lexDesc(l *lexer) stateFunc {
-	l.emit(itemDesc)
+// acceptDirection emits the optional asc or desc following an order by item.
+func (l *lexer) acceptDirection() {
+	switch l.peekTerm() {
+	case KeyAsc:
+		l.nextTerm()
+		l.emit(itemAsc)
+	case KeyDesc:
+		l.nextTerm()
+		l.emit(itemDesc)
+	}
+	l.skipSpace()
+}
+
+func lexLimit(l *lexer) stateFunc {
+	l.nextTerm()
+	l.emit(itemLimit)
+	if !l.emitCount(KeyLimit) {
+		return nil
+	}
+	if l.peekClause() == KeyOffset {
+		return lexOffset
+	}
 	return lexCheckEnd
 }
 
-func lexAsc(l *lexer) stateFunc {
-	l.emit(itemAsc)
+func lexOffset(l *lexer) stateFunc {
+	l.nextTerm()
+	l.emit(itemOffset)
+	if !l.emitCount(KeyOffset) {
+		return nil
+	}
 	return lexCheckEnd
 }
 
+// emitCount emits the number or placeholder following limit or offset.
+func (l *lexer) emitCount(clause string) bool {
+	l.skipSpace()
+	switch l.peek() {
+	case '?', '$', ':':
+		return l.emitValue()
+	}
+	if l.acceptRun(digits); l.pos == l.start {
+		l.errorf("syntax error: %s %q not valid", clause, l.input[l.pos:])
+		return false
+	}
+	l.emit(itemNumber)
+	return true
+}
+
 func lexCheckEnd(l *lexer) stateFunc {
 	l.skipSpace()
+	if op, ok := setOperators[l.peekTerm()]; ok {
+		l.nextTerm()
+		l.emit(op)
+		if l.peekTerm() == KeyAll {
+			l.nextTerm()
+			l.emit(itemAll)
+		}
+		return lexStart
+	}
+	if n := len(l.outerDepth); n > 0 {
+		if !l.accept(MarkRightParen) {
+			return l.errorf("syntax error: unclosed subquery before %q", l.input[l.pos:])
+		}
+		l.emit(itemRightParen)
+		l.parenDepth, l.outerDepth = l.outerDepth[n-1], l.outerDepth[:n-1]
+		return lexLogic
+	}
 	if l.pos >= len(l.input) {
 		l.emit(itemEOF)
 		return nil
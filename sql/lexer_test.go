@@ -12,19 +12,19 @@ type lexTest struct {
 
 var lexStartTest = []lexTest{
 	{
-		"select", []item{{itemSelect, "select"}},
+		"select", []item{{itemSelect, "select", 0}},
 	},
 	{
-		" select", []item{{itemSelect, "select"}},
+		" select", []item{{itemSelect, "select", 1}},
 	},
 	{
-		"  select", []item{{itemSelect, "select"}},
+		"  select", []item{{itemSelect, "select", 2}},
 	},
 	{
-		"select  ", []item{{itemSelect, "select"}},
+		"select  ", []item{{itemSelect, "select", 0}},
 	},
 	{
-		"notselect", []item{{itemError, "syntax error: start with 'n'"}},
+		"notselect", []item{{itemError, "syntax error: start with 'n'", 0}},
 	},
 }
 
@@ -38,7 +38,7 @@ func Test_LexStart(t *testing.T) {
 			lexStart(l)
 		}()
 		it := <-l.items
-		if it.typ != i.items[0].typ || it.val != i.items[0].val {
+		if it != i.items[0] {
 			t.Error("not right:", it.typ, it.val)
 		} else {
 			fmt.Println(it.typ, it.val)
@@ -48,16 +48,16 @@ func Test_LexStart(t *testing.T) {
 
 var lexFieldTest = []lexTest{
 	{
-		"name, ", []item{{itemIdentifier, "name"}, {itemError, ""}},
+		"name, ", []item{{typ: itemIdentifier, val: "name"}, {typ: itemError}},
 	},
 	{
-		"name, age", []item{{itemIdentifier, "name"}, {itemError, ""}},
+		"name, age", []item{{typ: itemIdentifier, val: "name"}, {typ: itemError}},
 	},
 	{
-		"  name, count()", []item{{itemIdentifier, "name"}, {itemError, ""}},
+		"  name, count()", []item{{typ: itemIdentifier, val: "name"}, {typ: itemError}},
 	},
 	{
-		"  name  , sum(age)  ", []item{{itemIdentifier, "name"}, {itemError, ""}},
+		"  name  , sum(age)  ", []item{{typ: itemIdentifier, val: "name"}, {typ: itemError}},
 	},
 }
 
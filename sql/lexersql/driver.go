@@ -0,0 +1,280 @@
+// Package lexersql registers a database/sql driver that runs queries through
+// the lexer/sql parser and engine.
+//
+//	lexersql.RegisterTable("graph", sql.NewMemTable(columns, rows...))
+//	db, err := database.Open("lexersql", "")
+//	rows, err := db.Query(`select name from graph where age > ?`, 18)
+//	rows, err = db.Query(`select name from graph where age > :age`, database.Named("age", 18))
+//	res, err := db.Exec(`update graph set age = age + 1 where name = ?`, "alice")
+//
+// The data source name selects the engine: the empty name is the default
+// engine holding the tables given to RegisterTable, a name passed to
+// RegisterEngine selects that engine, and any other name is taken as a
+// directory whose csv files become tables named after the files.
+package lexersql
+
+import (
+	"context"
+	database "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"lexer/sql"
+)
+
+// DriverName is the name the driver is registered under.
+const DriverName = "lexersql"
+
+var (
+	mu            sync.RWMutex
+	defaultEngine = sql.NewEngine()
+	engines       = map[string]*sql.Engine{"": defaultEngine}
+
+	errMixed = errors.New("lexersql: mixed named and positional arguments")
+)
+
+func init() {
+	database.Register(DriverName, &Driver{})
+}
+
+// RegisterTable makes t available as name to connections of the default
+// engine.
+func RegisterTable(name string, t sql.Table) {
+	defaultEngine.Register(name, t)
+}
+
+// RegisterEngine makes e available to connections opened with the data
+// source name dsn.
+func RegisterEngine(dsn string, e *sql.Engine) {
+	mu.Lock()
+	engines[dsn] = e
+	mu.Unlock()
+}
+
+// Driver implements driver.Driver.
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	e, err := engine(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{engine: e}, nil
+}
+
+// engine returns the engine registered as dsn, or loads the csv files of
+// the directory dsn into a new one.
+func engine(dsn string) (*sql.Engine, error) {
+	mu.RLock()
+	e, ok := engines[dsn]
+	mu.RUnlock()
+	if ok {
+		return e, nil
+	}
+	files, err := ioutil.ReadDir(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("lexersql: %v", err)
+	}
+	e = sql.NewEngine()
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".csv" {
+			continue
+		}
+		t, err := sql.NewFileTable(filepath.Join(dsn, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("lexersql: %v", err)
+		}
+		e.Register(strings.TrimSuffix(f.Name(), ".csv"), t)
+	}
+	return e, nil
+}
+
+type conn struct {
+	engine *sql.Engine
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.engine.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: s}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	s, err := c.engine.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return (&stmt{stmt: s}).QueryContext(ctx, args)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s, err := c.engine.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return (&stmt{stmt: s}).ExecContext(ctx, args)
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("lexersql: transactions are not supported")
+}
+
+type stmt struct {
+	stmt *sql.Stmt
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 for statements with named placeholders, leaving the
+// check of their arguments to the engine.
+func (s *stmt) NumInput() int {
+	if s.stmt.Named() {
+		return -1
+	}
+	return s.stmt.NumInput()
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	positional, named, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var r sql.Result
+	if named != nil {
+		r, err = s.stmt.ExecNamed(ctx, named)
+	} else {
+		r, err = s.stmt.Exec(ctx, positional...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(r.RowsAffected), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	positional, named, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var r *sql.Rows
+	if named != nil {
+		r, err = s.stmt.QueryNamed(ctx, named)
+	} else {
+		r, err = s.stmt.Query(ctx, positional...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// splitArgs returns the values of args, which are either all positional or
+// all named.
+func splitArgs(args []driver.NamedValue) ([]interface{}, map[string]interface{}, error) {
+	if len(args) > 0 && args[0].Name != "" {
+		named := make(map[string]interface{}, len(args))
+		for _, a := range args {
+			if a.Name == "" {
+				return nil, nil, errMixed
+			}
+			named[a.Name] = a.Value
+		}
+		return nil, named, nil
+	}
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, nil, errMixed
+		}
+		values[i] = a.Value
+	}
+	return values, nil, nil
+}
+
+type rows struct {
+	rows *sql.Rows
+}
+
+func (r *rows) Columns() []string {
+	return r.rows.Columns()
+}
+
+// ColumnTypeDatabaseTypeName returns the static type of the column index in
+// upper case, or "" when it depends on the data.
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if t := r.rows.ColumnTypes()[index]; t != sql.TypeAny {
+		return strings.ToUpper(t.String())
+	}
+	return ""
+}
+
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	values := make([]interface{}, len(dest))
+	ptrs := make([]interface{}, len(dest))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return err
+	}
+	for i, v := range values {
+		dest[i] = driverValue(v)
+	}
+	return nil
+}
+
+// driverValue converts the values a Table may hold to the types of
+// driver.Value.
+func driverValue(v interface{}) driver.Value {
+	if driver.IsValue(v) {
+		return v
+	}
+	if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		return dv
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,106 @@
+package lexersql
+
+import (
+	"context"
+	database "database/sql"
+	"reflect"
+	"testing"
+
+	"lexer/sql"
+)
+
+func init() {
+	RegisterTable("graph", sql.NewMemTable(
+		[]string{"name", "age"},
+		[]interface{}{"alice", 30},
+		[]interface{}{"bob", 25},
+		[]interface{}{"carol", 35},
+	))
+}
+
+func queryNames(t *testing.T, db *database.DB, query string, args ...interface{}) []string {
+	rows, err := db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func Test_Driver(t *testing.T) {
+	db, err := database.Open(DriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	got := queryNames(t, db, `select name from graph where age > ? order by age desc`, 26)
+	if want := []string{"carol", "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	stmt, err := db.Prepare(`select name, age where name = ?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var name string
+	var age int
+	if err := stmt.QueryRow("bob").Scan(&name, &age); err != nil || age != 25 {
+		t.Errorf("got %d, %v, want 25", age, err)
+	}
+	if _, err := stmt.Query(); err == nil {
+		t.Error("expected an error for a missing argument")
+	}
+	if _, err := db.Exec(`select name`); err == nil {
+		t.Error("expected an error from exec")
+	}
+}
+
+func Test_DriverExec(t *testing.T) {
+	e := sql.NewEngine()
+	e.Register("items", sql.NewMemTable([]string{"id", "name"}))
+	RegisterEngine("exec", e)
+	db, err := database.Open(DriverName, "exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.Exec(`insert into items values (1, ?), (2, ?)`, "cup", "mug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); n != 2 || err != nil {
+		t.Errorf("got %d rows affected, %v", n, err)
+	}
+	if _, err := db.Exec(`update items set name = :name where id = 2`, database.Named("name", "pen")); err != nil {
+		t.Fatal(err)
+	}
+	got := queryNames(t, db, `select name from items order by id`)
+	if want := []string{"cup", "pen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_DriverFiles(t *testing.T) {
+	db, err := database.Open(DriverName, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	got := queryNames(t, db, `select name from people where region = ? and age >= 30`, "cn-beijing")
+	if want := []string{"alice", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
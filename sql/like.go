@@ -0,0 +1,245 @@
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Like is a compiled like pattern: "%" matches any run of characters and
+// "_" a single one. The escape character, when there is one, makes the
+// character following it literal, so that "50!%" with the escape "!"
+// matches "50%" only.
+type Like struct {
+	Pattern string
+	Escape  string // a single character, or empty for none
+	Fold    bool   // ignore case, as ilike does
+	parts   []likePart
+	re      *regexp.Regexp // nil when the pattern is literal text or a prefix
+}
+
+// likePart is a run of literal text, or a wildcard when wild is set.
+type likePart struct {
+	wild rune // '%' or '_'
+	text string
+}
+
+type likeKey struct {
+	pattern, escape string
+	fold            bool
+}
+
+// maxLikeCache bounds the compiled patterns kept; the cache starts over
+// when it is full.
+const maxLikeCache = 256
+
+var likeCache = struct {
+	sync.Mutex
+	m map[likeKey]*Like
+}{m: make(map[likeKey]*Like)}
+
+// CompileLike compiles pattern with the escape character escape, which
+// may be empty. Compiled patterns are cached, so compiling a pattern again
+// is cheap.
+func CompileLike(pattern, escape string, fold bool) (*Like, error) {
+	key := likeKey{pattern, escape, fold}
+	likeCache.Lock()
+	l, ok := likeCache.m[key]
+	likeCache.Unlock()
+	if ok {
+		return l, nil
+	}
+	l, err := compileLike(pattern, escape, fold)
+	if err != nil {
+		return nil, err
+	}
+	likeCache.Lock()
+	if len(likeCache.m) >= maxLikeCache {
+		likeCache.m = make(map[likeKey]*Like)
+	}
+	likeCache.m[key] = l
+	likeCache.Unlock()
+	return l, nil
+}
+
+func compileLike(pattern, escape string, fold bool) (*Like, error) {
+	if escape != "" && utf8.RuneCountInString(escape) != 1 {
+		return nil, fmt.Errorf("like escape %q must be one character", escape)
+	}
+	esc, _ := utf8.DecodeRuneInString(escape)
+	l := &Like{Pattern: pattern, Escape: escape, Fold: fold}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			l.parts = append(l.parts, likePart{text: text.String()})
+			text.Reset()
+		}
+	}
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			text.WriteRune(r)
+			escaped = false
+		case escape != "" && r == esc:
+			escaped = true
+		case r == '%' || r == '_':
+			flush()
+			// a run of % matches as one
+			if n := len(l.parts); r == '%' && n > 0 && l.parts[n-1].wild == '%' {
+				continue
+			}
+			l.parts = append(l.parts, likePart{wild: r})
+		default:
+			text.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("like pattern %q ends with the escape %s", pattern, escape)
+	}
+	flush()
+	if _, ok := l.exact(); ok && !fold {
+		return l, nil
+	}
+	if l.prefixOnly() && !fold {
+		return l, nil
+	}
+	re, err := regexp.Compile(l.Regexp())
+	if err != nil {
+		return nil, err
+	}
+	l.re = re
+	return l, nil
+}
+
+// Match reports whether s matches the pattern.
+func (l *Like) Match(s string) bool {
+	if l.re != nil {
+		return l.re.MatchString(s)
+	}
+	if text, ok := l.exact(); ok {
+		return s == text
+	}
+	return strings.HasPrefix(s, l.Prefix())
+}
+
+// exact returns the text the pattern matches when it has no wildcards.
+func (l *Like) exact() (string, bool) {
+	switch len(l.parts) {
+	case 0:
+		return "", true
+	case 1:
+		return l.parts[0].text, l.parts[0].wild == 0
+	}
+	return "", false
+}
+
+// prefixOnly reports whether the pattern is literal text followed by "%".
+func (l *Like) prefixOnly() bool {
+	n := len(l.parts)
+	return n > 0 && l.parts[n-1].wild == '%' && (n == 1 || n == 2 && l.parts[0].wild == 0)
+}
+
+// Prefix returns the literal text the matches start with.
+func (l *Like) Prefix() string {
+	if len(l.parts) > 0 && l.parts[0].wild == 0 {
+		return l.parts[0].text
+	}
+	return ""
+}
+
+// Range returns the range of strings from, inclusive, to to, exclusive,
+// that the matches lie in, for backends scanning an index instead. to is
+// empty when the range has no upper bound. ok is false when the pattern
+// ignores case or starts with a wildcard, so that it has no such range.
+func (l *Like) Range() (from, to string, ok bool) {
+	from = l.Prefix()
+	if l.Fold || from == "" {
+		return "", "", false
+	}
+	b := []byte(from)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return from, string(b[:i+1]), true
+		}
+	}
+	return from, "", true
+}
+
+// Regexp returns the pattern as an anchored regular expression of the
+// syntax of Go, whose flags let "." match newlines and, for a pattern
+// folding case, ignore case.
+func (l *Like) Regexp() string {
+	if l.Fold {
+		return "(?is)" + l.regexpBody()
+	}
+	return "(?s)" + l.regexpBody()
+}
+
+// regexpBody returns the regular expression without flags, for the
+// backends that take them apart.
+func (l *Like) regexpBody() string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, p := range l.parts {
+		switch p.wild {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(p.text))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// render writes the pattern with wild for each wildcard and literal text
+// passed through quote.
+func (l *Like) render(wild func(rune) string, quote func(string) string) string {
+	var b strings.Builder
+	for _, p := range l.parts {
+		if p.wild != 0 {
+			b.WriteString(wild(p.wild))
+		} else {
+			b.WriteString(quote(p.text))
+		}
+	}
+	return b.String()
+}
+
+// escaped returns the pattern with the escape character esc, which
+// precedes every literal "%", "_" and esc.
+func (l *Like) escaped(esc string) string {
+	r := strings.NewReplacer(esc, esc+esc, "%", esc+"%", "_", esc+"_")
+	return l.render(func(w rune) string { return string(w) }, r.Replace)
+}
+
+// glob returns the pattern as a glob, where "*" matches any run of
+// characters, "?" a single one and brackets hold a literal.
+func (l *Like) glob() string {
+	r := strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+	return l.render(func(w rune) string {
+		if w == '%' {
+			return "*"
+		}
+		return "?"
+	}, r.Replace)
+}
+
+// wildcard returns the pattern as a wildcard query of Elasticsearch, where
+// "*" matches any run of characters, "?" a single one and a backslash
+// escapes.
+func (l *Like) wildcard() string {
+	r := strings.NewReplacer("*", `\*`, "?", `\?`, `\`, `\\`)
+	return l.render(func(w rune) string {
+		if w == '%' {
+			return "*"
+		}
+		return "?"
+	}, r.Replace)
+}
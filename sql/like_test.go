@@ -0,0 +1,57 @@
+package sql
+
+import "testing"
+
+func Test_Like(t *testing.T) {
+	for _, test := range []struct {
+		pattern, escape string
+		fold            bool
+		match, miss     []string
+		regexp          string
+		from, to        string
+		gremlin         string
+	}{
+		{"abc", "", false, []string{"abc"}, []string{"abcd", "ABC"}, `(?s)^abc$`, "abc", "abd", `eq('abc')`},
+		{"ab%", "", false, []string{"ab", "ab\nc"}, []string{"a", "Ab"}, `(?s)^ab.*$`, "ab", "ac", `startingWith('ab')`},
+		{"a_c%%d", "", false, []string{"abcd", "a.cxxd"}, []string{"acd"}, `(?s)^a.c.*d$`, "a", "b", `regex('(?s)^a.c.*d$')`},
+		{"%.x", "", false, []string{".x", "y.x"}, []string{"yyx"}, `(?s)^.*\.x$`, "", "", `endingWith('.x')`},
+		{"50!%!_!!%", "!", false, []string{"50%_!", "50%_!z"}, []string{"50x_!", "50%a!"}, `(?s)^50%_!.*$`, "50%_!", "50%_\"", `startingWith('50%_!')`},
+		{`a\%`, `\`, true, []string{"A%", "a%"}, []string{"ab"}, `(?is)^a%$`, "", "", `regex('(?is)^a%$')`},
+		{"%b%", "", true, []string{"aBc"}, []string{"ac"}, `(?is)^.*b.*$`, "", "", `regex('(?is)^.*b.*$')`},
+	} {
+		like, err := CompileLike(test.pattern, test.escape, test.fold)
+		if err != nil {
+			t.Errorf("%s: %v", test.pattern, err)
+			continue
+		}
+		for _, s := range test.match {
+			if !like.Match(s) {
+				t.Errorf("%s: %q does not match", test.pattern, s)
+			}
+		}
+		for _, s := range test.miss {
+			if like.Match(s) {
+				t.Errorf("%s: %q matches", test.pattern, s)
+			}
+		}
+		if got := like.Regexp(); got != test.regexp {
+			t.Errorf("%s: got regexp %s, want %s", test.pattern, got, test.regexp)
+		}
+		from, to, ok := like.Range()
+		if ok != (test.from != "") || from != test.from || to != test.to {
+			t.Errorf("%s: got range %q, %q, %v, want %q, %q", test.pattern, from, to, ok, test.from, test.to)
+		}
+		if got := gremlinLike(like); got != test.gremlin {
+			t.Errorf("%s: got gremlin %s, want %s", test.pattern, got, test.gremlin)
+		}
+		if again, _ := CompileLike(test.pattern, test.escape, test.fold); again != like {
+			t.Errorf("%s: compiled again", test.pattern)
+		}
+	}
+
+	for _, test := range []struct{ pattern, escape string }{{"ab!", "!"}, {"a", "!!"}} {
+		if _, err := CompileLike(test.pattern, test.escape, false); err == nil {
+			t.Errorf("%s escape %s: expected error", test.pattern, test.escape)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+)
+
+// Assignment gives a column a value, in the set clause of an update or a row
+// of an insert.
+type Assignment struct {
+	Column string
+	Value  interface{} // a value or a placeholder
+	Expr   Expr        // the value when it is more than a literal, such as "a + 1"; Value is then nil
+}
+
+// String returns the text of the value.
+func (a Assignment) String() string {
+	if a.Expr != nil {
+		return a.Expr.String()
+	}
+	return formatValue(a.Value)
+}
+
+// Result is the outcome of an insert, update, delete or create table.
+type Result struct {
+	RowsAffected int64
+}
+
+// modify runs the insert, update or delete m against its table, which
+// must be a WritableTable, or creates the table of a create table.
+func (e *Engine) modify(ctx context.Context, m *model) (Result, error) {
+	if m.Type == SqlSelect {
+		return Result{}, fmt.Errorf("%s returns rows, use Query", m.Type)
+	}
+	if m.Type == SqlCreate {
+		return Result{}, e.create(m)
+	}
+	t, ok := e.Table(m.TableName)
+	if !ok {
+		return Result{}, fmt.Errorf("table %q not found", m.TableName)
+	}
+	w, ok := t.(WritableTable)
+	if !ok {
+		return Result{}, fmt.Errorf("table %q is read-only", m.TableName)
+	}
+	types, err := e.catalog.types(m.TableName, w)
+	if err != nil {
+		return Result{}, err
+	}
+	s := &scope{ctx: ctx, engine: e, tables: []string{m.TableName}, columns: w.Columns()}
+	var n int64
+	switch m.Type {
+	case SqlInsert:
+		n, err = insertRows(m, w, types, s)
+	case SqlUpdate:
+		n, err = updateRows(ctx, m, w, types, s)
+	case SqlDelete:
+		n, err = deleteRows(ctx, m, w, types, s)
+	}
+	return Result{RowsAffected: n}, err
+}
+
+// create registers an empty MemTable for the table m creates and defines
+// its schema.
+func (e *Engine) create(m *model) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.tables[m.TableName]; ok {
+		return fmt.Errorf("table %q already exists", m.TableName)
+	}
+	if err := e.catalog.Define(m.TableName, m.Schema...); err != nil {
+		return err
+	}
+	columns := make([]string, len(m.Schema))
+	for i, c := range m.Schema {
+		columns[i] = c.Name
+	}
+	e.tables[m.TableName] = NewMemTable(columns)
+	return nil
+}
+
+// insertRows computes every row before inserting the first, whose values may
+// not refer to fields. The values are converted to types, unless nil.
+func insertRows(m *model, w WritableTable, types []Type, s *scope) (int64, error) {
+	rows := make([][]interface{}, len(m.Values))
+	for i, values := range m.Values {
+		if values[0].Column == "" && len(values) != len(s.columns) {
+			return 0, fmt.Errorf("insert %d values into %d columns", len(values), len(s.columns))
+		}
+		rows[i] = make([]interface{}, len(s.columns))
+		for j, a := range values {
+			idx := j
+			if a.Column != "" {
+				var err error
+				if idx, err = columnIndex(s.columns, s.tables, a.Column); err != nil {
+					return 0, err
+				}
+			}
+			eval, err := compileAssignment(a, nil, s)
+			if err != nil {
+				return 0, err
+			}
+			if rows[i][idx], err = eval(nil); err != nil {
+				return 0, err
+			}
+		}
+		if err := convertRow(types, s.columns, rows[i]); err != nil {
+			return 0, err
+		}
+	}
+	for i, row := range rows {
+		if err := w.Insert(row...); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(rows)), nil
+}
+
+// updateRows computes the assigned values from the rows as they were,
+// converted to types unless nil.
+func updateRows(ctx context.Context, m *model, w WritableTable, types []Type, s *scope) (int64, error) {
+	match, err := compileCondition(m.Conditions, s)
+	if err != nil {
+		return 0, err
+	}
+	index := make([]int, len(m.Set))
+	evals := make([]evaluator, len(m.Set))
+	for i, a := range m.Set {
+		if index[i], err = columnIndex(s.columns, s.tables, a.Column); err != nil {
+			return 0, err
+		}
+		if evals[i], err = compileAssignment(a, s.columns, s); err != nil {
+			return 0, err
+		}
+	}
+	return w.Update(ctx, func(row []interface{}) ([]interface{}, error) {
+		row, err := typedRow(types, s.columns, row)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			if ok, err := match(row); err != nil || !ok {
+				return nil, err
+			}
+		}
+		updated := append([]interface{}(nil), row...)
+		for i, eval := range evals {
+			v, err := eval(row)
+			if err != nil {
+				return nil, err
+			}
+			updated[index[i]] = v
+		}
+		return updated, convertRow(types, s.columns, updated)
+	})
+}
+
+func deleteRows(ctx context.Context, m *model, w WritableTable, types []Type, s *scope) (int64, error) {
+	match, err := compileCondition(m.Conditions, s)
+	if err != nil {
+		return 0, err
+	}
+	return w.Delete(ctx, func(row []interface{}) (bool, error) {
+		if match == nil {
+			return true, nil
+		}
+		row, err := typedRow(types, s.columns, row)
+		if err != nil {
+			return false, err
+		}
+		return match(row)
+	})
+}
+
+// typedRow returns a copy of row with the values converted to types, or row
+// itself when there are no types.
+func typedRow(types []Type, columns []string, row []interface{}) ([]interface{}, error) {
+	if types == nil {
+		return row, nil
+	}
+	row = append([]interface{}(nil), row...)
+	return row, convertRow(types, columns, row)
+}
+
+// compileAssignment compiles the value a assigns, computed over rows with
+// columns.
+func compileAssignment(a Assignment, columns []string, s *scope) (evaluator, error) {
+	if a.Expr != nil {
+		return compileExpr(a.Expr, columns, s)
+	}
+	v := a.Value
+	return func([]interface{}) (interface{}, error) {
+		return v, nil
+	}, nil
+}
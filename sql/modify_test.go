@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type execTest struct {
+	query    string
+	args     []interface{}
+	affected int64
+	rows     [][]interface{} // the items table afterwards
+}
+
+var execTests = []execTest{
+	{
+		`insert into items (id, name) values (3, "pen"), (4, ?)`, []interface{}{"ink"}, 2,
+		[][]interface{}{{int64(1), "cup", int64(10)}, {int64(2), "mug", int64(20)}, {int64(3), "pen", nil}, {int64(4), "ink", nil}},
+	},
+	{
+		`insert into items values (5, upper("bag"), 2 * 4)`, nil, 1,
+		[][]interface{}{{int64(1), "cup", int64(10)}, {int64(2), "mug", int64(20)}, {int64(5), "BAG", int64(8)}},
+	},
+	{
+		`update items set price = price + 1, name = name || "!" where id = ?`, []interface{}{2}, 1,
+		[][]interface{}{{int64(1), "cup", int64(10)}, {int64(2), "mug!", int64(21)}},
+	},
+	{
+		`update items set price = ? * 2 where id = ?`, []interface{}{7, 1}, 1,
+		[][]interface{}{{int64(1), "cup", int64(14)}, {int64(2), "mug", int64(20)}},
+	},
+	{
+		`update items set price = :price`, []interface{}{nil}, 2,
+		[][]interface{}{{int64(1), "cup", nil}, {int64(2), "mug", nil}},
+	},
+	{
+		`delete from items where price > (select min(price) from items)`, nil, 1,
+		[][]interface{}{{int64(1), "cup", int64(10)}},
+	},
+	{
+		`delete from items`, nil, 2,
+		nil,
+	},
+}
+
+func newItems() *MemTable {
+	return NewMemTable([]string{"id", "name", "price"},
+		[]interface{}{int64(1), "cup", int64(10)},
+		[]interface{}{int64(2), "mug", int64(20)},
+	)
+}
+
+func Test_Exec(t *testing.T) {
+	for _, test := range execTests {
+		e := NewEngine()
+		items := newItems()
+		e.Register("items", items)
+		stmt, err := e.Prepare(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var res Result
+		if stmt.Named() {
+			res, err = stmt.ExecNamed(context.Background(), map[string]interface{}{"price": test.args[0]})
+		} else {
+			res, err = stmt.Exec(context.Background(), test.args...)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if res.RowsAffected != test.affected {
+			t.Errorf("%s: %d rows affected, want %d", test.query, res.RowsAffected, test.affected)
+		}
+		rows, _ := readAll(context.Background(), &cursorIter{cursor: &memCursor{rows: items.rows}})
+		if !reflect.DeepEqual(rows, test.rows) {
+			t.Errorf("%s: got %v, want %v", test.query, rows, test.rows)
+		}
+	}
+}
+
+func Test_ExecErrors(t *testing.T) {
+	e := NewEngine()
+	items := newItems()
+	e.Register("items", items)
+	e.Register("readonly", struct{ Table }{newItems()})
+	for _, query := range []string{
+		`insert into items values (1, "cup")`,
+		`insert into items (id, nosuch) values (1, 2)`,
+		`insert into items (id) values (price)`,
+		`update items set nosuch = 1`,
+		`update items set price = price / 0`,
+		`delete from readonly`,
+		`delete from nosuch`,
+		`select name from items`,
+	} {
+		if _, err := e.Exec(context.Background(), query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	if !reflect.DeepEqual(items.rows, newItems().rows) {
+		t.Errorf("failed statements changed the table: %v", items.rows)
+	}
+	if _, err := e.Query(context.Background(), `delete from items`); err == nil {
+		t.Error("expected error querying a delete")
+	}
+	for _, query := range []string{
+		`insert into items (id, id) values (1, 2)`,
+		`insert into items (id, name) values (1)`,
+		`insert into items values (1, 2), (3)`,
+		`insert items values (1)`,
+		`update items set price = 1, price = 2`,
+		`update items set price = 1 order by id`,
+		`delete from items where id = 1 limit 1`,
+		`delete items`,
+		`select id where id in (delete from items)`,
+		`select id union delete from items`,
+	} {
+		if _, err := e.Prepare(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MongoDoc is a document whose keys keep their order, as MongoDB needs for
+// sort specifications and commands. It converts directly to a bson.D.
+type MongoDoc []MongoElem
+
+// MongoElem is an element of a MongoDoc.
+type MongoElem struct {
+	Key   string
+	Value interface{}
+}
+
+func (d MongoDoc) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// ToMongo parses query and returns the JSON of the equivalent MongoDB
+// command.
+func ToMongo(query string) ([]byte, error) {
+	p := NewParse(query)
+	p.Generate()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	cmd, err := p.MongoCommand()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cmd)
+}
+
+// MongoCommand translates the model to a find command on the collection
+// named by the table, or to an aggregate command running MongoPipeline when
+// the query aggragates or selects distinct fields, which are grouped on.
+// Placeholders must be bound first.
+func (m *model) MongoCommand() (MongoDoc, error) {
+	m, err := m.distinctGroups()
+	if err != nil {
+		return nil, err
+	}
+	if m.aggragates() {
+		pipeline, err := m.MongoPipeline()
+		if err != nil {
+			return nil, err
+		}
+		return MongoDoc{
+			{"aggregate", m.TableName},
+			{"pipeline", pipeline},
+			{"cursor", map[string]interface{}{}},
+		}, nil
+	}
+	filter, err := m.MongoFilter()
+	if err != nil {
+		return nil, err
+	}
+	cmd := MongoDoc{{"find", m.TableName}, {"filter", filter}}
+	if len(m.Fields) > 0 {
+		cmd = append(cmd, MongoElem{"projection", mongoProjection(m.Fields)})
+	}
+	if len(m.OrderBy) > 0 {
+		cmd = append(cmd, MongoElem{"sort", m.mongoSort()})
+	}
+	if m.Offset > 0 {
+		cmd = append(cmd, MongoElem{"skip", m.Offset})
+	}
+	if m.Limit >= 0 {
+		cmd = append(cmd, MongoElem{"limit", m.Limit})
+	}
+	return cmd, nil
+}
+
+// MongoFilter translates the conditions of the model to a query filter.
+// Like patterns become anchored regular expressions and "not" becomes $nor.
+func (m *model) MongoFilter() (map[string]interface{}, error) {
+	if err := m.checkCounts(); err != nil {
+		return nil, err
+	}
+	if err := m.plainFields(); err != nil {
+		return nil, err
+	}
+	if m.Conditions == nil {
+		return map[string]interface{}{}, nil
+	}
+	return mongoCondition(m.Conditions)
+}
+
+// MongoPipeline translates the model to an aggregation pipeline: $match for
+// the conditions, then $group and a $project naming the results after the
+// group by fields and aggragations, then $sort, $skip and $limit. Without
+// aggragations the $project of the fields comes last. Since result field
+// names cannot hold dots, those of group keys and aggragations are replaced
+// by underscores, e.g. "count(n_age)".
+func (m *model) MongoPipeline() ([]interface{}, error) {
+	m, err := m.distinctGroups()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.MongoFilter()
+	if err != nil {
+		return nil, err
+	}
+	var stages []interface{}
+	if len(filter) > 0 {
+		stages = append(stages, map[string]interface{}{"$match": filter})
+	}
+	var project map[string]interface{}
+	if m.aggragates() {
+		var id interface{}
+		if len(m.GroupBy) > 0 {
+			keys := make(map[string]interface{}, len(m.GroupBy))
+			for _, f := range m.GroupBy {
+				keys[mongoName(f)] = "$" + f
+			}
+			id = keys
+		}
+		group := map[string]interface{}{"_id": id}
+		project = map[string]interface{}{"_id": 0}
+		for _, f := range m.GroupBy {
+			project[f] = "$_id." + mongoName(f)
+		}
+		for _, agg := range m.Aggragations.Items {
+			name := mongoName(agg.String())
+			acc, err := mongoAccumulator(agg)
+			if err != nil {
+				return nil, err
+			}
+			group[name] = acc
+			switch {
+			case agg.distinctCount():
+				project[name] = map[string]interface{}{"$size": "$" + name}
+			case agg.Agg == AggPercentile || agg.Agg == AggApproxPercentile:
+				project[name] = map[string]interface{}{"$arrayElemAt": []interface{}{"$" + name, 0}}
+			case agg.Agg == AggVariance:
+				project[name] = map[string]interface{}{"$pow": []interface{}{"$" + name, 2}}
+			default:
+				project[name] = 1
+			}
+		}
+		stages = append(stages, map[string]interface{}{"$group": group}, map[string]interface{}{"$project": project})
+		project = nil
+	} else if len(m.Fields) > 0 {
+		project = mongoProjection(m.Fields)
+	}
+	if len(m.OrderBy) > 0 {
+		stages = append(stages, map[string]interface{}{"$sort": m.mongoSort()})
+	}
+	if m.Offset > 0 {
+		stages = append(stages, map[string]interface{}{"$skip": m.Offset})
+	}
+	if m.Limit >= 0 {
+		stages = append(stages, map[string]interface{}{"$limit": m.Limit})
+	}
+	if project != nil {
+		stages = append(stages, map[string]interface{}{"$project": project})
+	}
+	return stages, nil
+}
+
+func (m *model) aggragates() bool {
+	return len(m.Aggragations.Items) > 0 || len(m.GroupBy) > 0
+}
+
+func (m *model) mongoSort() MongoDoc {
+	sort := make(MongoDoc, len(m.OrderBy))
+	for i, o := range m.OrderBy {
+		key := o.Column
+		if m.aggragates() && !contains(m.GroupBy, key) {
+			key = mongoName(key)
+		}
+		if o.Desc {
+			sort[i] = MongoElem{key, -1}
+		} else {
+			sort[i] = MongoElem{key, 1}
+		}
+	}
+	return sort
+}
+
+func mongoProjection(fields []string) map[string]interface{} {
+	project := map[string]interface{}{"_id": 0}
+	for _, f := range fields {
+		project[f] = 1
+	}
+	return project
+}
+
+func mongoName(name string) string {
+	return strings.Replace(name, MarkDot, "_", -1)
+}
+
+// mongoAccumulator returns the $group accumulator of agg. count counts the
+// documents where the field is not null; a distinct count collects a set
+// whose size is taken by the following $project, which also takes the
+// single percentile of $percentile and squares the deviation of a variance.
+// Percentiles are the approximate ones of MongoDB.
+func mongoAccumulator(agg aggItem) (map[string]interface{}, error) {
+	if err := checkBound(agg.Params); err != nil {
+		return nil, err
+	}
+	field := "$" + agg.Field
+	switch {
+	case agg.distinctCount():
+		return map[string]interface{}{"$addToSet": field}, nil
+	case agg.Distinct && agg.Agg == AggArray:
+		return map[string]interface{}{"$addToSet": field}, nil
+	case agg.Distinct:
+		return nil, fmt.Errorf("aggragation %s not supported", agg)
+	}
+	switch agg.Agg {
+	case AggCount:
+		return map[string]interface{}{"$sum": map[string]interface{}{
+			"$cond": []interface{}{map[string]interface{}{"$gt": []interface{}{field, nil}}, 1, 0},
+		}}, nil
+	case AggSum:
+		return map[string]interface{}{"$sum": field}, nil
+	case AggAverage:
+		return map[string]interface{}{"$avg": field}, nil
+	case AggMin:
+		return map[string]interface{}{"$min": field}, nil
+	case AggMax:
+		return map[string]interface{}{"$max": field}, nil
+	case AggMedian:
+		return map[string]interface{}{"$median": map[string]interface{}{"input": field, "method": "approximate"}}, nil
+	case AggPercentile, AggApproxPercentile:
+		return map[string]interface{}{"$percentile": map[string]interface{}{
+			"input": field, "p": []interface{}{agg.Params[0]}, "method": "approximate",
+		}}, nil
+	case AggStddev, AggVariance:
+		return map[string]interface{}{"$stdDevSamp": field}, nil
+	case AggArray:
+		return map[string]interface{}{"$push": field}, nil
+	}
+	return nil, fmt.Errorf("aggragation %s not supported", agg)
+}
+
+var mongoComparator = map[ComparatorType]string{
+	ComparatorEQ:  "$eq",
+	ComparatorNEQ: "$ne",
+	ComparatorGT:  "$gt",
+	ComparatorGTE: "$gte",
+	ComparatorLT:  "$lt",
+	ComparatorLTE: "$lte",
+	ComparatorIN:  "$in",
+}
+
+var mongoLogic = map[LogicType]string{
+	LogicAnd: "$and",
+	LogicOr:  "$or",
+	LogicNot: "$nor",
+}
+
+func mongoCondition(c Condition) (map[string]interface{}, error) {
+	switch c := c.(type) {
+	case *SingleCondition:
+		if err := checkBound(c.Value); err != nil {
+			return nil, err
+		}
+		if c.isLike() {
+			like, err := c.like()
+			if err != nil {
+				return nil, err
+			}
+			options := "s"
+			if like.Fold {
+				options = "si"
+			}
+			return map[string]interface{}{c.Field: map[string]interface{}{
+				"$regex":   like.regexpBody(),
+				"$options": options,
+			}}, nil
+		}
+		if c.Comparator == ComparatorREGEXP {
+			return map[string]interface{}{c.Field: map[string]interface{}{"$regex": c.Value}}, nil
+		}
+		if c.Comparator == ComparatorNREGEXP {
+			return map[string]interface{}{c.Field: map[string]interface{}{
+				"$not": map[string]interface{}{"$regex": c.Value},
+			}}, nil
+		}
+		op, ok := mongoComparator[c.Comparator]
+		if !ok {
+			return nil, fmt.Errorf("comparator %s not supported", c.Comparator)
+		}
+		return map[string]interface{}{c.Field: map[string]interface{}{op: c.Value}}, nil
+	case *MultiCondition:
+		op, ok := mongoLogic[c.Logic]
+		if !ok {
+			return nil, fmt.Errorf("unknown logic %d", c.Logic)
+		}
+		subs := make([]interface{}, len(c.SubConditions))
+		for i, sub := range c.SubConditions {
+			doc, err := mongoCondition(sub)
+			if err != nil {
+				return nil, err
+			}
+			subs[i] = doc
+		}
+		return map[string]interface{}{op: subs}, nil
+	}
+	return nil, fmt.Errorf("unknown condition %T", c)
+}
@@ -0,0 +1,131 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+type mongoTest struct {
+	query string
+	json  string
+}
+
+var mongoTests = []mongoTest{
+	{
+		`select name, age from users where age > 18 and (region = "cn" or not name like "a_%") order by age desc, name limit 10`,
+		`{"find": "users",
+		  "filter": {"$and": [
+			{"age": {"$gt": 18}},
+			{"$or": [{"region": {"$eq": "cn"}}, {"$nor": [{"name": {"$options": "s", "$regex": "^a..*$"}}]}]}
+		  ]},
+		  "projection": {"_id": 0, "age": 1, "name": 1},
+		  "sort": {"age": -1, "name": 1},
+		  "limit": 10}`,
+	},
+	{
+		`select name from users where id in (1, 2) and tag != "x"`,
+		`{"find": "users",
+		  "filter": {"$and": [{"id": {"$in": [1, 2]}}, {"tag": {"$ne": "x"}}]},
+		  "projection": {"_id": 0, "name": 1}}`,
+	},
+	{
+		`select n.region, count(id), distinct(n.user) from events where status >= 500 group by n.region order by count(id) desc, n.region limit 3`,
+		`{"aggregate": "events",
+		  "pipeline": [
+			{"$match": {"status": {"$gte": 500}}},
+			{"$group": {
+				"_id": {"n_region": "$n.region"},
+				"count(id)": {"$sum": {"$cond": [{"$gt": ["$id", null]}, 1, 0]}},
+				"distinct(n_user)": {"$addToSet": "$n.user"}
+			}},
+			{"$project": {"_id": 0, "count(id)": 1, "distinct(n_user)": {"$size": "$distinct(n_user)"}, "n.region": "$_id.n_region"}},
+			{"$sort": {"count(id)": -1, "n.region": 1}},
+			{"$limit": 3}
+		  ],
+		  "cursor": {}}`,
+	},
+	{
+		`select count(distinct user), median(latency), percentile(latency, 0.99), variance(latency), array_agg(distinct host)`,
+		`{"aggregate": "graph",
+		  "pipeline": [
+			{"$group": {
+				"_id": null,
+				"array_agg(distinct host)": {"$addToSet": "$host"},
+				"count(distinct user)": {"$addToSet": "$user"},
+				"median(latency)": {"$median": {"input": "$latency", "method": "approximate"}},
+				"percentile(latency, 0_99)": {"$percentile": {"input": "$latency", "method": "approximate", "p": [0.99]}},
+				"variance(latency)": {"$stdDevSamp": "$latency"}
+			}},
+			{"$project": {
+				"_id": 0,
+				"array_agg(distinct host)": 1,
+				"count(distinct user)": {"$size": "$count(distinct user)"},
+				"median(latency)": 1,
+				"percentile(latency, 0_99)": {"$arrayElemAt": ["$percentile(latency, 0_99)", 0]},
+				"variance(latency)": {"$pow": ["$variance(latency)", 2]}
+			}}
+		  ],
+		  "cursor": {}}`,
+	},
+	{
+		`select distinct region, host from events where status >= 500 order by region`,
+		`{"aggregate": "events",
+		  "pipeline": [
+			{"$match": {"status": {"$gte": 500}}},
+			{"$group": {"_id": {"host": "$host", "region": "$region"}}},
+			{"$project": {"_id": 0, "host": "$_id.host", "region": "$_id.region"}},
+			{"$sort": {"region": 1}}
+		  ],
+		  "cursor": {}}`,
+	},
+	{
+		`select sum(bytes), average(bytes)`,
+		`{"aggregate": "graph",
+		  "pipeline": [
+			{"$group": {"_id": null, "average(bytes)": {"$avg": "$bytes"}, "sum(bytes)": {"$sum": "$bytes"}}},
+			{"$project": {"_id": 0, "average(bytes)": 1, "sum(bytes)": 1}}
+		  ],
+		  "cursor": {}}`,
+	},
+}
+
+func Test_Mongo(t *testing.T) {
+	for _, test := range mongoTests {
+		got, err := ToMongo(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var want bytes.Buffer
+		if err := json.Compact(&want, []byte(test.json)); err != nil {
+			t.Fatalf("%s: %v", test.json, err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.query, got, want.Bytes())
+		}
+	}
+	if _, err := ToMongo(`select string_agg(host, ",")`); err == nil {
+		t.Error("expected an error for an unsupported aggragation")
+	}
+	if _, err := ToMongo(`select name, rank() over (order by age)`); err == nil {
+		t.Error("expected an error for a window")
+	}
+	if _, err := ToMongo(`select distinct count(id) group by region`); err == nil {
+		t.Error("expected an error for a select distinct grouped on an unselected field")
+	}
+}
+
+func Test_MongoPipeline(t *testing.T) {
+	p := NewParse(`select name where age > 3 order by age limit 2`)
+	p.Generate()
+	stages, err := p.MongoPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(stages)
+	want := `[{"$match":{"age":{"$gt":3}}},{"$sort":{"age":1}},{"$limit":2},{"$project":{"_id":0,"name":1}}]`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
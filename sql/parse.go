@@ -1,13 +1,42 @@
 package sql
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 type SqlType int
 
 const (
 	SqlSelect SqlType = iota
+	SqlInsert
+	SqlUpdate
+	SqlDelete
+	SqlCreate
 )
 
+var sqlTypeNames = [...]string{
+	SqlSelect: KeySelect,
+	SqlInsert: KeyInsert,
+	SqlUpdate: KeyUpdate,
+	SqlDelete: KeyDelete,
+	SqlCreate: KeyCreate + Space + KeyTable,
+}
+
+func (t SqlType) String() string {
+	if t < 0 || int(t) >= len(sqlTypeNames) {
+		return "unknown"
+	}
+	return sqlTypeNames[t]
+}
+
+// DefaultTable is the table queried when the from clause is omitted.
+const DefaultTable = "graph"
+
 var (
 	parseError = errors.New("syntax error")
 	aggError   = errors.New("aggragation error")
@@ -24,16 +53,47 @@ const (
 	stateGroupBy
 	stateOrderBy
 	stateSort
+	stateLimit
+	stateOffset
+	stateSetOp
+	stateInsert
+	stateUpdate
+	stateDelete
+	stateCreate
 	stateEnd
 	stateError
 )
 
 type model struct {
-	Type         SqlType // currently set to select
-	TableName    string  // currently set to graph
-	Fields       []string
+	Type         SqlType  // select, insert, update, delete or create table
+	TableName    string   // the first table of the from clause, graph by default, or the table modified or created
+	Alias        string   // alias of TableName
+	Joins        []*Join  // tables joined to TableName, in order
+	SetOps       []*SetOp // queries combined with this one; OrderBy, Limit and Offset then apply to the combined result
+	Distinct     bool     // select distinct: duplicate result rows are dropped
+	Fields       []string // plain fields, in select order
+	Expressions  []Expr   // computed columns that are neither fields nor aggragations
+	Columns      []string // result columns, fields, expressions, aggragations and windows in select order
+	Types        []Type   // static types of Columns, set when the query is checked
 	Aggragations Aggragation
+	Windows      []*Window // window functions and aggragations over windows, computed after grouping
 	Conditions   Condition
+	GroupBy      []string
+	OrderBy      []orderItem
+	Limit        int            // negative when there is no limit
+	LimitArg     *Placeholder   // set when the limit is a placeholder
+	Offset       int            // number of result rows to skip
+	OffsetArg    *Placeholder   // set when the offset is a placeholder
+	Set          []Assignment   // the assignments of an update
+	Values       [][]Assignment // the rows of an insert, whose Column is empty when the insert names no columns
+	Schema       []Column       // the columns of a created table
+	Placeholders int            // number of arguments for "?" and "$n" placeholders
+	Names        []string       // names of ":name" placeholders
+}
+
+type orderItem struct {
+	Column string // a field or an aggragation such as "count(id)"
+	Desc   bool
 }
 
 type parse struct {
@@ -41,103 +101,1467 @@ type parse struct {
 	model
 	state
 	error
+	token      item // one token of lookahead for the parser
+	peekCount  int
+	numbered   bool // "$n" placeholders seen, which rule out "?"
+	nested     bool // parsing a subquery, which ends at a right paren
+	setOperand bool // parsing a query following a set operator, which ends at the next one
+	inWindow   bool // parsing the over clause of a window, which cannot hold another
+	positions  positions
+}
+
+// positions holds the offsets in the query of the expressions and
+// conditions parsed, by pointer, and of the fields and aggragations of each
+// model, by a textKey. A text appearing more than once in a model keeps its
+// first offset.
+type positions map[interface{}]int
+
+type textKey struct {
+	m    *model
+	text string
+}
+
+// mark records that text starts at the offset pos in the query of p.
+func (p *parse) mark(text string, pos int) {
+	k := textKey{&p.model, text}
+	if _, ok := p.positions[k]; !ok {
+		p.positions[k] = pos
+	}
 }
 
 func NewParse(text string) *parse {
 	return &parse{
-		lexer: lex("sql", text),
-		model: model{
-			Fields: make([]string, 0),
-			Aggragations: Aggragation{
-				Items: make([]aggItem, 0),
-			},
+		lexer:     lex("sql", text),
+		model:     newModel(),
+		state:     stateStart,
+		positions: make(positions),
+	}
+}
+
+func newModel() model {
+	return model{
+		TableName: DefaultTable,
+		Fields:    make([]string, 0),
+		Columns:   make([]string, 0),
+		Aggragations: Aggragation{
+			Items: make([]aggItem, 0),
 		},
-		state: stateStart,
+		Limit: -1,
+	}
+}
+
+// Err returns the error that stopped Generate, if any.
+func (p *parse) Err() error {
+	return p.error
+}
+
+// nextToken returns the next item, honoring backupToken.
+func (p *parse) nextToken() item {
+	if p.peekCount > 0 {
+		p.peekCount--
+		return p.token
+	}
+	p.token = p.lexer.nextItem()
+	return p.token
+}
+
+// backupToken backs the input stream up one item.
+func (p *parse) backupToken() {
+	p.peekCount++
+}
+
+func (p *parse) peekToken() item {
+	i := p.nextToken()
+	p.backupToken()
+	return i
+}
+
+// errorf stops the parse and lets the lexing goroutine run to completion.
+func (p *parse) errorf(err error) {
+	p.state = stateError
+	p.error = err
+	p.lexer.drain()
+}
+
+// unexpected reports an item that is not valid at the current position.
+func (p *parse) unexpected(i item) {
+	if i.typ == itemError && i.val != "" {
+		p.errorf(errors.New(i.val))
+		return
+	}
+	if i.typ == itemError {
+		p.errorf(parseError)
+		return
 	}
+	p.errorf(fmt.Errorf("%v: unexpected %s", parseError, i))
 }
-func (p *parse) switchState(i itemType) {
-	switch i {
-	case itemError:
-		p.state = stateError
-		p.error = parseError
+
+func (p *parse) switchState(i item) {
+	// an insert or create ends after its values or columns, an update or
+	// delete after its where
+	if p.Type != SqlSelect && i.typ != itemEOF && (i.typ != itemWhere || p.Type == SqlInsert || p.Type == SqlCreate) {
+		p.unexpected(i)
+		return
+	}
+	switch i.typ {
 	case itemSelect:
 		p.state = stateField
+	case itemInsert:
+		p.state = p.statement(i, stateInsert)
+	case itemUpdate:
+		p.state = p.statement(i, stateUpdate)
+	case itemDelete:
+		p.state = p.statement(i, stateDelete)
+	case itemCreate:
+		p.state = p.statement(i, stateCreate)
 	case itemWhere:
 		p.state = stateCondition
 	case itemGroupBy:
 		p.state = stateGroupBy
 	case itemOrderBy:
 		p.state = stateOrderBy
+	case itemLimit:
+		p.state = stateLimit
+	case itemOffset:
+		p.state = stateOffset
 	case itemEOF:
-		p.state = stateEnd
+		if p.nested {
+			p.unexpected(i)
+			break
+		}
+		p.endOperand()
+	case itemRightParen:
+		if !p.nested {
+			p.unexpected(i)
+			break
+		}
+		p.endOperand()
+	case itemUnion, itemIntersect, itemExcept:
+		p.backupToken()
+		if p.setOperand {
+			p.state = stateEnd
+			break
+		}
+		p.state = stateSetOp
 	case itemFrom:
 		p.state = stateFromTable
+	default:
+		p.unexpected(i)
 	}
+}
 
+// statement returns the state parsing the statement i starts, which cannot
+// be nested in a query.
+func (p *parse) statement(i item, s state) state {
+	if p.nested || p.setOperand {
+		p.unexpected(i)
+		return stateError
+	}
+	return s
 }
+
 func (p *parse) Generate() {
 	for {
 		switch p.state {
 		case stateError:
 			return
+		case stateEnd:
+			if err := p.checkAgg(); err != nil {
+				p.errorf(err)
+				return
+			}
+			if err := p.checkDistinct(); err != nil {
+				p.errorf(err)
+				return
+			}
+			if !p.setOperand {
+				if err := p.checkSetOps(); err != nil {
+					p.errorf(err)
+				}
+			}
+			return
 		case stateStart:
-			i := p.lexer.nextItem()
-			p.switchState(i.typ)
+			p.switchState(p.nextToken())
 		case stateField:
 			p.getFields()
-
 		case stateFromTable:
-			i := p.lexer.nextItem()
-			if i.typ == itemError {
-				p.switchState(i.typ)
+			p.getFrom()
+		case stateCondition:
+			p.getConditions()
+		case stateGroupBy:
+			p.getGroupBy()
+		case stateOrderBy:
+			p.getOrderBy()
+		case stateLimit:
+			p.getLimit()
+		case stateOffset:
+			p.getOffset()
+		case stateSetOp:
+			p.getSetOp()
+		case stateInsert:
+			p.getInsert()
+		case stateUpdate:
+			p.getUpdate()
+		case stateDelete:
+			p.getDelete()
+		case stateCreate:
+			p.getCreate()
+		}
+	}
+}
+
+// getInsert parses the table, the columns and the rows of values of an
+// insert.
+func (p *parse) getInsert() {
+	p.Type = SqlInsert
+	table, ok := p.expect(itemIdentifier)
+	if !ok {
+		return
+	}
+	p.TableName = table.val
+	var columns []string
+	if p.peekToken().typ == itemLeftParen {
+		p.nextToken()
+		for {
+			column, ok := p.expect(itemIdentifier)
+			if !ok {
+				return
+			}
+			if contains(columns, column.val) {
+				p.errorf(fmt.Errorf("%v: column %s inserted twice", parseError, column.val))
+				return
+			}
+			columns = append(columns, column.val)
+			p.mark(column.val, column.pos)
+			if next := p.nextToken(); next.typ == itemRightParen {
 				break
+			} else if next.typ != itemComma {
+				p.unexpected(next)
+				return
 			}
-			p.TableName = i.val
-			next := p.lexer.nextItem()
-			p.switchState(next.typ)
-		case stateCondition:
+		}
+	}
+	if _, ok := p.expect(itemValues); !ok {
+		return
+	}
+	for {
+		if _, ok := p.expect(itemLeftParen); !ok {
+			return
+		}
+		var row []Assignment
+		for {
+			column := ""
+			if len(row) < len(columns) {
+				column = columns[len(row)]
+			}
+			a, ok := p.getAssignment(column)
+			if !ok {
+				return
+			}
+			row = append(row, a)
+			if next := p.nextToken(); next.typ == itemRightParen {
+				break
+			} else if next.typ != itemComma {
+				p.unexpected(next)
+				return
+			}
+		}
+		want := len(columns)
+		if want == 0 && len(p.Values) > 0 {
+			want = len(p.Values[0])
+		}
+		if want > 0 && len(row) != want {
+			p.errorf(fmt.Errorf("%v: %d values for %d columns", parseError, len(row), want))
+			return
+		}
+		p.Values = append(p.Values, row)
+		if next := p.nextToken(); next.typ != itemComma {
+			p.switchState(next)
+			return
+		}
+	}
+}
 
+// getUpdate parses the table and the assignments of an update.
+func (p *parse) getUpdate() {
+	p.Type = SqlUpdate
+	table, ok := p.expect(itemIdentifier)
+	if !ok {
+		return
+	}
+	p.TableName = table.val
+	if _, ok := p.expect(itemSet); !ok {
+		return
+	}
+	for {
+		column, ok := p.expect(itemIdentifier)
+		if !ok {
+			return
+		}
+		for _, a := range p.Set {
+			if a.Column == column.val {
+				p.errorf(fmt.Errorf("%v: column %s set twice", parseError, column.val))
+				return
+			}
+		}
+		p.mark(column.val, column.pos)
+		if _, ok := p.expect(itemEqual); !ok {
+			return
+		}
+		a, ok := p.getAssignment(column.val)
+		if !ok {
+			return
+		}
+		p.Set = append(p.Set, a)
+		if next := p.nextToken(); next.typ != itemComma {
+			p.switchState(next)
+			return
 		}
 	}
 }
 
-func (p *parse) getFields() {
-	var next item
+// getDelete parses the table of a delete.
+func (p *parse) getDelete() {
+	p.Type = SqlDelete
+	table, ok := p.expect(itemIdentifier)
+	if !ok {
+		return
+	}
+	p.TableName = table.val
+	p.switchState(p.nextToken())
+}
+
+// getCreate parses the table and the typed columns of a create table.
+func (p *parse) getCreate() {
+	p.Type = SqlCreate
+	table, ok := p.expect(itemIdentifier)
+	if !ok {
+		return
+	}
+	p.TableName = table.val
+	if _, ok := p.expect(itemLeftParen); !ok {
+		return
+	}
+	for {
+		name, ok := p.expect(itemIdentifier)
+		if !ok {
+			return
+		}
+		typ, ok := p.expect(itemIdentifier)
+		if !ok {
+			return
+		}
+		column, err := newColumn(name.val, typ.val)
+		if err != nil {
+			p.errorf(fmt.Errorf("%v: %v", parseError, err))
+			return
+		}
+		for _, c := range p.Schema {
+			if c.Name == column.Name {
+				p.errorf(fmt.Errorf("%v: column %s defined twice", parseError, column.Name))
+				return
+			}
+		}
+		p.Schema = append(p.Schema, column)
+		if next := p.nextToken(); next.typ == itemRightParen {
+			break
+		} else if next.typ != itemComma {
+			p.unexpected(next)
+			return
+		}
+	}
+	p.switchState(p.nextToken())
+}
+
+// getAssignment parses the value assigned to column: a value, a
+// placeholder or an expression.
+func (p *parse) getAssignment(column string) (Assignment, bool) {
+	a := Assignment{Column: column}
+	e, ok := p.getExpr()
+	if !ok {
+		return a, false
+	}
+	if l, ok := e.(*Literal); ok {
+		a.Value = l.Value
+	} else {
+		a.Expr = e
+	}
+	return a, true
+}
+
+// expect returns the next item, which must be of type typ.
+func (p *parse) expect(typ itemType) (item, bool) {
+	i := p.nextToken()
+	if i.typ != typ {
+		p.unexpected(i)
+		return i, false
+	}
+	return i, true
+}
+
+// getFrom parses the tables of the from clause and the joins between them.
+func (p *parse) getFrom() {
+	var ok bool
+	if p.TableName, p.Alias, ok = p.getTable(); !ok {
+		return
+	}
 	for {
-		i := p.lexer.nextItem()
-		if i.typ == itemError {
-			p.switchState(i.typ)
+		join := &Join{}
+		switch i := p.nextToken(); i.typ {
+		case itemComma, itemCrossJoin:
+			join.Type = JoinCross
+		case itemJoin:
+			join.Type = JoinInner
+		case itemLeftJoin:
+			join.Type = JoinLeft
+		default:
+			p.switchState(i)
+			return
+		}
+		if join.Table, join.Alias, ok = p.getTable(); !ok {
+			return
+		}
+		if q := join.name(); contains(p.tables(), q) {
+			p.errorf(fmt.Errorf("%v: table %s joined twice, alias it", parseError, q))
 			return
 		}
-		if i.typ == itemIdentifier {
-			p.Fields = append(p.Fields, i.val)
-		} else if i.typ > itemAggragation {
-			if p.lexer.nextItem().typ == itemError { // eliminate the left paren
-				p.switchState(i.typ)
+		if join.Type != JoinCross {
+			if i := p.nextToken(); i.typ != itemOn {
+				p.unexpected(i)
 				return
 			}
-			field := p.lexer.nextItem()
-			if field.typ == itemError {
-				p.switchState(i.typ)
+			c := p.orCondition()
+			if p.state == stateError {
 				return
 			}
-			p.Aggragations.Items = append(p.Aggragations.Items, aggItem{Field: field.val, Agg: itemType2AggType[i.typ]})
-			if p.lexer.nextItem().typ == itemError { // eliminate the right paren
-				p.switchState(i.typ)
+			if !isCondition(c) {
+				p.unexpected(p.nextToken())
 				return
 			}
+			join.On = c
 		}
-		if next = p.lexer.nextItem(); next.typ != itemComma {
-			break
+		p.Joins = append(p.Joins, join)
+	}
+}
+
+// getTable parses a table name and its optional alias.
+func (p *parse) getTable() (name, alias string, ok bool) {
+	i := p.nextToken()
+	if i.typ != itemIdentifier {
+		p.unexpected(i)
+		return "", "", false
+	}
+	name = i.val
+	next := p.nextToken()
+	if next.typ == itemAs {
+		if next = p.nextToken(); next.typ != itemIdentifier {
+			p.unexpected(next)
+			return "", "", false
+		}
+	}
+	if next.typ == itemIdentifier {
+		alias = next.val
+	} else {
+		p.backupToken()
+	}
+	return name, alias, true
+}
+
+func (p *parse) getFields() {
+	if p.peekToken().typ == itemDistinctArg {
+		p.nextToken()
+		p.Distinct = true
+	}
+	for {
+		i := p.nextToken()
+		if i.typ > itemAggragation || p.windowFunction(i) {
+			column, ok := p.getAggOrWindow(i)
+			if !ok {
+				return
+			}
+			p.Columns = append(p.Columns, column)
+		} else {
+			p.backupToken()
+			e, ok := p.getExpr()
+			if !ok {
+				return
+			}
+			if ident, ok := e.(*Ident); ok {
+				p.Fields = append(p.Fields, ident.Name)
+			} else if !p.hasExpression(e.String()) {
+				p.Expressions = append(p.Expressions, e)
+			}
+			p.Columns = append(p.Columns, e.String())
+		}
+		if next := p.nextToken(); next.typ != itemComma {
+			p.switchState(next)
+			return
+		}
+	}
+}
+
+// windowFunction reports whether the item i starts a call of a window
+// function.
+func (p *parse) windowFunction(i item) bool {
+	return i.typ == itemIdentifier && windowFunctions[strings.ToLower(i.val)] != nil && p.peekToken().typ == itemLeftParen
+}
+
+// getAggOrWindow parses the aggragation or the window function i and the
+// window it is computed over, if any, and returns its column. The
+// aggragation or window is computed even if it is not selected.
+func (p *parse) getAggOrWindow(i item) (string, bool) {
+	w := &Window{}
+	if i.typ > itemAggragation {
+		agg, ok := p.getAggragation(i)
+		if !ok {
+			return "", false
+		}
+		if p.peekToken().typ != itemOver {
+			if !p.hasAggragation(agg) {
+				p.Aggragations.Items = append(p.Aggragations.Items, agg)
+			}
+			return agg.String(), true
+		}
+		if agg.Distinct {
+			p.errorf(fmt.Errorf("%v: distinct %s over a window not supported", aggError, agg.Agg))
+			return "", false
+		}
+		w.Agg = agg
+	} else {
+		w.Func = strings.ToLower(i.val)
+		p.nextToken()
+		args, ok := p.getArgs()
+		if !ok {
+			return "", false
+		}
+		w.Args = args
+		if err := windowFunctions[w.Func].checkArgs(w.Func, len(args)); err != nil {
+			p.errorf(err)
+			return "", false
+		}
+		if len(args) > 1 {
+			if l, ok := args[1].(*Literal); !ok || !isOffset(l.Value) {
+				p.errorf(fmt.Errorf("%v: %s offset %s not a non-negative integer", parseError, w.Func, args[1]))
+				return "", false
+			}
+		}
+		if next := p.peekToken(); next.typ != itemOver {
+			p.errorf(fmt.Errorf("%v: %s must be followed by over", parseError, w.Func))
+			return "", false
+		}
+	}
+	if !p.getOver(w) {
+		return "", false
+	}
+	column := w.String()
+	p.mark(column, i.pos)
+	if !p.hasWindow(column) {
+		p.Windows = append(p.Windows, w)
+	}
+	return column, true
+}
+
+// isOffset reports whether v is a non-negative integer.
+func isOffset(v interface{}) bool {
+	n, ok := v.(int64)
+	return ok && n >= 0
+}
+
+// getOver parses the over clause of the window w: the columns it is
+// partitioned on and those it is ordered on, which may not hold another
+// window.
+func (p *parse) getOver(w *Window) bool {
+	over := p.nextToken()
+	if p.inWindow {
+		p.errorf(fmt.Errorf("%v: window within a window at offset %d", parseError, over.pos))
+		return false
+	}
+	if _, ok := p.expect(itemLeftParen); !ok {
+		return false
+	}
+	p.inWindow = true
+	defer func() {
+		p.inWindow = false
+	}()
+	if p.peekToken().typ == itemPartitionBy {
+		p.nextToken()
+		for {
+			column, ok := p.getColumn()
+			if !ok {
+				return false
+			}
+			w.PartitionBy = append(w.PartitionBy, column)
+			if p.peekToken().typ != itemComma {
+				break
+			}
+			p.nextToken()
+		}
+	}
+	if p.peekToken().typ == itemOrderBy {
+		p.nextToken()
+		for {
+			o, ok := p.getOrderItem()
+			if !ok {
+				return false
+			}
+			w.OrderBy = append(w.OrderBy, o)
+			if p.peekToken().typ != itemComma {
+				break
+			}
+			p.nextToken()
+		}
+	}
+	_, ok := p.expect(itemRightParen)
+	return ok
+}
+
+// getAggragation parses the parenthesized argument following the
+// aggragation i, optionally preceded by distinct and followed by the
+// constants the aggragation takes.
+func (p *parse) getAggragation(i item) (aggItem, bool) {
+	if next := p.nextToken(); next.typ != itemLeftParen {
+		p.unexpected(next)
+		return aggItem{}, false
+	}
+	agg := aggItem{Agg: itemType2AggType[i.typ]}
+	if p.peekToken().typ == itemDistinctArg {
+		p.nextToken()
+		agg.Distinct = true
+	}
+	arg, ok := p.getExpr()
+	if !ok {
+		return aggItem{}, false
+	}
+	next := p.nextToken()
+	for next.typ == itemComma {
+		e, ok := p.getExpr()
+		if !ok {
+			return aggItem{}, false
+		}
+		l, ok := e.(*Literal)
+		if !ok {
+			p.errorf(fmt.Errorf("%v: %s of %s not a constant", aggError, agg.Agg, e))
+			return aggItem{}, false
+		}
+		agg.Params = append(agg.Params, l.Value)
+		next = p.nextToken()
+	}
+	if next.typ != itemRightParen {
+		p.unexpected(next)
+		return aggItem{}, false
+	}
+	if err := agg.checkParams(); err != nil {
+		p.errorf(fmt.Errorf("%v: %v at offset %d", aggError, err, i.pos))
+		return aggItem{}, false
+	}
+	agg.Field = arg.String()
+	if _, ok := arg.(*Ident); !ok {
+		agg.Expr = arg
+	}
+	p.mark(agg.String(), i.pos)
+	return agg, true
+}
+
+// getExpr parses an expression, climbing the precedence of its operators.
+func (p *parse) getExpr() (Expr, bool) {
+	pos := p.peekToken().pos
+	left, ok := p.unaryExpr()
+	if !ok {
+		return nil, false
+	}
+	p.positions[left] = pos
+	p.mark(left.String(), pos)
+	return p.binaryExpr(left, precConcat)
+}
+
+// binaryExpr parses the operators following left that bind at least as tight
+// as prec. The right operand takes the operators binding tighter first.
+func (p *parse) binaryExpr(left Expr, prec int) (Expr, bool) {
+	for {
+		op, ok := itemType2Operator[p.peekToken().typ]
+		if !ok || op.prec() < prec {
+			return left, true
+		}
+		p.nextToken()
+		pos := p.peekToken().pos
+		right, ok := p.unaryExpr()
+		if !ok {
+			return nil, false
+		}
+		p.positions[right] = pos
+		p.mark(right.String(), pos)
+		if right, ok = p.binaryExpr(right, op.prec()+1); !ok {
+			return nil, false
+		}
+		pos = p.positions[left]
+		left = &Binary{Op: op, Left: left, Right: right}
+		p.positions[left] = pos
+	}
+}
+
+// unaryExpr parses an operand, possibly signed or parenthesized. A negated
+// number is folded into the literal.
+func (p *parse) unaryExpr() (Expr, bool) {
+	switch i := p.nextToken(); i.typ {
+	case itemMinus:
+		x, ok := p.unaryExpr()
+		if !ok {
+			return nil, false
+		}
+		if l, ok := x.(*Literal); ok {
+			switch v := l.Value.(type) {
+			case int64:
+				return &Literal{Value: -v}, true
+			case float64:
+				return &Literal{Value: -v}, true
+			}
+		}
+		return &Unary{Op: OpNeg, X: x}, true
+	case itemPlus:
+		return p.unaryExpr()
+	case itemLeftParen:
+		if p.peekToken().typ == itemSelect {
+			return p.getSubquery()
+		}
+		e, ok := p.getExpr()
+		if !ok {
+			return nil, false
+		}
+		if next := p.nextToken(); next.typ != itemRightParen {
+			p.unexpected(next)
+			return nil, false
+		}
+		return e, true
+	}
+	p.backupToken()
+	return p.operand()
+}
+
+// operand parses a field, a call or a literal, which may be a placeholder
+// bound with the query.
+func (p *parse) operand() (Expr, bool) {
+	i := p.nextToken()
+	switch {
+	case i.typ == itemIdentifier && p.peekToken().typ == itemLeftParen:
+		return p.getCall(i)
+	case i.typ == itemIdentifier:
+		return &Ident{Name: i.val}, true
+	case i.typ > itemAggragation:
+		p.errorf(fmt.Errorf("%v: %s not allowed in an expression", aggError, i.val))
+		return nil, false
+	}
+	p.backupToken()
+	v, ok := p.getValue()
+	if !ok {
+		return nil, false
+	}
+	return &Literal{Value: v}, true
+}
+
+// getCall parses the arguments of a call of the function name.
+func (p *parse) getCall(name item) (Expr, bool) {
+	if windowFunctions[strings.ToLower(name.val)] != nil {
+		p.errorf(fmt.Errorf("%v: window function %s not allowed in an expression", parseError, name.val))
+		return nil, false
+	}
+	fn, ok := lookupFunction(name.val)
+	if !ok {
+		p.errorf(fmt.Errorf("%v: unknown function %s", parseError, name.val))
+		return nil, false
+	}
+	p.nextToken()
+	call := &Call{Name: strings.ToLower(name.val)}
+	if call.Args, ok = p.getArgs(); !ok {
+		return nil, false
+	}
+	if err := fn.checkArgs(call.Name, len(call.Args)); err != nil {
+		p.errorf(err)
+		return nil, false
+	}
+	return call, true
+}
+
+// getArgs parses the arguments of a call up to the right paren, the left
+// one read.
+func (p *parse) getArgs() ([]Expr, bool) {
+	var args []Expr
+	if p.peekToken().typ == itemRightParen {
+		p.nextToken()
+		return args, true
+	}
+	for {
+		arg, ok := p.getExpr()
+		if !ok {
+			return nil, false
+		}
+		args = append(args, arg)
+		if next := p.nextToken(); next.typ == itemRightParen {
+			return args, true
+		} else if next.typ != itemComma {
+			p.unexpected(next)
+			return nil, false
+		}
+	}
+}
+
+func (p *parse) getConditions() {
+	c := p.orCondition()
+	if p.state == stateError {
+		return
+	}
+	if !isCondition(c) {
+		p.unexpected(p.nextToken())
+		return
+	}
+	p.Conditions = c
+	p.switchState(p.nextToken())
+}
+
+// orCondition parses conditions joined by "or", which binds loosest.
+func (p *parse) orCondition() Condition {
+	return p.logicCondition(itemOr, LogicOr, p.andCondition)
+}
+
+func (p *parse) andCondition() Condition {
+	return p.logicCondition(itemAnd, LogicAnd, p.unaryCondition)
+}
+
+// logicCondition parses operands joined by the logic item op, collecting them
+// into a single MultiCondition. A parenthesized operand joined by the same
+// logic is merged in, so "a and (b and c)" reads as "a and b and c".
+func (p *parse) logicCondition(op itemType, logic LogicType, operand func() Condition) Condition {
+	c := operand()
+	if p.state == stateError || p.peekToken().typ != op {
+		return c
+	}
+	multi := &MultiCondition{Logic: logic}
+	multi.add(c)
+	for p.peekToken().typ == op {
+		p.nextToken()
+		c = operand()
+		if p.state == stateError {
+			return nil
+		}
+		if !isCondition(c) {
+			p.unexpected(p.nextToken())
+			return nil
+		}
+		multi.add(c)
+	}
+	return multi
+}
+
+func (p *parse) unaryCondition() Condition {
+	switch i := p.nextToken(); i.typ {
+	case itemExists:
+		if next := p.nextToken(); next.typ != itemLeftParen {
+			p.unexpected(next)
+			return nil
+		}
+		sub, ok := p.getSubquery()
+		if !ok {
+			return nil
+		}
+		return &SingleCondition{Comparator: ComparatorEXISTS, Right: sub}
+	case itemNot:
+		c := p.unaryCondition()
+		if p.state == stateError {
+			return nil
+		}
+		if !isCondition(c) {
+			p.unexpected(p.nextToken())
+			return nil
+		}
+		return &MultiCondition{SubConditions: []Condition{c}, Logic: LogicNot}
+	case itemLeftParen:
+		c := p.orCondition()
+		if p.state == stateError {
+			return nil
+		}
+		if next := p.nextToken(); next.typ != itemRightParen {
+			p.unexpected(next)
+			return nil
+		}
+		if isCondition(c) {
+			return c
+		}
+		// the parens grouped an expression, which goes on
+		left, ok := p.binaryExpr(c.(Expr), precConcat)
+		if !ok {
+			return nil
+		}
+		return p.exprCondition(left)
+	default:
+		p.backupToken()
+		left, ok := p.getExpr()
+		if !ok {
+			return nil
+		}
+		return p.exprCondition(left)
+	}
+}
+
+// exprCondition parses the comparison following left. Before a closing
+// paren left is returned as it is, since the paren may group an expression
+// rather than a condition, as in "(a + b) * 2 > c".
+func (p *parse) exprCondition(left Expr) Condition {
+	if p.peekToken().typ == itemRightParen {
+		return left
+	}
+	// "not like" and "not in" negate the condition that follows
+	negate := p.peekToken().typ == itemNot
+	if negate {
+		p.nextToken()
+	}
+	c := p.singleCondition(left)
+	sc, ok := c.(*SingleCondition)
+	if !ok {
+		return c
+	}
+	p.positions[sc] = p.positions[left]
+	if negate {
+		if !sc.Comparator.takesPattern() && sc.Comparator != ComparatorIN {
+			p.errorf(fmt.Errorf("%v: not before %s", parseError, sc.Comparator))
+			return nil
+		}
+		return &MultiCondition{SubConditions: []Condition{sc}, Logic: LogicNot}
+	}
+	return c
+}
+
+// singleCondition parses the comparison following left and its right side,
+// a value or an expression such as another field.
+func (p *parse) singleCondition(left Expr) Condition {
+	op := p.nextToken()
+	cmp, ok := itemType2Comparator[op.typ]
+	if !ok {
+		p.unexpected(op)
+		return nil
+	}
+	if cmp == ComparatorIN {
+		if i := p.nextToken(); i.typ != itemLeftParen {
+			p.unexpected(i)
+			return nil
+		}
+		if p.peekToken().typ == itemSelect {
+			sub, ok := p.getSubquery()
+			if !ok {
+				return nil
+			}
+			c := newSingleCondition(left, cmp, nil)
+			c.Right = sub
+			return c
+		}
+		values, ok := p.getValueList()
+		if !ok {
+			return nil
+		}
+		return newSingleCondition(left, cmp, values)
+	}
+	right, ok := p.getExpr()
+	if !ok {
+		return nil
+	}
+	if l, ok := right.(*Literal); ok {
+		return p.pattern(newSingleCondition(left, cmp, l.Value), p.positions[right])
+	}
+	if cmp.takesPattern() {
+		p.errorf(fmt.Errorf("%v: %s pattern for %s must be a value, got %s", parseError, cmp, left, right))
+		return nil
+	}
+	c := newSingleCondition(left, cmp, nil)
+	c.Right = right
+	return c
+}
+
+// pattern parses the escape following the pattern of a like condition c,
+// and checks the pattern of a like or regexp given as a string, which
+// starts at the offset pos.
+func (p *parse) pattern(c *SingleCondition, pos int) Condition {
+	if !c.Comparator.takesPattern() {
+		return c
+	}
+	if c.isLike() && p.peekToken().typ == itemEscape {
+		p.nextToken()
+		v, ok := p.getValue()
+		if !ok {
+			return nil
+		}
+		s, ok := v.(string)
+		if !ok || utf8.RuneCountInString(s) != 1 {
+			p.errorf(fmt.Errorf("%v: like escape must be one character, got %v", parseError, v))
+			return nil
+		}
+		c.Escape = s
+	}
+	if _, ok := c.Value.(string); !ok {
+		return c
+	}
+	if err := c.checkPattern(); err != nil {
+		p.errorf(fmt.Errorf("%v: %v at offset %d", parseError, err, pos))
+		return nil
+	}
+	return c
+}
+
+func newSingleCondition(left Expr, cmp ComparatorType, value interface{}) *SingleCondition {
+	c := &SingleCondition{Field: left.String(), Comparator: cmp, Value: value}
+	if _, ok := left.(*Ident); !ok {
+		c.Expr = left
+	}
+	return c
+}
+
+// getSubquery parses a select within parens, the left one read.
+func (p *parse) getSubquery() (*Subquery, bool) {
+	sub := p.nestedParse()
+	sub.nested = true
+	if !p.resume(sub) {
+		return nil, false
+	}
+	return &Subquery{Query: &sub.model}, true
+}
+
+// nestedParse returns a parse of a query nested in the one of p. It takes
+// over the lookahead and placeholders of p, which go on counting across the
+// whole query and are kept on the outermost model.
+func (p *parse) nestedParse() *parse {
+	sub := &parse{
+		lexer:     p.lexer,
+		model:     newModel(),
+		state:     stateStart,
+		token:     p.token,
+		peekCount: p.peekCount,
+		numbered:  p.numbered,
+		positions: p.positions,
+	}
+	sub.Placeholders, sub.Names = p.Placeholders, p.Names
+	return sub
+}
+
+// resume runs the nested parse sub and takes the lookahead and placeholders
+// back from it.
+func (p *parse) resume(sub *parse) bool {
+	sub.Generate()
+	if err := sub.Err(); err != nil {
+		p.errorf(err)
+		return false
+	}
+	p.token, p.peekCount, p.numbered = sub.token, sub.peekCount, sub.numbered
+	p.Placeholders, p.Names = sub.Placeholders, sub.Names
+	sub.Placeholders, sub.Names = 0, nil
+	return true
+}
+
+// getSetOp parses a set operator and the query following it, which ends
+// where the next set operator or the whole query does.
+func (p *parse) getSetOp() {
+	op := &SetOp{Type: itemType2SetOp[p.nextToken().typ]}
+	if p.peekToken().typ == itemAll {
+		p.nextToken()
+		op.All = true
+	}
+	sub := p.nestedParse()
+	sub.nested, sub.setOperand = p.nested, true
+	if !p.resume(sub) {
+		return
+	}
+	op.Query = &sub.model
+	p.SetOps = append(p.SetOps, op)
+	p.switchState(p.nextToken())
+}
+
+// endOperand ends the parse at the end of the query, which the parse of a
+// query with set operators still has to see when p parses one of them.
+func (p *parse) endOperand() {
+	if p.setOperand {
+		p.backupToken()
+	}
+	p.state = stateEnd
+}
+
+// typedLiteral returns the value of a date, timestamp or interval literal.
+// A date is the midnight starting it in UTC, and a timestamp without a time
+// zone is in UTC.
+func typedLiteral(i item) (interface{}, error) {
+	n := strings.IndexAny(i.val, `'"`)
+	keyword, quoted := strings.ToLower(i.val[:n]), strings.TrimSpace(i.val[n:])
+	text := strings.Replace(quoted[1:len(quoted)-1], "''", "'", -1)
+	if quoted[0] == '"' {
+		if s, err := strconv.Unquote(quoted); err == nil {
+			text = s
+		}
+	}
+	switch i.typ {
+	case itemDate:
+		if t, err := time.Parse("2006-01-02", text); err == nil {
+			return t, nil
+		}
+	case itemTimestamp:
+		if t, ok := parseTimestamp(text); ok {
+			return t, nil
+		}
+	case itemInterval:
+		return ParseInterval(text)
+	}
+	return nil, fmt.Errorf("%s %q not valid", strings.TrimSpace(keyword), text)
+}
+
+// getValueList parses the values of an in list up to the right paren.
+func (p *parse) getValueList() ([]interface{}, bool) {
+	var values []interface{}
+	for {
+		v, ok := p.getValue()
+		if !ok {
+			return nil, false
 		}
+		values = append(values, v)
+		switch i := p.nextToken(); i.typ {
+		case itemComma:
+		case itemRightParen:
+			return values, true
+		default:
+			p.unexpected(i)
+			return nil, false
+		}
+	}
+}
+
+// getValue parses a literal or a placeholder. An identifier in an in list is
+// taken as a bare string.
+func (p *parse) getValue() (interface{}, bool) {
+	i := p.nextToken()
+	switch i.typ {
+	case itemNumber:
+		if n, err := strconv.ParseInt(i.val, 0, 64); err == nil {
+			return n, true
+		}
+		f, err := strconv.ParseFloat(i.val, 64)
+		if err != nil {
+			p.errorf(fmt.Errorf("%v: number %q not valid", parseError, i.val))
+			return nil, false
+		}
+		return f, true
+	case itemString:
+		if i.val[0] == '\'' {
+			// single quotes are doubled within a single quoted string
+			return strings.Replace(i.val[1:len(i.val)-1], "''", "'", -1), true
+		}
+		s, err := strconv.Unquote(i.val)
+		if err != nil {
+			s = i.val[1 : len(i.val)-1]
+		}
+		return s, true
+	case itemBool:
+		return strings.EqualFold(i.val, KeyTrue), true
+	case itemDate, itemTimestamp, itemInterval:
+		v, err := typedLiteral(i)
+		if err != nil {
+			p.errorf(fmt.Errorf("%v: %v at offset %d", parseError, err, i.pos))
+			return nil, false
+		}
+		return v, true
+	case itemIdentifier:
+		return i.val, true
+	case itemPlaceholder:
+		ph, err := p.placeholder(i.val)
+		if err != nil {
+			p.errorf(err)
+			return nil, false
+		}
+		return ph, true
+	}
+	p.unexpected(i)
+	return nil, false
+}
+
+// placeholder records the placeholder val. "?" placeholders are numbered in
+// order and cannot be mixed with "$n"; positional and named placeholders
+// cannot be mixed either.
+func (p *parse) placeholder(val string) (Placeholder, error) {
+	named := val[0] == ':'
+	if named && p.Placeholders > 0 || !named && len(p.Names) > 0 {
+		return Placeholder{}, fmt.Errorf("%v: %s mixes positional and named placeholders", parseError, val)
+	}
+	switch val[0] {
+	case ':':
+		if !contains(p.Names, val[1:]) {
+			p.Names = append(p.Names, val[1:])
+		}
+		return Placeholder{Index: -1, Name: val[1:]}, nil
+	case '$':
+		n, err := strconv.Atoi(val[1:])
+		if err != nil || n < 1 {
+			return Placeholder{}, fmt.Errorf("%v: placeholder %s not valid", parseError, val)
+		}
+		if !p.numbered && p.Placeholders > 0 {
+			return Placeholder{}, fmt.Errorf("%v: %s mixes ? and $n placeholders", parseError, val)
+		}
+		p.numbered = true
+		if n > p.Placeholders {
+			p.Placeholders = n
+		}
+		return Placeholder{Index: n - 1}, nil
+	}
+	if p.numbered {
+		return Placeholder{}, fmt.Errorf("%v: ? mixes ? and $n placeholders", parseError)
+	}
+	p.Placeholders++
+	return Placeholder{Index: p.Placeholders - 1}, nil
+}
+
+func (p *parse) getGroupBy() {
+	for {
+		i := p.nextToken()
+		if i.typ != itemIdentifier {
+			if i.typ > itemAggragation {
+				p.errorf(fmt.Errorf("%v: %s in group by", aggError, i.val))
+				return
+			}
+			p.unexpected(i)
+			return
+		}
+		p.GroupBy = append(p.GroupBy, i.val)
+		p.mark(i.val, i.pos)
+		if next := p.nextToken(); next.typ != itemComma {
+			p.switchState(next)
+			return
+		}
+	}
+}
+
+func (p *parse) getOrderBy() {
+	for {
+		o, ok := p.getOrderItem()
+		if !ok {
+			return
+		}
+		p.OrderBy = append(p.OrderBy, o)
+		if next := p.nextToken(); next.typ != itemComma {
+			p.switchState(next)
+			return
+		}
+	}
+}
+
+// getOrderItem parses a column to order on and its optional direction.
+func (p *parse) getOrderItem() (orderItem, bool) {
+	column, ok := p.getColumn()
+	if !ok {
+		return orderItem{}, false
+	}
+	o := orderItem{Column: column}
+	switch p.peekToken().typ {
+	case itemDesc:
+		p.nextToken()
+		o.Desc = true
+	case itemAsc:
+		p.nextToken()
+	}
+	return o, true
+}
+
+// getColumn parses a column to order or partition on: a field, an
+// expression, an aggragation or a window.
+func (p *parse) getColumn() (string, bool) {
+	switch i := p.nextToken(); {
+	case i.typ > itemAggragation || p.windowFunction(i):
+		return p.getAggOrWindow(i)
+	case i.typ < itemAggragation:
+		p.backupToken()
+		e, ok := p.getExpr()
+		if !ok {
+			return "", false
+		}
+		if _, ok := e.(*Literal); ok {
+			p.errorf(fmt.Errorf("%v: cannot order by constant %s", parseError, e))
+			return "", false
+		}
+		column := e.String()
+		// an expression ordered on but not selected is still computed
+		if _, ok := e.(*Ident); !ok && !contains(p.Columns, column) && !p.hasExpression(column) {
+			p.Expressions = append(p.Expressions, e)
+		}
+		return column, true
+	default:
+		p.unexpected(i)
+		return "", false
+	}
+}
+
+func (p *parse) getLimit() {
+	n, arg, ok := p.getCount(KeyLimit)
+	if !ok {
+		return
+	}
+	p.Limit, p.LimitArg = n, arg
+	p.switchState(p.nextToken())
+}
+
+func (p *parse) getOffset() {
+	n, arg, ok := p.getCount(KeyOffset)
+	if !ok {
+		return
 	}
-	if err := p.checkAgg(); err != nil {
-		p.state = stateError
-		p.error = err
+	p.Offset, p.OffsetArg = n, arg
+	p.switchState(p.nextToken())
+}
+
+// getCount returns the non-negative number or the placeholder of a limit or
+// offset clause.
+func (p *parse) getCount(clause string) (int, *Placeholder, bool) {
+	v, ok := p.getValue()
+	if !ok {
+		return 0, nil, false
 	}
-	p.switchState(next.typ)
+	switch v := v.(type) {
+	case int64:
+		if v >= 0 {
+			return int(v), nil, true
+		}
+	case Placeholder:
+		return 0, &v, true
+	}
+	p.errorf(fmt.Errorf("%v: %s %v not valid", parseError, clause, v))
+	return 0, nil, false
 }
 
+// checkAgg verifies that every plain field of an aggragating query is
+// grouped on, those its windows refer to included.
 func (p *parse) checkAgg() error {
+	if len(p.Aggragations.Items) == 0 && len(p.GroupBy) == 0 {
+		return nil
+	}
+	fields := append([]string(nil), p.Fields...)
+	for _, e := range p.Expressions {
+		fields = append(fields, exprFields(e)...)
+	}
+	fields = append(fields, p.windowFields()...)
+	for _, f := range fields {
+		if !contains(p.GroupBy, f) {
+			return fmt.Errorf("%v: field %q must appear in group by", aggError, f)
+		}
+	}
+	return nil
+}
+
+// checkDistinct verifies that a select distinct orders on its columns
+// only, since a column it drops could tell its duplicate rows apart. The
+// order by of a query following a set operator is checked with the set
+// operation.
+func (p *parse) checkDistinct() error {
+	if !p.Distinct || p.setOperand {
+		return nil
+	}
+	for _, o := range p.OrderBy {
+		if !contains(p.Columns, o.Column) {
+			return fmt.Errorf("%v: order by %s of a select distinct must be selected", parseError, o.Column)
+		}
+	}
+	return nil
+}
+
+// checkSetOps moves the order by, limit and offset of the last query of a
+// set operation to the first one, where they apply to the combined result,
+// and verifies that the queries are compatible column by column.
+func (p *parse) checkSetOps() error {
+	if len(p.SetOps) == 0 {
+		return nil
+	}
+	op := p.SetOps[0]
+	if p.hasTail() {
+		return fmt.Errorf("%v: order by, limit and offset must follow the last query of a %s", parseError, op.Type)
+	}
+	for _, op := range p.SetOps[:len(p.SetOps)-1] {
+		if op.Query.hasTail() {
+			return fmt.Errorf("%v: order by, limit and offset must follow the last query of a %s", parseError, op.Type)
+		}
+	}
+	last := p.SetOps[len(p.SetOps)-1].Query
+	p.OrderBy, last.OrderBy = last.OrderBy, nil
+	p.Limit, p.LimitArg, last.Limit, last.LimitArg = last.Limit, last.LimitArg, -1, nil
+	p.Offset, p.OffsetArg, last.Offset, last.OffsetArg = last.Offset, last.OffsetArg, 0, nil
+	last.dropHidden()
+	for _, o := range p.OrderBy {
+		if !contains(p.Columns, o.Column) {
+			return fmt.Errorf("%v: order by %s is not a column of the %s", parseError, o.Column, op.Type)
+		}
+	}
+	for _, op := range p.SetOps {
+		q := op.Query
+		if len(q.Columns) != len(p.Columns) {
+			return fmt.Errorf("%v: %s of queries with %d and %d columns", parseError, op.Type, len(p.Columns), len(q.Columns))
+		}
+		for i, c := range p.Columns {
+			if t, u := p.columnType(c), q.columnType(q.Columns[i]); !compatible(t, u) {
+				return fmt.Errorf("%v: %s column %d has types %s and %s", parseError, op.Type, i+1, t, u)
+			}
+		}
+	}
 	return nil
 }
+
+// hasTail reports whether the model has an order by, limit or offset.
+func (m *model) hasTail() bool {
+	return len(m.OrderBy) > 0 || m.Limit >= 0 || m.LimitArg != nil || m.Offset > 0 || m.OffsetArg != nil
+}
+
+// dropHidden drops the expressions, aggragations and windows that are
+// computed only to be ordered on. Those the windows kept partition or order
+// on stay.
+func (m *model) dropHidden() {
+	var windows []*Window
+	used := append([]string(nil), m.Columns...)
+	for _, w := range m.Windows {
+		if contains(m.Columns, w.String()) {
+			windows = append(windows, w)
+			used = append(used, w.columns()...)
+		}
+	}
+	m.Windows = windows
+	var exprs []Expr
+	for _, e := range m.Expressions {
+		if contains(used, e.String()) {
+			exprs = append(exprs, e)
+		}
+	}
+	m.Expressions = exprs
+	items := make([]aggItem, 0, len(m.Aggragations.Items))
+	for _, agg := range m.Aggragations.Items {
+		if contains(used, agg.String()) {
+			items = append(items, agg)
+		}
+	}
+	m.Aggragations.Items = items
+}
+
+func (p *parse) hasAggragation(agg aggItem) bool {
+	for _, a := range p.Aggragations.Items {
+		if a.String() == agg.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parse) hasWindow(label string) bool {
+	for _, w := range p.Windows {
+		if w.String() == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parse) hasExpression(label string) bool {
+	for _, e := range p.Expressions {
+		if e.String() == label {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	return indexOf(list, s) >= 0
+}
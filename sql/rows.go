@@ -0,0 +1,180 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Rows is the result of a query. Rows are read from the table as Next is
+// called, so a query is only as expensive as the rows consumed. Cancelling
+// the context stops the query before the next row.
+//
+//	rows, err := engine.Query(ctx, "select name, age from graph where age > 18")
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		var name string
+//		var age int64
+//		if err := rows.Scan(&name, &age); err != nil {
+//			...
+//		}
+//	}
+//	err = rows.Err()
+type Rows struct {
+	ctx     context.Context
+	columns []string
+	types   []Type
+	iter    rowIterator
+	row     []interface{}
+	err     error
+	closed  bool
+}
+
+func newRows(ctx context.Context, columns []string, types []Type, iter rowIterator) *Rows {
+	if types == nil {
+		types = make([]Type, len(columns))
+	}
+	return &Rows{ctx: ctx, columns: columns, types: types, iter: iter}
+}
+
+// Columns returns the result column names. Aggragations are named after
+// their expression, e.g. "count(id)".
+func (r *Rows) Columns() []string {
+	return r.columns
+}
+
+// ColumnTypes returns the static types of the result columns, TypeAny for
+// those depending on the data.
+func (r *Rows) ColumnTypes() []Type {
+	return r.types
+}
+
+// Next prepares the next row for Scan. It returns false at the end of the
+// result or on error; Err tells the two apart.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		r.Close()
+		return false
+	}
+	row, err := r.iter.next()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		r.Close()
+		return false
+	}
+	r.row = row
+	return true
+}
+
+// Scan copies the columns of the current row into dest, which holds one
+// pointer per column. Supported pointers are *interface{}, *string,
+// *[]byte, *int, *int64, *float64, *bool and *time.Time.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.row == nil {
+		return errors.New("scan called without calling next")
+	}
+	if len(dest) != len(r.row) {
+		return fmt.Errorf("expected %d destination arguments in scan, not %d", len(r.row), len(dest))
+	}
+	for i, d := range dest {
+		if err := assign(d, r.row[i]); err != nil {
+			return fmt.Errorf("scan column %d %q: %v", i, r.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// Err returns the error, if any, that ended the iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close releases the table cursor. It is called by Next at the end of the
+// result and is safe to call more than once.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.row = nil
+	return r.iter.close()
+}
+
+func assign(dest, src interface{}) error {
+	if d, ok := dest.(*interface{}); ok {
+		*d = src
+		return nil
+	}
+	if src == nil {
+		return fmt.Errorf("converting nil to %T is unsupported", dest)
+	}
+	switch d := dest.(type) {
+	case *string:
+		*d = fmt.Sprint(src)
+	case *[]byte:
+		*d = []byte(fmt.Sprint(src))
+	case *bool:
+		switch s := src.(type) {
+		case bool:
+			*d = s
+		case string:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			*d = b
+		default:
+			return fmt.Errorf("converting %T to bool is unsupported", src)
+		}
+	case *float64:
+		f, ok := toNumber(src)
+		if !ok {
+			return fmt.Errorf("converting %T %q to float64 is unsupported", src, src)
+		}
+		*d = f
+	case *time.Time:
+		t, ok := TypeTimestamp.convert(src)
+		if !ok {
+			return fmt.Errorf("converting %T %v to time.Time is unsupported", src, src)
+		}
+		*d = t.(time.Time)
+	case *int64:
+		n, err := assignInt(src)
+		if err != nil {
+			return err
+		}
+		*d = n
+	case *int:
+		n, err := assignInt(src)
+		if err != nil {
+			return err
+		}
+		*d = int(n)
+	default:
+		return fmt.Errorf("unsupported scan destination %T", dest)
+	}
+	return nil
+}
+
+func assignInt(src interface{}) (int64, error) {
+	if n, ok := toInt(src); ok {
+		return n, nil
+	}
+	if s, ok := src.(string); ok {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	if f, ok := toNumber(src); ok && f == float64(int64(f)) {
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("converting %T %v to int is unsupported", src, src)
+}
@@ -0,0 +1,116 @@
+package sql
+
+import (
+	"errors"
+	"strings"
+)
+
+// Statement is a statement of a script.
+type Statement struct {
+	Text  string // source of the statement, without the separator and the space and comments around it
+	Start int    // byte offset of Text in the script
+	End   int    // byte offset just past Text
+	Line  int    // line of Start, counting from 1
+	Query *model // the parsed statement, nil when Err is set
+	Err   error
+}
+
+var errUnclosedComment = errors.New("syntax error: unclosed comment")
+
+// ParseScript splits script into statements at the semicolons outside double
+// or single quoted strings and comments and parses each of them. Comments
+// run from "--" to the end of the line or from "/*" to "*/"; they may also
+// appear within a statement. A statement that fails to parse carries its
+// error and the following ones are parsed all the same. Empty statements are
+// skipped.
+func ParseScript(script string) []*Statement {
+	// Comments are blanked out for the parser, keeping the offsets.
+	blanked := []byte(script)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if blanked[i] != '\n' {
+				blanked[i] = ' '
+			}
+		}
+	}
+	var stmts []*Statement
+	start, unclosed := 0, -1
+	for i := 0; i < len(script); {
+		switch {
+		case script[i] == '"':
+			for i++; i < len(script) && script[i] != '"'; i++ {
+				if script[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case script[i] == '\'':
+			// a doubled quote within closes the string and opens another
+			for i++; i < len(script) && script[i] != '\''; i++ {
+			}
+			i++
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			blank(i, i+end)
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				unclosed = i
+				blank(i, len(script))
+				i = len(script)
+				break
+			}
+			blank(i, i+end+4)
+			i += end + 4
+		case script[i] == ';':
+			stmts = appendStatement(stmts, script, blanked, start, i)
+			start = i + 1
+			i++
+		default:
+			i++
+		}
+	}
+	stmts = appendStatement(stmts, script, blanked, start, len(script))
+	if unclosed >= 0 {
+		// the comment runs to the end, after every statement
+		stmts = append(stmts, &Statement{
+			Text:  strings.TrimRight(script[unclosed:], whitespace),
+			Start: unclosed,
+			End:   len(strings.TrimRight(script, whitespace)),
+			Line:  lineOf(script, unclosed),
+			Err:   errUnclosedComment,
+		})
+	}
+	return stmts
+}
+
+// appendStatement parses the statement between the offsets start and end
+// of script, unless it is empty. blanked is the script without comments.
+func appendStatement(stmts []*Statement, script string, blanked []byte, start, end int) []*Statement {
+	text := strings.TrimLeft(string(blanked[start:end]), whitespace)
+	start = end - len(text)
+	end = start + len(strings.TrimRight(text, whitespace))
+	if start == end {
+		return stmts
+	}
+	stmt := &Statement{
+		Text:  script[start:end],
+		Start: start,
+		End:   end,
+		Line:  lineOf(script, start),
+	}
+	p := NewParse(text[:end-start])
+	p.Generate()
+	if stmt.Err = p.Err(); stmt.Err == nil {
+		stmt.Query = &p.model
+	}
+	return append(stmts, stmt)
+}
+
+func lineOf(s string, offset int) int {
+	return strings.Count(s[:offset], "\n") + 1
+}
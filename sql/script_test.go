@@ -0,0 +1,62 @@
+package sql
+
+import "testing"
+
+func Test_ParseScript(t *testing.T) {
+	script := "-- users\nselect name where age > 3;\n\n" +
+		"select name where s = \"a;b\" /* not; here */ and t = 1 ;;\n" +
+		"select name where;\n" +
+		"select user from orders -- trailing; comment\n" +
+		"limit 2;\n" +
+		"select name /* unclosed"
+	type want struct {
+		text string
+		line int
+		err  bool
+	}
+	wants := []want{
+		{"select name where age > 3", 2, false},
+		{"select name where s = \"a;b\" /* not; here */ and t = 1", 4, false},
+		{"select name where", 5, true},
+		{"select user from orders -- trailing; comment\nlimit 2", 6, false},
+		{"select name", 8, false},
+		{"/* unclosed", 8, true},
+	}
+	stmts := ParseScript(script)
+	if len(stmts) != len(wants) {
+		t.Fatalf("got %d statements, want %d", len(stmts), len(wants))
+	}
+	for i, stmt := range stmts {
+		w := wants[i]
+		if stmt.Text != w.text || stmt.Line != w.line || (stmt.Err != nil) != w.err {
+			t.Errorf("statement %d: got %q line %d error %v, want %q line %d error %v", i, stmt.Text, stmt.Line, stmt.Err, w.text, w.line, w.err)
+		}
+		if script[stmt.Start:stmt.End] != stmt.Text {
+			t.Errorf("statement %d: span %d:%d holds %q", i, stmt.Start, stmt.End, script[stmt.Start:stmt.End])
+		}
+		if (stmt.Query == nil) != (stmt.Err != nil) {
+			t.Errorf("statement %d: query %v with error %v", i, stmt.Query, stmt.Err)
+		}
+	}
+	if q := stmts[3].Query; q == nil || q.TableName != "orders" || q.Limit != 2 {
+		t.Errorf("got %+v", q)
+	}
+	// a single quoted string may hold a semicolon, and a comment a quote
+	stmts = ParseScript("select name where created > date '2024-01-01' and name like \"a;%\" escape ';';\n" +
+		"select name where created < now() - interval '1 day' -- isn't\n;")
+	texts := []string{
+		"select name where created > date '2024-01-01' and name like \"a;%\" escape ';'",
+		"select name where created < now() - interval '1 day'",
+	}
+	if len(stmts) != len(texts) {
+		t.Fatalf("got %d statements, want %d", len(stmts), len(texts))
+	}
+	for i, stmt := range stmts {
+		if stmt.Text != texts[i] || stmt.Err != nil {
+			t.Errorf("statement %d: got %q error %v, want %q", i, stmt.Text, stmt.Err, texts[i])
+		}
+	}
+	if len(ParseScript(" ; -- nothing\n")) != 0 {
+		t.Error("expected no statements")
+	}
+}
@@ -0,0 +1,213 @@
+package sql
+
+import (
+	"context"
+	"io"
+)
+
+type SetOpType int
+
+const (
+	SetUnion SetOpType = iota
+	SetIntersect
+	SetExcept
+)
+
+var setOpNames = [...]string{
+	SetUnion:     KeyUnion,
+	SetIntersect: KeyIntersect,
+	SetExcept:    KeyExcept,
+}
+
+var itemType2SetOp = map[itemType]SetOpType{
+	itemUnion:     SetUnion,
+	itemIntersect: SetIntersect,
+	itemExcept:    SetExcept,
+}
+
+func (t SetOpType) String() string {
+	if t < 0 || int(t) >= len(setOpNames) {
+		return "unknown"
+	}
+	return setOpNames[t]
+}
+
+// SetOp combines the result of a query with those of the queries before it.
+// Intersect binds tighter than union and except, which apply from left to
+// right.
+type SetOp struct {
+	Type  SetOpType
+	All   bool // keep duplicate rows
+	Query *model
+}
+
+func (op *SetOp) String() string {
+	if op.All {
+		return op.Type.String() + Space + KeyAll
+	}
+	return op.Type.String()
+}
+
+// columnType returns the static type of the result column label, TypeAny
+// when it depends on the data.
+func (m *model) columnType(label string) Type {
+	for _, agg := range m.Aggragations.Items {
+		if agg.String() != label {
+			continue
+		}
+		t := TypeAny
+		if agg.Expr != nil {
+			t = exprType(agg.Expr)
+		}
+		return agg.Agg.resultType(t)
+	}
+	for _, e := range m.Expressions {
+		if e.String() == label {
+			return exprType(e)
+		}
+	}
+	for _, w := range m.Windows {
+		if w.String() != label {
+			continue
+		}
+		t := TypeAny
+		if arg := w.arg(); arg != nil {
+			t = exprType(arg)
+		}
+		return w.resultType(t)
+	}
+	return TypeAny
+}
+
+// compatible reports whether values of the types t and u may share a
+// column.
+func compatible(t, u Type) bool {
+	return t == TypeAny || u == TypeAny || t == u || t.numeric() && u.numeric()
+}
+
+// setIter combines the rows of its inputs by the set operators between
+// them, reading them all on the first call.
+type setIter struct {
+	ctx    context.Context
+	inputs []rowIterator // the query with the operators, then one per operator
+	ops    []*SetOp
+	rows   [][]interface{}
+	done   bool
+}
+
+func (it *setIter) next() ([]interface{}, error) {
+	if !it.done {
+		if err := it.combine(); err != nil {
+			return nil, err
+		}
+		it.done = true
+	}
+	if len(it.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+// combine applies the intersections first and then the unions and excepts
+// to their results.
+func (it *setIter) combine() error {
+	first, err := readAll(it.ctx, it.inputs[0])
+	if err != nil {
+		return err
+	}
+	terms := [][][]interface{}{first}
+	var ops []*SetOp
+	for i, op := range it.ops {
+		rows, err := readAll(it.ctx, it.inputs[i+1])
+		if err != nil {
+			return err
+		}
+		if op.Type == SetIntersect {
+			terms[len(terms)-1] = applySetOp(op, terms[len(terms)-1], rows)
+			continue
+		}
+		terms = append(terms, rows)
+		ops = append(ops, op)
+	}
+	it.rows = terms[0]
+	for i, op := range ops {
+		it.rows = applySetOp(op, it.rows, terms[i+1])
+	}
+	return nil
+}
+
+func (it *setIter) close() error {
+	it.rows = nil
+	var err error
+	for _, input := range it.inputs {
+		if cerr := input.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// applySetOp combines the rows a and b. Without all, the result holds each
+// row once; with it, intersect keeps a row as often as both sides have it
+// and except as often as a has it more than b.
+func applySetOp(op *SetOp, a, b [][]interface{}) [][]interface{} {
+	var rows [][]interface{}
+	seen := rowSet{}
+	if op.Type == SetUnion {
+		for _, part := range [][][]interface{}{a, b} {
+			for _, row := range part {
+				if op.All || seen.add(row) == 1 {
+					rows = append(rows, row)
+				}
+			}
+		}
+		return rows
+	}
+	other := rowSet{}
+	for _, row := range b {
+		other.add(row)
+	}
+	for _, row := range a {
+		found := other.find(row)
+		in := found != nil && found.n > 0
+		if op.All && in {
+			found.n--
+		}
+		if in != (op.Type == SetIntersect) || !op.All && seen.add(row) > 1 {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+type rowCount struct {
+	row []interface{}
+	n   int
+}
+
+// rowSet counts rows, which are equal when compareValues takes each of
+// their values as equal. Nulls are equal to each other.
+type rowSet map[string][]*rowCount
+
+func (s rowSet) find(row []interface{}) *rowCount {
+	for _, c := range s[hashKey(row)] {
+		if equalKeys(c.row, row) {
+			return c
+		}
+	}
+	return nil
+}
+
+// add counts row and returns how often it has been added.
+func (s rowSet) add(row []interface{}) int {
+	if c := s.find(row); c != nil {
+		c.n++
+		return c.n
+	}
+	k := hashKey(row)
+	s[k] = append(s[k], &rowCount{row: row, n: 1})
+	return 1
+}
@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// hllPrecision is the number of hash bits choosing a register of a
+// hyperLogLog: 4096 registers estimate within about 1.6%.
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct strings added to it in a
+// fixed amount of memory. Each register keeps the longest run of leading
+// zeros seen in the hashes falling on it.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{}
+}
+
+func (h *hyperLogLog) add(s string) {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	x := mix64(f.Sum64())
+	i := x >> (64 - hllPrecision)
+	// the guard bit bounds the run when the remaining bits are all zero
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// count returns the estimate, counting the empty registers instead while
+// many are left, where that is the more accurate.
+func (h *hyperLogLog) count() int64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// mix64 spreads the bits of the FNV hash of short strings over the whole
+// word, as the finalizer of MurmurHash3 does.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// sketchAccuracy is the relative error of the values a quantileSketch
+// returns.
+const sketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// quantileSketch estimates the quantiles of the numbers added to it. It
+// counts them in buckets whose bounds grow by sketchGamma, so that its size
+// grows with the logarithm of the range of the numbers rather than with
+// their count, and returns the middle of a bucket, within sketchAccuracy of
+// any number in it.
+type quantileSketch struct {
+	n        int64
+	zeros    int64
+	pos, neg map[int]int64 // the counts of the buckets of the magnitudes
+}
+
+func newQuantileSketch() *quantileSketch {
+	return &quantileSketch{pos: make(map[int]int64), neg: make(map[int]int64)}
+}
+
+func (s *quantileSketch) add(f float64) {
+	s.n++
+	switch {
+	case f > 0:
+		s.pos[sketchKey(f)]++
+	case f < 0:
+		s.neg[sketchKey(-f)]++
+	default:
+		s.zeros++
+	}
+}
+
+func sketchKey(f float64) int {
+	return int(math.Ceil(math.Log(f) / sketchLogGamma))
+}
+
+func sketchValue(key int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(key)) / (sketchGamma + 1)
+}
+
+// quantile returns the number that the fraction q of the numbers falls
+// below.
+func (s *quantileSketch) quantile(q float64) float64 {
+	rank := int64(q * float64(s.n-1))
+	for _, k := range sortedKeys(s.neg, true) {
+		if rank < s.neg[k] {
+			return -sketchValue(k)
+		}
+		rank -= s.neg[k]
+	}
+	if rank < s.zeros {
+		return 0
+	}
+	rank -= s.zeros
+	keys := sortedKeys(s.pos, false)
+	for _, k := range keys {
+		if rank < s.pos[k] {
+			return sketchValue(k)
+		}
+		rank -= s.pos[k]
+	}
+	return sketchValue(keys[len(keys)-1])
+}
+
+func sortedKeys(counts map[int]int64, desc bool) []int {
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	} else {
+		sort.Ints(keys)
+	}
+	return keys
+}
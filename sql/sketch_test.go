@@ -0,0 +1,45 @@
+package sql
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func Test_HyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := newHyperLogLog()
+		for i := 0; i < n; i++ {
+			// every value twice
+			h.add("v" + strconv.Itoa(i))
+			h.add("v" + strconv.Itoa(i))
+		}
+		got := h.count()
+		if n <= 10 && got != int64(n) {
+			t.Errorf("%d values: got %d", n, got)
+		}
+		if err := math.Abs(float64(got)-float64(n)) / float64(n); n > 10 && err > 0.05 {
+			t.Errorf("%d values: got %d, off by %.1f%%", n, got, err*100)
+		}
+	}
+}
+
+func Test_QuantileSketch(t *testing.T) {
+	s := newQuantileSketch()
+	for i := -1000; i <= 9000; i++ {
+		s.add(float64(i))
+	}
+	for _, test := range []struct{ q, want float64 }{
+		{0, -1000},
+		{0.05, -500},
+		{0.1, 0},
+		{0.5, 4000},
+		{0.99, 8900},
+		{1, 9000},
+	} {
+		got := s.quantile(test.q)
+		if math.Abs(got-test.want) > sketchAccuracy*math.Abs(test.want) {
+			t.Errorf("quantile %v: got %v, want %v", test.q, got, test.want)
+		}
+	}
+}
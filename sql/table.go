@@ -0,0 +1,220 @@
+package sql
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Table is a source of rows for the executor.
+type Table interface {
+	// Columns returns the column names, in row order.
+	Columns() []string
+	// Cursor starts a scan over the rows of the table.
+	Cursor(ctx context.Context) (Cursor, error)
+}
+
+// WritableTable is a Table that insert, update and delete statements can
+// modify. The functions given to Update and Delete see every row; when
+// they fail, the table is left as it was.
+type WritableTable interface {
+	Table
+	// Insert appends a row of values, one per column.
+	Insert(values ...interface{}) error
+	// Update replaces each row set returns a new row for, keeping those it
+	// returns nil for, and returns the number of rows replaced.
+	Update(ctx context.Context, set func(row []interface{}) ([]interface{}, error)) (int64, error)
+	// Delete removes the rows match returns true for and returns their
+	// number.
+	Delete(ctx context.Context, match func(row []interface{}) (bool, error)) (int64, error)
+}
+
+// Cursor iterates over the rows of a table. Next returns io.EOF after the
+// last row.
+type Cursor interface {
+	Next() ([]interface{}, error)
+	Close() error
+}
+
+// MemTable is a WritableTable held in memory. Writes replace the rows
+// rather than change them, so open cursors keep seeing the rows as they
+// were.
+type MemTable struct {
+	mu      sync.RWMutex
+	wmu     sync.Mutex // serializes writes, which read the rows without mu
+	columns []string
+	rows    [][]interface{}
+}
+
+func NewMemTable(columns []string, rows ...[]interface{}) *MemTable {
+	t := &MemTable{columns: columns}
+	for _, r := range rows {
+		t.Insert(r...)
+	}
+	return t
+}
+
+func (t *MemTable) Columns() []string {
+	return t.columns
+}
+
+// Insert appends a row. Missing trailing values are stored as nil.
+func (t *MemTable) Insert(values ...interface{}) error {
+	if len(values) > len(t.columns) {
+		return fmt.Errorf("insert %d values into %d columns", len(values), len(t.columns))
+	}
+	row := make([]interface{}, len(t.columns))
+	copy(row, values)
+	t.wmu.Lock()
+	t.mu.Lock()
+	t.rows = append(t.rows, row)
+	t.mu.Unlock()
+	t.wmu.Unlock()
+	return nil
+}
+
+func (t *MemTable) Update(ctx context.Context, set func(row []interface{}) ([]interface{}, error)) (int64, error) {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	rows := make([][]interface{}, len(t.rows))
+	var n int64
+	for i, row := range t.rows {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		updated, err := set(row)
+		if err != nil {
+			return 0, err
+		}
+		if updated == nil {
+			rows[i] = row
+			continue
+		}
+		if len(updated) != len(t.columns) {
+			return 0, fmt.Errorf("update %d values into %d columns", len(updated), len(t.columns))
+		}
+		rows[i] = updated
+		n++
+	}
+	t.replace(rows)
+	return n, nil
+}
+
+func (t *MemTable) Delete(ctx context.Context, match func(row []interface{}) (bool, error)) (int64, error) {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	rows := make([][]interface{}, 0, len(t.rows))
+	for _, row := range t.rows {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		ok, err := match(row)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			rows = append(rows, row)
+		}
+	}
+	n := int64(len(t.rows) - len(rows))
+	t.replace(rows)
+	return n, nil
+}
+
+func (t *MemTable) replace(rows [][]interface{}) {
+	t.mu.Lock()
+	t.rows = rows
+	t.mu.Unlock()
+}
+
+// Cursor returns a cursor over the rows present when it is called.
+func (t *MemTable) Cursor(ctx context.Context) (Cursor, error) {
+	t.mu.RLock()
+	rows := t.rows
+	t.mu.RUnlock()
+	return &memCursor{rows: rows}, nil
+}
+
+type memCursor struct {
+	rows [][]interface{}
+	pos  int
+}
+
+func (c *memCursor) Next() ([]interface{}, error) {
+	if c.pos >= len(c.rows) {
+		return nil, io.EOF
+	}
+	c.pos++
+	return c.rows[c.pos-1], nil
+}
+
+func (c *memCursor) Close() error {
+	c.pos = len(c.rows)
+	return nil
+}
+
+// FileTable is a Table backed by a csv file whose first record holds the
+// column names. Every cursor reads the file anew, one record per row, and
+// all values are strings.
+type FileTable struct {
+	path    string
+	columns []string
+}
+
+func NewFileTable(path string) (*FileTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	columns, err := csv.NewReader(f).Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: missing header", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FileTable{path: path, columns: columns}, nil
+}
+
+func (t *FileTable) Columns() []string {
+	return t.columns
+}
+
+func (t *FileTable) Cursor(ctx context.Context) (Cursor, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(t.columns)
+	if _, err := r.Read(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileCursor{file: f, reader: r}, nil
+}
+
+type fileCursor struct {
+	file   *os.File
+	reader *csv.Reader
+}
+
+func (c *fileCursor) Next() ([]interface{}, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(record))
+	for i, v := range record {
+		row[i] = v
+	}
+	return row, nil
+}
+
+func (c *fileCursor) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,24 @@
+select name, age where age > 18 order by age desc limit 5
+select name from users where (region = "cn" or region = "us") and not age in (1, 2, 3)
+select n.name from graph where n.name like "a_%\\*[x]?" and score != 1.5
+select region, count(id), distinct(user), average(age) where active = true group by region order by count(id) desc, region
+select name, sum(age) group by name order by max(age) limit 10 offset 20
+select name offset 20
+select name where age >= ? and name like ?
+select name where age > $1 and region = $3 or name != $3
+select name where name ilike "a!%\\_%" escape "!" and region not like "cn\\%"
+select name where name ~ "^a.+" and region not rlike "^cn-" or name !~ "[0-9]"
+select region, count(distinct name), avg(age), array_agg(name), string_agg(name, ", "), approx_count_distinct(age) group by region
+select distinct region, lower(name) where age > ? order by lower(name) desc
+select name, region, row_number() over (partition by region order by age desc), sum(age) over (order by age), lag(name) over (order by age) where age > ? order by region, name
+select name, created + interval '1 month 2 days' where created > now() - interval '7 days' and created < timestamp '2024-03-01 12:00:00+02:00' and day >= date '2024-01-01'
+select upper(name), length(region) where lower(name) like "a%" and substr(region, 1, 2) = "us" order by coalesce(age, 0) desc
+select name || "@" || region, -age * (price + 1) where (price - discount) * qty > 1000 order by price % 7
+select name where created_at < updated_at and src.region = dst.region and total != price * qty
+select name where id in (select user_id from orders where total > ?) and exists (select id from bans where bans.user_id = graph.id limit 1) and age > (select average(age) from graph where name like ?)
+select u.name, count(o.id) from users u left join orders o on o.user_id = u.id and o.total > ? cross join regions where u.region = regions.name group by u.name
+select name from graph where age > ? union select user_id from orders where total > ? except select name from bans order by name desc limit 3
+insert into items (id, name, price) values (1, ?, 2.5), (2, lower("MUG"), 3 * 2)
+update items set price = price * 2, name = ? where id in (1, 2) and name like ?
+delete from items where price > ? or name = "x"
+create table events (id int, name string, score number, at timestamp)
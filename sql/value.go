@@ -0,0 +1,228 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// toNumber converts v to a float64. Strings holding a number are accepted,
+// since file backed tables carry every value as text.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toInt converts an integer valued v to an int64.
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// arith applies an arithmetic operator to the numbers a and b. Integers
+// stay integers, their division truncating; otherwise the result is a float.
+func arith(op Operator, a, b interface{}) (interface{}, error) {
+	if isTemporal(a) || isTemporal(b) {
+		return timeArith(op, a, b)
+	}
+	x, ok := TypeNumber.convert(a)
+	if !ok {
+		return nil, fmt.Errorf("operand %v of %s is not a number", a, op)
+	}
+	y, ok := TypeNumber.convert(b)
+	if !ok {
+		return nil, fmt.Errorf("operand %v of %s is not a number", b, op)
+	}
+	if i, ok := x.(int64); ok {
+		if j, ok := y.(int64); ok {
+			switch op {
+			case OpAdd:
+				return i + j, nil
+			case OpSub:
+				return i - j, nil
+			case OpMul:
+				return i * j, nil
+			case OpDiv, OpMod:
+				if j == 0 {
+					return nil, errDivisionByZero
+				}
+				if op == OpDiv {
+					return i / j, nil
+				}
+				return i % j, nil
+			}
+		}
+	}
+	f, _ := toNumber(x)
+	g, _ := toNumber(y)
+	switch op {
+	case OpAdd:
+		return f + g, nil
+	case OpSub:
+		return f - g, nil
+	case OpMul:
+		return f * g, nil
+	case OpDiv, OpMod:
+		if g == 0 {
+			return nil, errDivisionByZero
+		}
+		if op == OpDiv {
+			return f / g, nil
+		}
+		return math.Mod(f, g), nil
+	}
+	return nil, fmt.Errorf("operator %s not supported", op)
+}
+
+// negate negates the number or interval v.
+func negate(v interface{}) (interface{}, error) {
+	if iv, ok := v.(Interval); ok {
+		return iv.neg(), nil
+	}
+	x, ok := TypeNumber.convert(v)
+	if !ok {
+		return nil, fmt.Errorf("operand %v of - is not a number", v)
+	}
+	if i, ok := x.(int64); ok {
+		return -i, nil
+	}
+	return -x.(float64), nil
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+// compareValues orders a and b, returning -1, 0 or 1. Numbers compare
+// numerically, also against strings holding a number; anything else
+// compares by its text. nil sorts before every other value.
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if !isString(a) || !isString(b) {
+		if x, ok := toNumber(a); ok {
+			if y, ok := toNumber(b); ok {
+				return compareFloat(x, y)
+			}
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			return compareBool(x, y)
+		}
+	}
+	// a time compares with a string holding one as instants
+	if isTemporal(a) || isTemporal(b) {
+		if x, ok := toTime(a); ok {
+			if y, ok := toTime(b); ok {
+				return compareTime(x, y)
+			}
+		}
+		if x, ok := a.(Interval); ok {
+			if y, ok := b.(Interval); ok {
+				return compareFloat(float64(x.approx()), float64(y.approx()))
+			}
+		}
+	}
+	return compareString(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareBool(x, y bool) int {
+	switch {
+	case x == y:
+		return 0
+	case !x:
+		return -1
+	}
+	return 1
+}
+
+func compareTime(x, y time.Time) int {
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	}
+	return 0
+}
+
+func compareString(x, y string) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
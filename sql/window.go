@@ -0,0 +1,323 @@
+package sql
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Window is a window function, or an aggragation computed over a window,
+// such as "rank() over (partition by region order by age desc)". It is
+// computed for each result row over the rows of its partition, after the
+// rows are grouped and before they are ordered.
+type Window struct {
+	Func        string      // row_number, rank, lag or lead, empty for an aggragation
+	Args        []Expr      // the arguments of Func
+	Agg         aggItem     // the aggragation when Func is empty
+	PartitionBy []string    // columns splitting the rows into partitions
+	OrderBy     []orderItem // columns ordering the rows of a partition
+}
+
+// windowFunctions are the functions computed over a window only. The
+// offset of lag and lead is a constant and their default a value of the
+// type of their argument.
+var windowFunctions = map[string]*Function{
+	KeyRowNumber: {Result: TypeInt},
+	KeyRank:      {Result: TypeInt},
+	KeyLag:       {Args: []Type{TypeAny, TypeInt, TypeAny}, Optional: 2, Result: TypeAny},
+	KeyLead:      {Args: []Type{TypeAny, TypeInt, TypeAny}, Optional: 2, Result: TypeAny},
+}
+
+func (w *Window) String() string {
+	var b strings.Builder
+	if w.Func == "" {
+		b.WriteString(w.Agg.String())
+	} else {
+		b.WriteString((&Call{Name: w.Func, Args: w.Args}).String())
+	}
+	b.WriteString(Space + KeyOver + Space + MarkLeftParen)
+	if len(w.PartitionBy) > 0 {
+		b.WriteString("partition by " + strings.Join(w.PartitionBy, MakrComma+Space))
+		if len(w.OrderBy) > 0 {
+			b.WriteString(Space)
+		}
+	}
+	if len(w.OrderBy) > 0 {
+		items := make([]string, len(w.OrderBy))
+		for i, o := range w.OrderBy {
+			items[i] = o.Column
+			if o.Desc {
+				items[i] += Space + KeyDesc
+			}
+		}
+		b.WriteString("order by " + strings.Join(items, MakrComma+Space))
+	}
+	b.WriteString(MarkRightParen)
+	return b.String()
+}
+
+// arg returns the expression the window computes its values from, nil for
+// row_number and rank.
+func (w *Window) arg() Expr {
+	switch {
+	case w.Func == "" && w.Agg.Expr != nil:
+		return w.Agg.Expr
+	case w.Func == "":
+		return &Ident{Name: w.Agg.Field}
+	case len(w.Args) > 0:
+		return w.Args[0]
+	}
+	return nil
+}
+
+// resultType returns the type of the values of the window, whose argument
+// is of type t.
+func (w *Window) resultType(t Type) Type {
+	if w.Func == "" {
+		return w.Agg.Agg.resultType(t)
+	}
+	if fn := windowFunctions[w.Func]; fn.Result != TypeAny {
+		return fn.Result
+	}
+	return t
+}
+
+// columns returns the columns the window partitions and orders on.
+func (w *Window) columns() []string {
+	columns := append([]string(nil), w.PartitionBy...)
+	for _, o := range w.OrderBy {
+		columns = append(columns, o.Column)
+	}
+	return columns
+}
+
+// windowFields returns the fields the windows of m refer to: those of their
+// arguments, and the columns they partition and order on that are not
+// computed.
+func (m *model) windowFields() []string {
+	var fields []string
+	for _, w := range m.Windows {
+		if w.Func == "" && w.Agg.Expr == nil {
+			fields = append(fields, w.Agg.Field)
+		} else if w.Func == "" {
+			fields = append(fields, exprFields(w.Agg.Expr)...)
+		}
+		for _, arg := range w.Args {
+			fields = append(fields, exprFields(arg)...)
+		}
+		for _, c := range w.columns() {
+			if !m.computes(c) {
+				fields = append(fields, c)
+			}
+		}
+	}
+	return fields
+}
+
+// computes reports whether column is an expression or an aggragation of m.
+func (m *model) computes(column string) bool {
+	for _, agg := range m.Aggragations.Items {
+		if agg.String() == column {
+			return true
+		}
+	}
+	for _, e := range m.Expressions {
+		if e.String() == column {
+			return true
+		}
+	}
+	return false
+}
+
+// windowSpec is a window compiled against the rows it is computed over.
+type windowSpec struct {
+	window    *Window
+	args      []evaluator // the argument of the aggragation, or those of the function
+	partition []int
+	order     []sortKey
+	offset    int // rows before or after the current one of lag and lead
+}
+
+// compileWindow compiles w against the columns of the rows it is computed
+// over.
+func compileWindow(w *Window, columns []string, s *scope) (windowSpec, error) {
+	spec := windowSpec{window: w, offset: 1}
+	args := w.Args
+	if w.Func == "" {
+		args = []Expr{w.arg()}
+	}
+	for i, arg := range args {
+		if l, ok := arg.(*Literal); ok && i == 1 {
+			n, _ := toInt(l.Value)
+			spec.offset = int(n)
+		}
+		eval, err := compileExpr(arg, columns, s)
+		if err != nil {
+			return windowSpec{}, err
+		}
+		spec.args = append(spec.args, eval)
+	}
+	for _, c := range w.PartitionBy {
+		idx, err := columnIndex(columns, s.tables, c)
+		if err != nil {
+			return windowSpec{}, err
+		}
+		spec.partition = append(spec.partition, idx)
+	}
+	for _, o := range w.OrderBy {
+		idx, err := columnIndex(columns, s.tables, o.Column)
+		if err != nil {
+			return windowSpec{}, err
+		}
+		spec.order = append(spec.order, sortKey{index: idx, desc: o.Desc})
+	}
+	return spec, nil
+}
+
+// windowIter buffers its input and appends the values of the windows to
+// each row, which keeps its place in the input.
+type windowIter struct {
+	ctx     context.Context
+	input   rowIterator
+	windows []windowSpec
+	rows    [][]interface{}
+	done    bool
+}
+
+func (it *windowIter) next() ([]interface{}, error) {
+	if !it.done {
+		if err := it.fill(); err != nil {
+			return nil, err
+		}
+		it.done = true
+	}
+	if len(it.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+func (it *windowIter) fill() error {
+	input, err := readAll(it.ctx, it.input)
+	if err != nil {
+		return err
+	}
+	rows := make([][]interface{}, len(input))
+	for i, row := range input {
+		rows[i] = make([]interface{}, len(row), len(row)+len(it.windows))
+		copy(rows[i], row)
+	}
+	for _, spec := range it.windows {
+		for _, part := range spec.partitions(input) {
+			values, err := spec.compute(input, part)
+			if err != nil {
+				return err
+			}
+			for i, idx := range part {
+				rows[idx] = append(rows[idx], values[i])
+			}
+		}
+	}
+	it.rows = rows
+	return nil
+}
+
+func (it *windowIter) close() error {
+	it.rows = nil
+	return it.input.close()
+}
+
+// partitions returns the indexes of the rows of each partition, in window
+// order. Rows ordered alike keep their input order.
+func (spec windowSpec) partitions(rows [][]interface{}) [][]int {
+	var parts [][]int
+	byKey := make(map[string]int)
+	for i, row := range rows {
+		keys := make([]interface{}, len(spec.partition))
+		for j, idx := range spec.partition {
+			keys[j] = row[idx]
+		}
+		k := groupKey(keys)
+		n, ok := byKey[k]
+		if !ok {
+			n = len(parts)
+			byKey[k] = n
+			parts = append(parts, nil)
+		}
+		parts[n] = append(parts[n], i)
+	}
+	for _, part := range parts {
+		sort.SliceStable(part, func(i, j int) bool {
+			return compareRows(spec.order, rows[part[i]], rows[part[j]]) < 0
+		})
+	}
+	return parts
+}
+
+// compute returns the values of the window for the rows of the partition
+// part. An aggragation over a window with an order by is computed over the
+// rows up to the current one and those ordered alike, and over the whole
+// partition otherwise.
+func (spec windowSpec) compute(rows [][]interface{}, part []int) ([]interface{}, error) {
+	values := make([]interface{}, len(part))
+	peers := func(i, j int) bool {
+		return compareRows(spec.order, rows[part[i]], rows[part[j]]) == 0
+	}
+	switch spec.window.Func {
+	case KeyRowNumber:
+		for i := range part {
+			values[i] = int64(i + 1)
+		}
+	case KeyRank:
+		for i := range part {
+			if i > 0 && peers(i-1, i) {
+				values[i] = values[i-1]
+			} else {
+				values[i] = int64(i + 1)
+			}
+		}
+	case KeyLag, KeyLead:
+		offset := -spec.offset
+		if spec.window.Func == KeyLead {
+			offset = spec.offset
+		}
+		for i, idx := range part {
+			var err error
+			if j := i + offset; j >= 0 && j < len(part) {
+				values[i], err = spec.args[0](rows[part[j]])
+			} else if len(spec.args) > 2 {
+				values[i], err = spec.args[2](rows[idx])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		acc := newAccumulator(spec.window.Agg)
+		for i := 0; i < len(part); {
+			// the rows ordered alike share the value, the whole partition
+			// without an order by
+			j := i + 1
+			for j < len(part) && (len(spec.order) == 0 || peers(i, j)) {
+				j++
+			}
+			for _, idx := range part[i:j] {
+				v, err := spec.args[0](rows[idx])
+				if err != nil {
+					return nil, err
+				}
+				if err := acc.add(v); err != nil {
+					return nil, err
+				}
+			}
+			v := acc.result()
+			for ; i < j; i++ {
+				values[i] = v
+			}
+		}
+	}
+	return values, nil
+}